@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// majorTag returns the floating major version tag for tag, like v1 for
+// v1.4.0, or "" for prereleases, which don't move it.
+func majorTag(tag, prefix string) string {
+	v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+	if err != nil || v.Prerelease() != "" {
+		return ""
+	}
+	return fmt.Sprintf("%s%s%d", prefix, vPrefix, v.Segments()[0])
+}
+
+// moveMajorTag points the major version tag of d's version at its commit,
+// the convention for Github Actions, used as org/action@v1.
+func (c *client) moveMajorTag(ctx context.Context, cfg *config, d decision) error {
+	if c.release != nil {
+		return c.release.moveMajorTag(ctx, cfg, d)
+	}
+	tag := majorTag(d.version, cfg.prefix)
+	if tag == "" {
+		return nil
+	}
+
+	ref := &github.Reference{
+		Ref:    github.String("refs/tags/" + tag),
+		Object: &github.GitObject{SHA: github.String(d.ref)},
+	}
+	// looked up among the refs starting with it, v1 is a prefix of v1.4.0
+	refs, resp, err := c.c.Git.GetRefs(ctx, c.owner, c.repo, "tags/"+tag)
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
+		return err
+	}
+	exists := false
+	for _, r := range refs {
+		exists = exists || r.GetRef() == "refs/tags/"+tag
+	}
+
+	if exists {
+		_, _, err = c.c.Git.UpdateRef(ctx, c.owner, c.repo, ref, true)
+	} else {
+		_, _, err = c.c.Git.CreateRef(ctx, c.owner, c.repo, ref)
+	}
+	if err != nil {
+		return err
+	}
+	tracef("Moved %s to %s", tag, d.ref)
+	return nil
+}
+
+// updatePins updates the pins of the action repo, like "uses: org/action@v1"
+// or "uses: org/action/sub@v1.2.3", to tag, as precise as they were: v1
+// becomes v2 when tag is v2.0.0, v1.2.3 becomes v2.0.0. Pins to
+// prereleases become the release. Pins to newer versions, branches or
+// commits are left alone.
+func updatePins(text, repo, prefix, tag string) string {
+	v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+	if err != nil {
+		return text
+	}
+	segs := v.Segments()
+
+	re := regexp.MustCompile(`(uses:\s*["']?` + regexp.QuoteMeta(repo) + `(?:/[^@\s"']*)?@` + regexp.QuoteMeta(prefix) + `)(v?)(\d+(?:\.\d+)*)(-[0-9A-Za-z.-]+)?\b`)
+	return re.ReplaceAllStringFunc(text, func(m string) string {
+		parts := re.FindStringSubmatch(m)
+		if pinned, err := version.NewSemver(parts[3] + parts[4]); err != nil || pinned.GreaterThan(v) {
+			return m
+		}
+		n := len(strings.Split(parts[3], "."))
+		if n > len(segs) {
+			n = len(segs)
+		}
+		nums := make([]string, n)
+		for i := range nums {
+			nums[i] = fmt.Sprint(segs[i])
+		}
+		return parts[1] + parts[2] + strings.Join(nums, ".")
+	})
+}
+
+// proposePins opens a pull request updating the action's pins in
+// PIN_FILES, like README examples, to d's version, or updates the one
+// that's already open. Nothing is opened when the pins are up to date.
+func (c *client) proposePins(ctx context.Context, cfg *config, d decision, base string) error {
+	if majorTag(d.version, cfg.prefix) == "" {
+		// examples shouldn't point at prereleases
+		return nil
+	}
+	if err := c.resetBranch(ctx, cfg.pinBranch, d.ref); err != nil {
+		return fmt.Errorf("could not cut %s: %v", cfg.pinBranch, err)
+	}
+
+	changed := false
+	for _, path := range cfg.pinFiles {
+		err := c.updateFile(ctx, cfg.pinBranch, path, "Pin "+d.version+" in "+path, func(old []byte) ([]byte, error) {
+			if old == nil {
+				return nil, fmt.Errorf("%s doesn't exist", path)
+			}
+			text := updatePins(string(old), c.owner+"/"+c.repo, cfg.prefix, d.version)
+			changed = changed || text != string(old)
+			return []byte(text), nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not update %s: %v", path, err)
+		}
+	}
+	if !changed {
+		tracef("Pins in %s are up to date", strings.Join(cfg.pinFiles, ", "))
+		return nil
+	}
+
+	title := "Use " + d.version + " in examples"
+	body := fmt.Sprintf("Updates the pins of %s/%s in %s to %s.", c.owner, c.repo, strings.Join(cfg.pinFiles, ", "), d.version)
+	number, opened, err := c.openPR(ctx, cfg.pinBranch, base, title, body)
+	if err != nil {
+		return err
+	}
+	if opened {
+		tracef("Opened PR #%d pinning %s", number, d.version)
+	} else {
+		tracef("Updated PR #%d pinning %s", number, d.version)
+	}
+	return nil
+}
+
+// openPR opens a pull request of head into base, or updates the title and
+// body of the one that's already open. It returns the PR's number, and
+// whether it was opened.
+func (c *client) openPR(ctx context.Context, head, base, title, body string) (int, bool, error) {
+	prs, _, err := c.c.PullRequests.List(ctx, c.owner, c.repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  c.owner + ":" + head,
+		Base:  base,
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("could not list pull requests: %v", err)
+	}
+
+	if len(prs) > 0 {
+		_, _, err = c.c.PullRequests.Edit(ctx, c.owner, c.repo, prs[0].GetNumber(), &github.PullRequest{
+			Title: github.String(title),
+			Body:  github.String(body),
+		})
+		if err != nil {
+			return 0, false, fmt.Errorf("could not update PR #%d: %v", prs[0].GetNumber(), err)
+		}
+		return prs[0].GetNumber(), false, nil
+	}
+
+	created, _, err := c.c.PullRequests.Create(ctx, c.owner, c.repo, &github.NewPullRequest{
+		Title: github.String(title),
+		Head:  github.String(head),
+		Base:  github.String(base),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("could not open PR: %v", err)
+	}
+	return created.GetNumber(), true, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import "testing"
+
+func Test_majorTag(t *testing.T) {
+	tests := []struct {
+		tag, want string
+	}{
+		{"v1.4.0", "v1"},
+		{"v2.0.3", "v2"},
+		{"v2.0.0-rc.1", ""},
+		{"api/v3.1.0", "api/v3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			prefix := ""
+			if tt.tag[0] == 'a' {
+				prefix = "api/"
+			}
+			if got := majorTag(tt.tag, prefix); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_updatePins(t *testing.T) {
+	text := `steps:
+  - uses: actions/checkout@v4
+  - uses: manifoldco/autotagger@v1
+  - uses: manifoldco/autotagger/setup@v1.4.2
+  - uses: "manifoldco/autotagger@v1.5.0-rc.1"
+  - uses: manifoldco/autotagger@main
+  - uses: manifoldco/autotagger-fork@v1
+`
+	want := `steps:
+  - uses: actions/checkout@v4
+  - uses: manifoldco/autotagger@v2
+  - uses: manifoldco/autotagger/setup@v2.0.0
+  - uses: "manifoldco/autotagger@v2.0.0"
+  - uses: manifoldco/autotagger@main
+  - uses: manifoldco/autotagger-fork@v1
+`
+	if got := updatePins(text, "manifoldco/autotagger", "", "v2.0.0"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	want = `steps:
+  - uses: actions/checkout@v4
+  - uses: manifoldco/autotagger@v1
+  - uses: manifoldco/autotagger/setup@v1.4.3
+  - uses: "manifoldco/autotagger@v1.5.0-rc.1"
+  - uses: manifoldco/autotagger@main
+  - uses: manifoldco/autotagger-fork@v1
+`
+	if got := updatePins(text, "manifoldco/autotagger", "", "v1.4.3"); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// skipAggregate is why merges aren't tagged in AGGREGATE mode.
+const skipAggregate = "waiting for the next aggregate release"
+
+// releaseCmd cuts one release covering every pull request merged into the
+// branch since the last tag, for AGGREGATE mode, where merges aren't tagged
+// on their own. It's meant to run on a schedule or a manual trigger.
+func releaseCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("release", flag.ExitOnError)
+	branch := fs.String("branch", "", "branch to release (default: the repository's default branch)")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	if *branch == "" {
+		r, _, err := cli.c.Repositories.Get(ctx, cli.owner, cli.repo)
+		if err != nil {
+			fatalf("could not get repository: %v", err)
+		}
+		*branch = r.GetDefaultBranch()
+	}
+
+	cfg.useBranch(*branch)
+	last, previous, err := cli.lastRelease(ctx, cfg)
+	if err != nil {
+		fatal(err)
+	}
+	if previous == "" {
+		fatalf("no versions under %q yet, tag the first release before aggregating", cfg.prefix)
+	}
+
+	since, err := cli.commitDate(ctx, previous)
+	if err != nil {
+		fatalf("could not get date of %s: %v", previous, err)
+	}
+	prs, err := cli.mergedSince(ctx, *branch, since)
+	if err != nil {
+		fatalf("could not list pull requests: %v", err)
+	}
+	if len(prs) == 0 {
+		fmt.Printf("Nothing merged into %s since %s\n", *branch, previous)
+		exitSkipped("nothing_merged")
+	}
+	fmt.Printf("Found %d pull requests merged into %s since %s\n", len(prs), *branch, previous)
+
+	b, _, err := cli.c.Repositories.GetBranch(ctx, cli.owner, cli.repo, *branch)
+	if err != nil {
+		fatalf("could not get branch %s: %v", *branch, err)
+	}
+	ref := b.GetCommit().GetSHA()
+	reportContext["repository"] = cli.owner + "/" + cli.repo
+	reportContext["sha"] = ref
+
+	d := cli.decideAggregate(ctx, cfg, last, previous, ref, prs)
+	if d.skip == "" {
+		if reason := cli.gate(ctx, cfg, d); reason != "" {
+			fatalf("release of %s is blocked: %s", d.version, reason)
+		}
+	}
+	cfg.useDigest(len(prs))
+	cli.apply(ctx, cfg, d, prs[len(prs)-1])
+	cli.sendDigest(ctx, cfg, prs[len(prs)-1])
+
+	if d.skip == skipConflict {
+		os.Exit(exConfig)
+	}
+	fmt.Println("Done")
+}
+
+// decideAggregate works out the release of ref, the head of the branch,
+// covering prs. It's bumped as much as the biggest of their bumps would be.
+func (c *client) decideAggregate(ctx context.Context, cfg *config, last *version.Version, previous, ref string, prs []*github.PullRequest) decision {
+	d := decision{ref: ref, previous: previous, prs: prs}
+
+	ok, ch := c.shouldTag(ctx, cfg, previous, ref)
+	if !ok {
+		d.skip = skipNoChanges
+		return d
+	}
+
+	b, reasons := bumpPatch, []string{}
+	for _, pr := range prs {
+		pb, reason := c.bumpLevel(ctx, cfg, pr, pr.GetMergeCommitSHA(), ch)
+		if pb > b {
+			b = pb
+		}
+		if pb > bumpPatch {
+			reasons = append(reasons, fmt.Sprintf("#%d: %s", pr.GetNumber(), reason))
+		}
+	}
+
+	next := bumpVersion(cfg, last, b)
+	d.reason = fmt.Sprintf("%s bump for %d pull requests", b, len(prs))
+	if len(reasons) > 0 {
+		d.reason += " (" + strings.Join(reasons, ", ") + ")"
+	}
+	if floor, ok := floorTag(cfg, last, next); ok {
+		next, d.reason = floor, d.reason+", raised to MIN_VERSION"
+	}
+	tracef("Bumping %s to %s: %s", previous, next, d.reason)
+
+	d.version, d.action = c.resolveConflict(ctx, next, ref, cfg.prefix, cfg.conflict)
+	if d.action == tagSkip {
+		d.skip = skipConflict
+	}
+	return d
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+func Test_decideAggregate(t *testing.T) {
+	pr := func(n int, label string) *github.PullRequest {
+		p := &github.PullRequest{Number: github.Int(n), MergeCommitSHA: github.String("merge")}
+		if label != "" {
+			p.Labels = []*github.Label{{Name: github.String(label)}}
+		}
+		return p
+	}
+	compare := func(files ...string) *github.CommitsComparison {
+		cmp := &github.CommitsComparison{}
+		for _, f := range files {
+			cmp.Files = append(cmp.Files, github.CommitFile{Filename: github.String(f), Status: github.String("modified")})
+		}
+		return cmp
+	}
+
+	tests := []struct {
+		name   string
+		files  []string
+		prs    []*github.PullRequest
+		want   string
+		skip   string
+		reason string
+	}{
+		{"all patches", []string{"main.go"}, []*github.PullRequest{pr(1, ""), pr(2, "")}, "v1.2.1", "", "patch bump for 2 pull requests"},
+		{"biggest wins", []string{"main.go"}, []*github.PullRequest{pr(1, ""), pr(2, "minor"), pr(3, "")}, "v1.3.0", "", "#2: minor bump"},
+		{"no matching changes", []string{"README.md"}, []*github.PullRequest{pr(1, "major")}, "", skipNoChanges, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, done := newTestClient(t, nil, map[string]interface{}{
+				"GET /repos/o/r/compare/v1.2.0...head": compare(tt.files...),
+			})
+			defer done()
+
+			cfg := &config{scheme: semverScheme{}, fileMatch: regexp.MustCompile(`\.go$`)}
+			last := version.Must(version.NewSemver("v1.2.0"))
+			d := c.decideAggregate(context.Background(), cfg, last, "v1.2.0", "head", tt.prs)
+			if d.version != tt.want || d.skip != tt.skip {
+				t.Errorf("got %q skipped %q, want %q skipped %q", d.version, d.skip, tt.want, tt.skip)
+			}
+			if !strings.Contains(d.reason, tt.reason) {
+				t.Errorf("got reason %q, want it to mention %q", d.reason, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// apiChange is what apidiff makes of the changes to a Go module's API.
+type apiChange struct {
+	bump    bump
+	summary string
+}
+
+// parseAPIDiff reads apidiff's report: any incompatible change is a major
+// bump, compatible ones (additions) a minor one, and no change a patch.
+func parseAPIDiff(report string) apiChange {
+	var incompatible, compatible int
+	var counter *int
+	s := bufio.NewScanner(strings.NewReader(report))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case strings.HasPrefix(line, "Incompatible changes:"):
+			counter = &incompatible
+		case strings.HasPrefix(line, "Compatible changes:"):
+			counter = &compatible
+		case strings.HasPrefix(line, "- ") && counter != nil:
+			*counter++
+		case line != "" && !strings.HasPrefix(line, "- "):
+			// a package header in module mode
+			counter = nil
+		}
+	}
+
+	switch {
+	case incompatible > 0:
+		return apiChange{bumpMajor, fmt.Sprintf("%d incompatible API changes", incompatible)}
+	case compatible > 0:
+		return apiChange{bumpMinor, fmt.Sprintf("%d compatible API changes", compatible)}
+	}
+	return apiChange{bumpPatch, "no API changes"}
+}
+
+// modulePath returns the module path declared in a go.mod file.
+func modulePath(gomod []byte) string {
+	s := bufio.NewScanner(bytes.NewReader(gomod))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`)
+		}
+	}
+	return ""
+}
+
+// apiDiff compares the exported API of the Go module in dir, relative to
+// the workspace, between the base and merge revisions. Both are checked out
+// in temporary worktrees, so the workspace needs the full history (fetch-depth:
+// 0), and apidiff (golang.org/x/exp/cmd/apidiff) has to be installed.
+func apiDiff(dir, base, merge string) (*apiChange, error) {
+	if err := needTools("APIDIFF", "git", "apidiff"); err != nil {
+		return nil, err
+	}
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	tmp, err := ioutil.TempDir("", "autotagger-apidiff")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	defer git(workspace, "worktree", "prune")
+
+	oldDir, err := worktree(workspace, filepath.Join(tmp, "old"), base)
+	if err != nil {
+		return nil, err
+	}
+	newDir, err := worktree(workspace, filepath.Join(tmp, "new"), merge)
+	if err != nil {
+		return nil, err
+	}
+	oldDir, newDir = filepath.Join(oldDir, dir), filepath.Join(newDir, dir)
+
+	gomod, err := ioutil.ReadFile(filepath.Join(newDir, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	mod := modulePath(gomod)
+	if mod == "" {
+		return nil, fmt.Errorf("no module path in %s/go.mod", dir)
+	}
+
+	export := filepath.Join(tmp, "old.api")
+	if out, err := run(oldDir, "apidiff", "-m", "-w", export, mod); err != nil {
+		return nil, fmt.Errorf("could not read the API of %s at %s: %v: %s", mod, base, err, out)
+	}
+	out, err := run(newDir, "apidiff", "-m", export, mod)
+	if err != nil {
+		return nil, fmt.Errorf("could not compare the API of %s: %v: %s", mod, err, out)
+	}
+
+	tracef("API changes of %s since %s:\n%s", mod, base, out)
+	ch := parseAPIDiff(out)
+	return &ch, nil
+}
+
+// worktree checks out rev of the repository in workspace at dir, returning
+// dir. It's removed along with dir by git worktree prune.
+func worktree(workspace, dir, rev string) (string, error) {
+	if out, err := git(workspace, "worktree", "add", "--detach", dir, rev); err != nil {
+		return "", fmt.Errorf("could not check out %s: %v: %s", rev, err, out)
+	}
+	return dir, nil
+}
+
+// needTools returns an error naming setting unless each of tools is on the
+// PATH. Everything else is done in-process, so these are the only features
+// needing more than the autotagger binary, on any OS.
+func needTools(setting string, tools ...string) error {
+	var missing []string
+	for _, t := range tools {
+		if _, err := exec.LookPath(t); err != nil {
+			missing = append(missing, t)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%s needs %s on the PATH, which isn't there", setting, strings.Join(missing, " and "))
+	}
+	return nil
+}
+
+// git runs git in dir.
+func git(dir string, args ...string) (string, error) {
+	return run(dir, "git", args...)
+}
+
+// run runs a command in dir and returns its combined output.
+func run(dir, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
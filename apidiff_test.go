@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_parseAPIDiff(t *testing.T) {
+	tests := map[string]bump{
+		"": bumpPatch,
+		"Compatible changes:\n- NewThing: added\n":                                          bumpMinor,
+		"Incompatible changes:\n- Old: removed\n\nCompatible changes:\n- NewThing: added\n": bumpMajor,
+		"example.com/m/a\nIncompatible changes:\n- F: changed from func() to func(int)\n":   bumpMajor,
+		"example.com/m/a\nCompatible changes:\n- G: added\nexample.com/m/b\n":               bumpMinor,
+	}
+
+	for report, want := range tests {
+		t.Run(report, func(t *testing.T) {
+			if got := parseAPIDiff(report); got.bump != want {
+				t.Errorf("got %v (%s), want %v", got.bump, got.summary, want)
+			}
+		})
+	}
+}
+
+func Test_modulePath(t *testing.T) {
+	gomod := "// a comment\nmodule \"github.com/manifoldco/autotagger\"\n\ngo 1.13\n"
+	if got := modulePath([]byte(gomod)); got != "github.com/manifoldco/autotagger" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func Test_needTools(t *testing.T) {
+	if err := needTools("APIDIFF", "go"); err != nil {
+		t.Errorf("got %v for go, which runs the tests", err)
+	}
+	err := needTools("APIDIFF", "go", "autotagger-missing-tool")
+	if err == nil || !strings.Contains(err.Error(), "APIDIFF needs autotagger-missing-tool") {
+		t.Errorf("got %v, want it to name the missing tool", err)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// approvalTask is the task of the deployments asking for a release to be
+// approved, for the paired workflow to pick up.
+const approvalTask = "autotagger:approve"
+
+// approvalGate holds back the release in d until it's approved in the
+// protected APPROVAL_ENVIRONMENT. It asks for approval with a deployment of
+// the merge commit to the environment, which a workflow listening to
+// deployment events and running a job in that environment marks successful
+// once reviewers let the job run. It returns why the release is blocked, or
+// "" once it's approved.
+func (c *client) approvalGate(ctx context.Context, cfg *config, d decision) string {
+	dep, err := c.findApproval(ctx, cfg.approvalEnv, d)
+	if err != nil {
+		fatalf("could not list deployments of %s: %v", d.ref, err)
+	}
+
+	if dep == nil {
+		dep, _, err = c.c.Repositories.CreateDeployment(ctx, c.owner, c.repo, &github.DeploymentRequest{
+			Ref:              github.String(d.ref),
+			Task:             github.String(approvalTask),
+			AutoMerge:        github.Bool(false),
+			RequiredContexts: &[]string{},
+			Payload:          map[string]string{"version": d.version},
+			Environment:      github.String(cfg.approvalEnv),
+			Description:      github.String("Approve release " + d.version),
+		})
+		if err != nil {
+			fatalf("could not ask for approval in %s: %v", cfg.approvalEnv, err)
+		}
+		tracef("Asked for approval of %s in %s (deployment %d)", d.version, cfg.approvalEnv, dep.GetID())
+	}
+
+	statuses, _, err := c.c.Repositories.ListDeploymentStatuses(ctx, c.owner, c.repo, dep.GetID(), nil)
+	if err != nil {
+		fatalf("could not get statuses of deployment %d: %v", dep.GetID(), err)
+	}
+	return approvalReason(cfg.approvalEnv, dep.GetID(), statuses)
+}
+
+// findApproval returns the deployment asking for approval of d's release
+// in environment, or nil if there's none yet.
+func (c *client) findApproval(ctx context.Context, environment string, d decision) (*github.Deployment, error) {
+	deps, _, err := c.c.Repositories.ListDeployments(ctx, c.owner, c.repo, &github.DeploymentsListOptions{
+		SHA:         d.ref,
+		Task:        approvalTask,
+		Environment: environment,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// newest first, a new version needs approval again
+	for _, dep := range deps {
+		var payload struct {
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(dep.Payload, &payload) == nil && payload.Version == d.version {
+			return dep, nil
+		}
+	}
+	return nil, nil
+}
+
+// approvalReason says why the approval deployment id with statuses, newest
+// first, blocks the release, or "" when it's approved.
+func approvalReason(environment string, id int64, statuses []*github.DeploymentStatus) string {
+	state := "pending"
+	if len(statuses) > 0 {
+		state = statuses[0].GetState()
+	}
+
+	switch state {
+	case "success":
+		return ""
+	case "failure", "error":
+		return fmt.Sprintf("approval in %s failed (deployment %d is %s)", environment, id, state)
+	default:
+		return fmt.Sprintf("waiting for approval in %s (deployment %d)", environment, id)
+	}
+}
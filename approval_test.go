@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func Test_approvalReason(t *testing.T) {
+	status := func(state string) *github.DeploymentStatus {
+		return &github.DeploymentStatus{State: github.String(state)}
+	}
+
+	tests := []struct {
+		name     string
+		statuses []*github.DeploymentStatus
+		blocked  bool
+	}{
+		{"no status", nil, true},
+		{"approved", []*github.DeploymentStatus{status("success"), status("in_progress")}, false},
+		{"running", []*github.DeploymentStatus{status("in_progress")}, true},
+		{"failed", []*github.DeploymentStatus{status("failure")}, true},
+		{"approved again", []*github.DeploymentStatus{status("success"), status("failure")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := approvalReason("production", 7, tt.statuses); (got != "") != tt.blocked {
+				t.Errorf("got %q, want blocked %v", got, tt.blocked)
+			}
+		})
+	}
+}
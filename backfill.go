@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// backfillCmd catches up on releases that were missed while the action was
+// broken or disabled. Without -create it only reports what it would do.
+func backfillCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	create := fs.Bool("create", false, "create the tags instead of only reporting them")
+	notes := fs.Bool("notes", false, "also show the release notes of the tags it would create")
+	branch := fs.String("branch", "", "base branch of the pull requests (default: the repository's default branch)")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	if *create && cfg.freeze {
+		fmt.Println("Releases are frozen, not backfilling")
+		exitSkipped("frozen")
+	}
+
+	if err := cli.backfill(ctx, cfg, *branch, *create, *notes); err != nil {
+		fatal(err)
+	}
+}
+
+// backfill goes through the pull requests merged into branch since the last
+// tag, oldest first, and tags each one the way the action would have, or
+// only reports it when create is false, with the release notes when notes
+// is set. It stops at the first release held back, since the later ones
+// build on it. An empty branch means the repository's default branch.
+func (c *client) backfill(ctx context.Context, cfg *config, branch string, create, notes bool) error {
+	if branch == "" {
+		r, _, err := c.c.Repositories.Get(ctx, c.owner, c.repo)
+		if err != nil {
+			return fmt.Errorf("could not get repository: %v", err)
+		}
+		branch = r.GetDefaultBranch()
+	}
+
+	cfg.useBranch(branch)
+	last, previous, err := c.lastRelease(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	if previous == "" {
+		// Bootstrapping would tag every PR ever merged.
+		return fmt.Errorf("no versions under %q yet, tag the first release before backfilling", cfg.prefix)
+	}
+
+	since, err := c.commitDate(ctx, previous)
+	if err != nil {
+		return fmt.Errorf("could not get date of %s: %v", previous, err)
+	}
+	fmt.Printf("Last version is %s, from %s\n", previous, since.Format(time.RFC3339))
+
+	prs, err := c.mergedSince(ctx, branch, since)
+	if err != nil {
+		return fmt.Errorf("could not list pull requests: %v", err)
+	}
+	fmt.Printf("Found %d pull requests merged into %s since then\n", len(prs), branch)
+
+	// When only reporting, the tags don't exist, so changes are looked up
+	// since the previous merge commit instead.
+	base := previous
+	tagged := 0
+	var newest *github.PullRequest
+	if create {
+		cfg.useDigest(len(prs))
+	}
+	for _, pr := range prs {
+		ref := pr.GetMergeCommitSHA()
+		fmt.Printf("\n#%d %s (%s)\n", pr.GetNumber(), pr.GetTitle(), ref)
+
+		d := c.decide(ctx, cfg, pr, last, previous, base, ref)
+		if d.skip != "" {
+			fmt.Printf("Would skip: %s\n", d.skip)
+			continue
+		}
+
+		if create && d.action != tagExists {
+			if reason := c.held(ctx, cfg, d, pr); reason != "" {
+				fmt.Printf("Stopping at %s: %s\n", d.version, reason)
+				break
+			}
+		}
+		if create {
+			c.apply(ctx, cfg, d, pr)
+			newest = pr
+		} else {
+			fmt.Printf("Would tag %s as %s\n", ref, d.version)
+			if notes {
+				n, _ := c.notesFor(ctx, cfg, d, []*github.PullRequest{pr})
+				fmt.Printf("\n%s\n", n)
+			}
+		}
+		tagged++
+
+		v, err := version.NewSemver(strings.TrimPrefix(d.version, cfg.prefix))
+		if err != nil {
+			return fmt.Errorf("could not parse tag %s: %v", d.version, err)
+		}
+		last, previous, base = v, d.version, d.version
+		if !create {
+			base = ref
+		}
+	}
+
+	if create {
+		if newest != nil {
+			c.sendDigest(ctx, cfg, newest)
+		}
+		fmt.Printf("\nTagged %d of %d pull requests\n", tagged, len(prs))
+	} else {
+		fmt.Printf("\n%d of %d pull requests would be tagged, run with -create to tag them\n", tagged, len(prs))
+	}
+	return nil
+}
+
+// held returns why the catch-up release d of pr can't go out now, as the
+// action would have held it back: an unconfirmed major bump with
+// CONFIRM_MAJOR, or a release gate. Gated releases are queued for autotagger
+// flush. It returns "" if nothing holds it.
+func (c *client) held(ctx context.Context, cfg *config, d decision, pr *github.PullRequest) string {
+	if cfg.confirmMajor && isMajorBump(d.previous, d.version, cfg.prefix) {
+		ok, err := c.majorConfirmed(ctx, cfg, pr.GetNumber())
+		if err != nil {
+			fatalf("could not check confirmation of PR #%d: %v", pr.GetNumber(), err)
+		}
+		if !ok {
+			return fmt.Sprintf("the major bump of PR #%d isn't confirmed", pr.GetNumber())
+		}
+	}
+	if reason := c.gate(ctx, cfg, d); reason != "" {
+		if err := c.enqueue(ctx, pr.GetNumber()); err != nil {
+			fatalf("could not queue release: %v", err)
+		}
+		return reason + ", queued it for autotagger flush"
+	}
+	return ""
+}
+
+// newRepoClient creates a client for the GITHUB_REPOSITORY, for commands that
+// don't run off a Github event.
+func newRepoClient(ctx context.Context) *client {
+	full := os.Getenv("GITHUB_REPOSITORY")
+	parts := strings.Split(full, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		fatalf("GITHUB_REPOSITORY must be set to owner/repo, got %q", full)
+	}
+
+	server := os.Getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+
+	return &client{c: newGithubClient(ctx), owner: parts[0], repo: parts[1], url: server + "/" + full}
+}
+
+// commitDate returns the committer date of the commit ref points at.
+func (c *client) commitDate(ctx context.Context, ref string) (time.Time, error) {
+	if c.release != nil {
+		return c.release.commitDate(ctx, ref)
+	}
+	commit, _, err := c.c.Repositories.GetCommit(ctx, c.owner, c.repo, ref)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return commit.GetCommit().GetCommitter().GetDate(), nil
+}
+
+// mergedSince returns the pull requests merged into branch after since,
+// oldest first.
+func (c *client) mergedSince(ctx context.Context, branch string, since time.Time) ([]*github.PullRequest, error) {
+	opt := &github.PullRequestListOptions{
+		State:       "closed",
+		Base:        branch,
+		Sort:        "updated",
+		Direction:   "desc",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var merged []*github.PullRequest
+	for {
+		prs, resp, err := c.c.PullRequests.List(ctx, c.owner, c.repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		done := false
+		for _, pr := range prs {
+			// a PR is updated when it's merged, so there's nothing newer
+			// past this one
+			if pr.GetUpdatedAt().Before(since) {
+				done = true
+				break
+			}
+			if pr.MergedAt != nil && pr.GetMergedAt().After(since) {
+				merged = append(merged, pr)
+			}
+		}
+
+		if done || resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].GetMergedAt().Before(merged[j].GetMergedAt())
+	})
+	return merged, nil
+}
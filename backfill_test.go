@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func Test_held(t *testing.T) {
+	minor := decision{ref: "merge", previous: "v1.2.0", version: "v1.3.0"}
+	major := decision{ref: "merge", previous: "v1.2.0", version: "v2.0.0"}
+	status := func(state string) *github.CombinedStatus {
+		return &github.CombinedStatus{State: github.String(state), TotalCount: github.Int(1)}
+	}
+
+	tests := []struct {
+		name   string
+		cfg    config
+		d      decision
+		status *github.CombinedStatus
+		want   string // in the reason, "" for none
+		queued bool
+	}{
+		{"clear", config{requireStatus: true}, minor, status("success"), "", false},
+		{"gated", config{requireStatus: true}, minor, status("failure"), "commit status of merge is failure", true},
+		{"unconfirmed major", config{confirmMajor: true, breakingLabel: "confirmed-breaking"}, major, nil, "isn't confirmed", false},
+		{"minor with confirm major", config{confirmMajor: true, breakingLabel: "confirmed-breaking"}, minor, nil, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queued := false
+			c, done := newTestClient(t, nil, map[string]interface{}{
+				"GET /repos/o/r/commits/merge/status": tt.status,
+				"GET /repos/o/r/issues/7/labels":      []*github.Label{},
+				"GET /repos/o/r/issues/7/comments":    []*github.IssueComment{},
+				"GET /repos/o/r/issues":               []*github.Issue{},
+				"POST /repos/o/r/issues": func(w http.ResponseWriter, r *http.Request) {
+					queued = true
+					w.WriteHeader(http.StatusCreated)
+					w.Write([]byte(`{"number": 1}`))
+				},
+			})
+			defer done()
+
+			pr := &github.PullRequest{Number: github.Int(7)}
+			got := c.held(context.Background(), &tt.cfg, tt.d, pr)
+			if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if queued != tt.queued {
+				t.Errorf("got queued %v, want %v", queued, tt.queued)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// maintenanceBranch returns the release branch to cut for tag, like
+// release/v1.2 for v1.2.0, or "" if tag doesn't start a maintenance line:
+// patch releases and prereleases don't.
+func maintenanceBranch(tag, prefix string) string {
+	v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+	if err != nil || v.Prerelease() != "" {
+		return ""
+	}
+	s := v.Segments()
+	if s[2] != 0 {
+		return ""
+	}
+	return fmt.Sprintf("release/%s%s%d.%d", prefix, vPrefix, s[0], s[1])
+}
+
+// cutBranch creates the maintenance branch for d's version at its commit,
+// for hotfixes to be released from later. A branch that's already there is
+// left alone.
+func (c *client) cutBranch(ctx context.Context, cfg *config, d decision) error {
+	branch := maintenanceBranch(d.version, cfg.prefix)
+	if branch == "" {
+		return nil
+	}
+
+	_, resp, err := c.c.Git.CreateRef(ctx, c.owner, c.repo, &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(d.ref)},
+	})
+	if resp != nil && resp.StatusCode == http.StatusUnprocessableEntity {
+		tracef("Branch %s already exists, leaving it alone", branch)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	tracef("Cut branch %s", branch)
+	return nil
+}
+
+// releaseLine is the maintenance line of a release branch: the versions
+// with its major number and, unless it's -1, its minor number.
+type releaseLine struct {
+	major, minor int
+}
+
+// lineBranchRE matches maintenance branches, like release/1.4,
+// release/api/v1.4 or release/v1.x.
+var lineBranchRE = regexp.MustCompile(`^release/(.*?)v?(\d+)\.(\d+|x)$`)
+
+// branchLine returns the maintenance line of branch under prefix, like 1.4
+// for release/v1.4 or release/1.4 and 1.x for release/v1.x, or nil when it
+// isn't a maintenance branch of the prefix.
+func branchLine(branch, prefix string) *releaseLine {
+	m := lineBranchRE.FindStringSubmatch(branch)
+	if m == nil || m[1] != prefix {
+		return nil
+	}
+	l := &releaseLine{minor: -1}
+	l.major, _ = strconv.Atoi(m[2])
+	if m[3] != "x" {
+		l.minor, _ = strconv.Atoi(m[3])
+	}
+	return l
+}
+
+func (l *releaseLine) String() string {
+	if l.minor < 0 {
+		return fmt.Sprintf("%d.x", l.major)
+	}
+	return fmt.Sprintf("%d.%d", l.major, l.minor)
+}
+
+// has reports whether v is on the line.
+func (l *releaseLine) has(v *version.Version) bool {
+	s := v.Segments()
+	return s[0] == l.major && (l.minor < 0 || s[1] == l.minor)
+}
+
+// maxBump is the biggest bump staying on the line.
+func (l *releaseLine) maxBump() bump {
+	if l.minor < 0 {
+		return bumpMinor
+	}
+	return bumpPatch
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-version"
+)
+
+func Test_maintenanceBranch(t *testing.T) {
+	tests := []struct {
+		tag, prefix, want string
+	}{
+		{"v1.2.0", "", "release/v1.2"},
+		{"v2.0.0", "", "release/v2.0"},
+		{"v1.2.3", "", ""},
+		{"v1.3.0-rc.1", "", ""},
+		{"api/v0.4.0", "api/", "release/api/v0.4"},
+		{"nonsense", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			if got := maintenanceBranch(tt.tag, tt.prefix); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_branchLine(t *testing.T) {
+	tests := []struct {
+		branch, prefix string
+		want           string // "" for none
+		maxBump        bump
+	}{
+		{"release/1.4", "", "1.4", bumpPatch},
+		{"release/v1.4", "", "1.4", bumpPatch},
+		{"release/v1.x", "", "1.x", bumpMinor},
+		{"release/api/v0.4", "api/", "0.4", bumpPatch},
+		{"release/api/v0.4", "", "", 0},
+		{"release/v1.4", "api/", "", 0},
+		{"main", "", "", 0},
+		{"release/next", "", "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.branch+" "+tt.prefix, func(t *testing.T) {
+			l := branchLine(tt.branch, tt.prefix)
+			if tt.want == "" {
+				if l != nil {
+					t.Errorf("got line %s, want none", l)
+				}
+				return
+			}
+			if l == nil || l.String() != tt.want || l.maxBump() != tt.maxBump {
+				t.Errorf("got %v, want line %s capped at %s", l, tt.want, tt.maxBump)
+			}
+		})
+	}
+}
+
+func Test_releaseLine_has(t *testing.T) {
+	minor, major := &releaseLine{1, 4}, &releaseLine{1, -1}
+	tests := []struct {
+		v            string
+		minor, major bool
+	}{
+		{"v1.4.7", true, true},
+		{"v1.4.8-rc.1", true, true},
+		{"v1.5.0", false, true},
+		{"v2.3.0", false, false},
+		{"v0.4.0", false, false},
+	}
+
+	for _, tt := range tests {
+		v := version.Must(version.NewSemver(tt.v))
+		if got := minor.has(v); got != tt.minor {
+			t.Errorf("1.4 line has %s: got %v", tt.v, got)
+		}
+		if got := major.has(v); got != tt.major {
+			t.Errorf("1.x line has %s: got %v", tt.v, got)
+		}
+	}
+}
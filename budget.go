@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// budget caps how much a single run may do against the API, so pointing
+// autotagger at a repository with 100k tags can't eat the org's rate limit.
+// A limit of 0 means unlimited.
+type budget struct {
+	mu sync.Mutex
+
+	maxPages     int
+	maxTags      int
+	maxMutations int
+
+	pages     int
+	tags      int
+	mutations int
+}
+
+// runBudget is the budget for this run, set up with the API client.
+var runBudget = &budget{}
+
+// newBudget reads the limits from MAX_PAGES, MAX_TAGS and MAX_MUTATIONS.
+func newBudget() *budget {
+	b := &budget{}
+	for env, limit := range map[string]*int{
+		"MAX_PAGES":     &b.maxPages,
+		"MAX_TAGS":      &b.maxTags,
+		"MAX_MUTATIONS": &b.maxMutations,
+	} {
+		v, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			fatalf("invalid %s %q: must be a number of 0 or more", env, v)
+		}
+		*limit = n
+	}
+	return b
+}
+
+// spend counts one more of something, returning an error once it goes over
+// max.
+func (b *budget) spend(count *int, max int, what, env string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	*count++
+	if max > 0 && *count > max {
+		return fmt.Errorf("API budget exceeded: more than %d %s in this run, stopping (raise %s to allow more)", max, what, env)
+	}
+	return nil
+}
+
+// page counts a fetched page, a read request.
+func (b *budget) page() error {
+	return b.spend(&b.pages, b.maxPages, "pages fetched", "MAX_PAGES")
+}
+
+// tag counts a scanned tag.
+func (b *budget) tag() error {
+	return b.spend(&b.tags, b.maxTags, "tags scanned", "MAX_TAGS")
+}
+
+// mutation counts a write request.
+func (b *budget) mutation() error {
+	return b.spend(&b.mutations, b.maxMutations, "mutations", "MAX_MUTATIONS")
+}
+
+// budgetTransport charges every API request to a budget before sending it,
+// refusing the request when the budget is spent.
+type budgetTransport struct {
+	base http.RoundTripper
+	b    *budget
+}
+
+func (t *budgetTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var err error
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		err = t.b.page()
+	default:
+		err = t.b.mutation()
+	}
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
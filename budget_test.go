@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func Test_budget(t *testing.T) {
+	tests := map[string]struct {
+		max   int
+		spend int
+		fail  bool
+	}{
+		"unlimited":   {0, 100, false},
+		"under limit": {3, 2, false},
+		"at limit":    {3, 3, false},
+		"over limit":  {3, 4, true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			b := &budget{maxTags: tc.max}
+			var err error
+			for i := 0; i < tc.spend && err == nil; i++ {
+				err = b.tag()
+			}
+			if (err != nil) != tc.fail {
+				t.Errorf("got error %v, want failure %v", err, tc.fail)
+			}
+		})
+	}
+}
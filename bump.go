@@ -0,0 +1,389 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// bump is which part of the version a release increments.
+type bump int
+
+const (
+	bumpPatch bump = iota
+	bumpMinor
+	bumpMajor
+)
+
+func (b bump) String() string {
+	switch b {
+	case bumpMajor:
+		return "major"
+	case bumpMinor:
+		return "minor"
+	default:
+		return "patch"
+	}
+}
+
+// parseBump parses a bump level name.
+func parseBump(s string) (bump, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "major":
+		return bumpMajor, nil
+	case "minor":
+		return bumpMinor, nil
+	case "patch":
+		return bumpPatch, nil
+	}
+	return bumpPatch, fmt.Errorf("invalid bump %q, expected major, minor or patch", s)
+}
+
+// keyword is a word in PR titles asking for a bump.
+type keyword struct {
+	word string
+	bump bump
+}
+
+// defaultTitleKeywords are used by BUMP_FROM_TITLE without TITLE_KEYWORDS.
+const defaultTitleKeywords = "[major]=major,breaking=major,[minor]=minor,feat:=minor,feat(=minor"
+
+// parseKeywords parses a comma-separated list of keyword=bump pairs, e.g.
+// "[major]=major,feat:=minor".
+func parseKeywords(s string) ([]keyword, error) {
+	var kws []keyword
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 || strings.TrimSpace(pair[:i]) == "" {
+			return nil, fmt.Errorf("invalid keyword %q, expected keyword=bump", pair)
+		}
+		b, err := parseBump(pair[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		kws = append(kws, keyword{word: strings.ToLower(strings.TrimSpace(pair[:i])), bump: b})
+	}
+	return kws, nil
+}
+
+// titleBump returns the biggest bump asked for by the keywords in title,
+// ignoring case, and the keyword asking for it. It returns "" when there
+// isn't any.
+func titleBump(title string, kws []keyword) (bump, string) {
+	title = strings.ToLower(title)
+	b, word := bumpPatch, ""
+	for _, kw := range kws {
+		if strings.Contains(title, kw.word) && (word == "" || kw.bump > b) {
+			b, word = kw.bump, kw.word
+		}
+	}
+	return b, word
+}
+
+// conventionalRE matches a Conventional Commits header, e.g. "feat(api)!: x".
+var conventionalRE = regexp.MustCompile(`^(\w+)(\([^)]*\))?(!)?: `)
+
+// messageBump derives the bump from a commit message using Conventional
+// Commits: a breaking change is major, a feat is minor and anything else is
+// a patch. Squash merge messages list the squashed commits as "* " bullets
+// after the title, so every line gets a say and the biggest bump wins.
+func messageBump(msg string) bump {
+	b := bumpPatch
+	for _, line := range strings.Split(msg, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+			return bumpMajor
+		}
+
+		m := conventionalRE.FindStringSubmatch(strings.TrimPrefix(line, "* "))
+		switch {
+		case m == nil:
+		case m[3] == "!":
+			return bumpMajor
+		case strings.EqualFold(m[1], "feat"):
+			b = bumpMinor
+		}
+	}
+	return b
+}
+
+// squashSettings are how a repository composes squash merge messages.
+type squashSettings struct {
+	Title   string `json:"squash_merge_commit_title"`   // PR_TITLE or COMMIT_OR_PR_TITLE
+	Message string `json:"squash_merge_commit_message"` // PR_BODY, COMMIT_MESSAGES or BLANK
+}
+
+// squashCache holds the squash settings by repository, as they're the same
+// for every pull request.
+var squashCache = map[string]squashSettings{}
+
+// getSquashSettings returns the repository's squash merge message settings.
+// go-github doesn't know about them yet, so they're read from the raw
+// repository. Tokens that can't see them get the defaults.
+func (c *client) getSquashSettings(ctx context.Context) (squashSettings, error) {
+	full := c.owner + "/" + c.repo
+	if s, ok := squashCache[full]; ok {
+		return s, nil
+	}
+
+	req, err := c.c.NewRequest("GET", "repos/"+full, nil)
+	if err != nil {
+		return squashSettings{}, err
+	}
+	var s squashSettings
+	if _, err := c.c.Do(ctx, req, &s); err != nil {
+		return squashSettings{}, err
+	}
+	if s.Message == "" {
+		s.Message = "COMMIT_MESSAGES"
+	}
+	squashCache[full] = s
+	return s, nil
+}
+
+// squashText returns the part of a squash merge message worth reading for
+// the bump, given how the repository composes them: the squashed commits'
+// messages are, but a PR description isn't, except for its BREAKING CHANGE
+// footers, as its bullet points are prose rather than commits.
+func squashText(msg, composition string) string {
+	switch composition {
+	case "BLANK":
+		return strings.SplitN(msg, "\n", 2)[0]
+	case "PR_BODY":
+		lines := strings.Split(msg, "\n")
+		keep := lines[:1]
+		for _, line := range lines[1:] {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "BREAKING CHANGE:") || strings.HasPrefix(line, "BREAKING-CHANGE:") {
+				keep = append(keep, line)
+			}
+		}
+		return strings.Join(keep, "\n")
+	}
+	return msg
+}
+
+// labelBump returns the bump asked for by a major, minor or patch label,
+// the biggest one winning, and whether there was one.
+func labelBump(labels []*github.Label) (bump, bool) {
+	switch {
+	case hasLabel(labels, "major"):
+		return bumpMajor, true
+	case hasLabel(labels, "minor"):
+		return bumpMinor, true
+	case hasLabel(labels, "patch"):
+		return bumpPatch, true
+	}
+	return bumpPatch, false
+}
+
+// directiveRE matches a #major, #minor, #patch or #none token in a PR body.
+var directiveRE = regexp.MustCompile(`(?i)(?:^|\s)#(major|minor|patch|none)\b`)
+
+// bodyDirective returns the bump directive in a PR body, lowercased, or ""
+// if there's none. With several, the first one counts.
+func bodyDirective(body string) string {
+	m := directiveRE.FindStringSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+// pathRule is a BUMP_PATHS rule: changes to files matching pattern (as
+// matchesPath does) ask for bump.
+type pathRule struct {
+	pattern string
+	bump    bump
+}
+
+// parsePathRules parses a comma-separated list of pattern=bump pairs, e.g.
+// "api/=minor,internal/=patch".
+func parsePathRules(s string) ([]pathRule, error) {
+	var rules []pathRule
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid rule %q, expected pattern=bump", pair)
+		}
+		b, err := parseBump(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, pathRule{pattern: strings.TrimSpace(parts[0]), bump: b})
+	}
+	return rules, nil
+}
+
+// pathBump is the bump asked for by the changed files.
+type pathBump struct {
+	bump bump
+	file string // a file asking for it
+	rule string // the pattern it matched
+}
+
+// filesBump returns the biggest bump the rules ask for given the changed
+// files, each file going by the first rule it matches. It returns nil if
+// no file matches a rule.
+func filesBump(rules []pathRule, files []string) *pathBump {
+	var pb *pathBump
+	for _, f := range files {
+		for _, r := range rules {
+			if !matchesPath([]string{r.pattern}, f) {
+				continue
+			}
+			if pb == nil || r.bump > pb.bump {
+				pb = &pathBump{bump: r.bump, file: f, rule: r.pattern}
+			}
+			break
+		}
+	}
+	return pb
+}
+
+// capBump returns the loosest cap the rules put on the bump given the
+// changed files, when every one of them matches a rule, each file going by
+// the first rule it matches. It returns nil otherwise.
+func capBump(rules []pathRule, files []string) *pathBump {
+	var pb *pathBump
+	for _, f := range files {
+		matched := false
+		for _, r := range rules {
+			if !matchesPath([]string{r.pattern}, f) {
+				continue
+			}
+			if pb == nil || r.bump > pb.bump {
+				pb = &pathBump{bump: r.bump, file: f, rule: r.pattern}
+			}
+			matched = true
+			break
+		}
+		if !matched {
+			return nil
+		}
+	}
+	return pb
+}
+
+// changes is what the changes since the last release say about the bump.
+type changes struct {
+	files []string   // the changed files that matched
+	paths *pathBump  // nil unless a BUMP_PATHS rule matched
+	api   *apiChange // nil unless APIDIFF compared the APIs
+}
+
+// bumpLevel works out how big a release of ref, the merge commit of pr, is,
+// and why, keeping it within the bounds BUMP_FLOOR and BUMP_CAP put on the
+// changed files, under a major bump with CAP_MAJOR, and on the maintenance
+// line of the branch.
+func (c *client) bumpLevel(ctx context.Context, cfg *config, pr *github.PullRequest, ref string, ch changes) (bump, string) {
+	b, reason := c.signalBump(ctx, cfg, pr, ref, ch)
+
+	if f := filesBump(cfg.bumpFloor, ch.files); f != nil && f.bump > b {
+		b = f.bump
+		reason += fmt.Sprintf(", raised to %s by %s matching %s", b, f.file, f.rule)
+	}
+	if cp := capBump(cfg.bumpCap, ch.files); cp != nil && cp.bump < b {
+		b = cp.bump
+		reason += fmt.Sprintf(", capped at %s as every change matches BUMP_CAP", b)
+	}
+	if cfg.capMajor && b == bumpMajor {
+		b = bumpMinor
+		reason += ", made minor by CAP_MAJOR"
+	}
+	if l := cfg.line; l != nil && b > l.maxBump() {
+		b = l.maxBump()
+		reason += fmt.Sprintf(", capped at %s to stay on the %s maintenance line", b, l)
+	}
+	return b, reason
+}
+
+// signalBump works out how big a release of ref, the merge commit of pr, is,
+// and why, from what asks for a bump, a bump label first, as it's the most
+// explicit. ch is what the changes say, if they were looked at. Without any
+// signal it's a patch bump.
+func (c *client) signalBump(ctx context.Context, cfg *config, pr *github.PullRequest, ref string, ch changes) (bump, string) {
+	if b, ok := labelBump(pr.Labels); ok {
+		return b, fmt.Sprintf("%s bump from the PR's %s label", b, b)
+	}
+
+	if dir := bodyDirective(pr.GetBody()); dir != "" && dir != "none" {
+		b, _ := parseBump(dir)
+		return b, fmt.Sprintf("%s bump from #%s in the PR description", b, dir)
+	}
+
+	if b, word := titleBump(pr.GetTitle(), cfg.titleKeywords); word != "" {
+		return b, fmt.Sprintf("%s bump from %q in the PR title", b, word)
+	}
+
+	if p := ch.paths; p != nil {
+		return p.bump, fmt.Sprintf("%s bump from %s matching %s", p.bump, p.file, p.rule)
+	}
+
+	if ch.api != nil {
+		return ch.api.bump, fmt.Sprintf("%s bump from %s", ch.api.bump, ch.api.summary)
+	}
+
+	if cfg.bumpFromCommits {
+		b, err := c.commitsBump(ctx, pr.GetNumber())
+		if err != nil {
+			fatalf("could not list commits of PR #%d: %v", pr.GetNumber(), err)
+		}
+		return b, fmt.Sprintf("%s bump from the PR's commits", b)
+	}
+
+	if cfg.bumpFromCommit {
+		commit, _, err := c.c.Git.GetCommit(ctx, c.owner, c.repo, ref)
+		if err != nil {
+			fatalf("could not get merge commit %s: %v", ref, err)
+		}
+
+		// Merge commits only say "Merge pull request #N", it's the squashed
+		// (or lone rebased) commit that holds the composed message.
+		if len(commit.Parents) == 1 {
+			s, err := c.getSquashSettings(ctx)
+			if err != nil {
+				fatalf("could not get squash merge settings: %v", err)
+			}
+			b := messageBump(squashText(commit.GetMessage(), s.Message))
+			return b, fmt.Sprintf("%s bump from the merge commit message (composed from %s)", b, s.Message)
+		}
+		tracef("Merge commit %s isn't a squash merge, not reading its message", ref)
+	}
+
+	return bumpPatch, "patch bump"
+}
+
+// commitsBump derives the bump from the Conventional Commits of the pull
+// request numbered number, the biggest one winning.
+func (c *client) commitsBump(ctx context.Context, number int) (bump, error) {
+	b := bumpPatch
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		commits, resp, err := c.c.PullRequests.ListCommits(ctx, c.owner, c.repo, number, opt)
+		if err != nil {
+			return b, err
+		}
+		for _, commit := range commits {
+			if cb := messageBump(commit.GetCommit().GetMessage()); cb > b {
+				b = cb
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return b, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
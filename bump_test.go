@@ -0,0 +1,225 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func Test_messageBump(t *testing.T) {
+	tests := map[string]bump{
+		"Fix the thing (#12)":                              bumpPatch,
+		"fix: the thing (#12)":                             bumpPatch,
+		"feat: add a thing (#12)":                          bumpMinor,
+		"feat(api)!: drop v1 (#12)":                        bumpMajor,
+		"Add a thing (#12)\n\n* feat: add it\n* fix: oops": bumpMinor,
+		"Add a thing (#12)\n\nBREAKING CHANGE: it's gone":  bumpMajor,
+		"Add a thing (#12)\n\n* refactor!: rename it":      bumpMajor,
+		"Mention feat: in passing":                         bumpPatch,
+	}
+
+	for msg, want := range tests {
+		t.Run(msg, func(t *testing.T) {
+			if got := messageBump(msg); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func Test_labelBump(t *testing.T) {
+	tests := []struct {
+		labels []string
+		want   bump
+		ok     bool
+	}{
+		{labels: nil, want: bumpPatch, ok: false},
+		{labels: []string{"kind/bug"}, want: bumpPatch, ok: false},
+		{labels: []string{"patch"}, want: bumpPatch, ok: true},
+		{labels: []string{"Minor"}, want: bumpMinor, ok: true},
+		{labels: []string{"minor", "major"}, want: bumpMajor, ok: true},
+	}
+
+	for _, tc := range tests {
+		var labels []*github.Label
+		for _, l := range tc.labels {
+			labels = append(labels, &github.Label{Name: github.String(l)})
+		}
+
+		got, ok := labelBump(labels)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%v: got %s, %v, want %s, %v", tc.labels, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func Test_titleBump(t *testing.T) {
+	kws, err := parseKeywords(defaultTitleKeywords)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		title string
+		want  bump
+		word  string
+	}{
+		{title: "Fix the thing", want: bumpPatch, word: ""},
+		{title: "feat: add the thing", want: bumpMinor, word: "feat:"},
+		{title: "Feat(api): add the thing", want: bumpMinor, word: "feat("},
+		{title: "[MAJOR] drop v1", want: bumpMajor, word: "[major]"},
+		{title: "feat: breaking rename", want: bumpMajor, word: "breaking"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.title, func(t *testing.T) {
+			got, word := titleBump(tc.title, kws)
+			if got != tc.want || word != tc.word {
+				t.Errorf("got %s (%q), want %s (%q)", got, word, tc.want, tc.word)
+			}
+		})
+	}
+}
+
+func Test_parseKeywords(t *testing.T) {
+	kws, err := parseKeywords("[Major]=major, wip=patch,a=b=minor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []keyword{{"[major]", bumpMajor}, {"wip", bumpPatch}, {"a=b", bumpMinor}}
+	if !reflect.DeepEqual(kws, want) {
+		t.Errorf("got %v, want %v", kws, want)
+	}
+
+	for _, bad := range []string{"major", "=major", "x=huge"} {
+		if _, err := parseKeywords(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func Test_bodyDirective(t *testing.T) {
+	tests := map[string]string{
+		"":                            "",
+		"Fixes #12":                   "",
+		"Drops v1.\n\n#major":         "major",
+		"#Minor, adds the thing":      "minor",
+		"Docs only #none":             "none",
+		"see foo#major":               "",
+		"#patch now, #major some day": "patch",
+		"#majority of the changes":    "",
+	}
+
+	for body, want := range tests {
+		t.Run(body, func(t *testing.T) {
+			if got := bodyDirective(body); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func Test_filesBump(t *testing.T) {
+	rules, err := parsePathRules("api/v1/=patch, api/=minor,internal/=patch,*.proto=major")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		want  *pathBump
+	}{
+		{"no rule", []string{"main.go", "README.md"}, nil},
+		{"one rule", []string{"main.go", "internal/x.go"}, &pathBump{bumpPatch, "internal/x.go", "internal/"}},
+		{"biggest wins", []string{"internal/x.go", "api/y.go"}, &pathBump{bumpMinor, "api/y.go", "api/"}},
+		{"first rule counts", []string{"api/v1/z.go"}, &pathBump{bumpPatch, "api/v1/z.go", "api/v1/"}},
+		{"glob", []string{"api/y.go", "x.proto"}, &pathBump{bumpMajor, "x.proto", "*.proto"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filesBump(rules, tt.files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"api/", "=minor", "api/=huge"} {
+		if _, err := parsePathRules(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func Test_capBump(t *testing.T) {
+	rules, err := parsePathRules("internal/=patch,docs/=patch,cmd/=minor")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		files []string
+		want  *pathBump
+	}{
+		{"no files", nil, nil},
+		{"not all match", []string{"internal/x.go", "api/y.go"}, nil},
+		{"all match", []string{"internal/x.go", "docs/y.md"}, &pathBump{bumpPatch, "internal/x.go", "internal/"}},
+		{"loosest wins", []string{"internal/x.go", "cmd/main.go"}, &pathBump{bumpMinor, "cmd/main.go", "cmd/"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := capBump(rules, tt.files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_bumpLevel_capMajor(t *testing.T) {
+	label := func(name string) *github.PullRequest {
+		return &github.PullRequest{Labels: []*github.Label{{Name: github.String(name)}}}
+	}
+
+	tests := []struct {
+		name     string
+		pr       *github.PullRequest
+		capMajor bool
+		want     bump
+	}{
+		{"major", label("major"), false, bumpMajor},
+		{"major capped", label("major"), true, bumpMinor},
+		{"minor", label("minor"), true, bumpMinor},
+		{"patch", label("patch"), true, bumpPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config{capMajor: tt.capMajor}
+			if got, reason := (&client{}).bumpLevel(context.Background(), cfg, tt.pr, "abc123", changes{}); got != tt.want {
+				t.Errorf("got %s (%s), want %s", got, reason, tt.want)
+			}
+		})
+	}
+}
+
+func Test_squashText(t *testing.T) {
+	msg := "feat: add things (#12)\n\n* fix: a typo\n* feat!: drop the old API\n\nBREAKING CHANGE: it's gone"
+	tests := map[string]string{
+		"COMMIT_MESSAGES": msg,
+		"PR_BODY":         "feat: add things (#12)\nBREAKING CHANGE: it's gone",
+		"BLANK":           "feat: add things (#12)",
+	}
+
+	for composition, want := range tests {
+		t.Run(composition, func(t *testing.T) {
+			if got := squashText(msg, composition); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
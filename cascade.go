@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// parseGoMod returns the module path a go.mod declares, and the modules it
+// requires or is replacing others with.
+func parseGoMod(b string) (string, []string) {
+	var mod string
+	var deps []string
+	block := ""
+	for _, line := range strings.Split(b, "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		directive, args := block, fields
+		if block == "" {
+			directive, args = fields[0], fields[1:]
+			if len(args) == 1 && args[0] == "(" {
+				block = directive
+				continue
+			}
+		} else if fields[0] == ")" {
+			block = ""
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		switch directive {
+		case "module":
+			mod = strings.Trim(args[0], `"`)
+		case "require":
+			deps = append(deps, strings.Trim(args[0], `"`))
+		case "replace":
+			// replacing with a directory keeps the module required as it is
+			for i, a := range args {
+				if a == "=>" && i+1 < len(args) && !strings.HasPrefix(args[i+1], ".") && !strings.HasPrefix(args[i+1], "/") {
+					deps = append(deps, strings.Trim(args[i+1], `"`))
+				}
+			}
+		}
+	}
+	return mod, deps
+}
+
+// moduleDeps returns the prefixes of the modules each module of mods depends
+// on, by prefix: the prefixes listed in its depends, plus those of the
+// modules its go.mod requires. gomods holds the go.mod files, by prefix.
+func moduleDeps(mods []module, gomods map[string]string) map[string][]string {
+	byPath := map[string]string{}
+	requires := map[string][]string{}
+	for _, m := range mods {
+		if gm, ok := gomods[m.Prefix]; ok {
+			path, deps := parseGoMod(gm)
+			if path != "" {
+				byPath[path] = m.Prefix
+			}
+			requires[m.Prefix] = deps
+		}
+	}
+
+	deps := map[string][]string{}
+	for _, m := range mods {
+		seen := map[string]bool{m.Prefix: true}
+		add := func(p string) {
+			if !seen[p] {
+				seen[p] = true
+				deps[m.Prefix] = append(deps[m.Prefix], p)
+			}
+		}
+		for _, p := range m.Depends {
+			add(p)
+		}
+		for _, r := range requires[m.Prefix] {
+			if p, ok := byPath[r]; ok {
+				add(p)
+			}
+		}
+	}
+	return deps
+}
+
+// cascade widens the files of each module of mods to those of every module
+// it depends on, directly or not, so a change to a dependency releases its
+// dependents too. Modules in a cycle always release together.
+func cascade(mods []module, deps map[string][]string) ([]module, error) {
+	byPrefix := map[string]module{}
+	for _, m := range mods {
+		byPrefix[m.Prefix] = m
+	}
+
+	out := make([]module, len(mods))
+	for i, m := range mods {
+		reached := map[string]bool{m.Prefix: true}
+		queue := append([]string(nil), deps[m.Prefix]...)
+		var through []string
+		for len(queue) > 0 {
+			p := queue[0]
+			queue = queue[1:]
+			if reached[p] {
+				continue
+			}
+			reached[p] = true
+			through = append(through, p)
+			queue = append(queue, deps[p]...)
+		}
+		if len(through) == 0 {
+			out[i] = m
+			continue
+		}
+
+		sort.Strings(through)
+		patterns := []string{"(?:" + m.Files + ")"}
+		for _, p := range through {
+			patterns = append(patterns, "(?:"+byPrefix[p].Files+")")
+		}
+		m.Files = strings.Join(patterns, "|")
+		var err error
+		if m.match, err = regexp.Compile(m.Files); err != nil {
+			return nil, fmt.Errorf("invalid files of %s with its dependencies: %v", m.Prefix, err)
+		}
+		tracef("%s depends on %s", m.Prefix, strings.Join(through, ", "))
+		out[i] = m
+	}
+	return out, nil
+}
+
+// goMods reads the go.mod of each of mods at ref, by prefix. Modules
+// without one aren't Go modules, and are left out.
+func (c *client) goMods(ctx context.Context, mods []module, ref string) (map[string]string, error) {
+	gomods := map[string]string{}
+	for _, m := range mods {
+		fc, _, resp, err := c.c.Repositories.GetContents(ctx, c.owner, c.repo, m.Path+"/go.mod", &github.RepositoryContentGetOptions{Ref: ref})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get %s/go.mod: %v", m.Path, err)
+		}
+		if fc == nil {
+			continue
+		}
+		content, err := fc.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("could not decode %s/go.mod: %v", m.Path, err)
+		}
+		gomods[m.Prefix] = content
+	}
+	return gomods, nil
+}
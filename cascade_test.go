@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseGoMod(t *testing.T) {
+	gomod := `module example.com/mono/services/api // the API
+
+go 1.21
+
+require example.com/mono/libs/core v0.3.0
+
+require (
+	"example.com/mono/libs/auth" v1.0.0
+	golang.org/x/oauth2 v0.0.0 // indirect
+)
+
+replace example.com/mono/libs/core => ../../libs/core
+
+replace (
+	example.com/old => example.com/mono/libs/new v1.0.0
+)
+`
+	mod, deps := parseGoMod(gomod)
+	if mod != "example.com/mono/services/api" {
+		t.Errorf("got module %q", mod)
+	}
+	want := []string{"example.com/mono/libs/core", "example.com/mono/libs/auth", "golang.org/x/oauth2", "example.com/mono/libs/new"}
+	if !reflect.DeepEqual(deps, want) {
+		t.Errorf("got dependencies %v, want %v", deps, want)
+	}
+}
+
+func Test_moduleDeps(t *testing.T) {
+	mods, err := parseModules([]byte(`{"modules": [
+		{"path": "libs/core", "prefix": "core/"},
+		{"path": "services/api", "prefix": "api/", "depends": ["core/"]},
+		{"path": "services/web", "prefix": "web/"},
+		{"path": "docs", "prefix": "docs/"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gomods := map[string]string{
+		"core/": "module example.com/mono/libs/core\n",
+		"api/":  "module example.com/mono/services/api\nrequire example.com/mono/libs/core v0.3.0\n",
+		"web/":  "module example.com/mono/services/web\nrequire (\n\texample.com/mono/services/api v1.0.0\n\tgolang.org/x/oauth2 v0.0.0\n)\n",
+	}
+
+	got := moduleDeps(mods, gomods)
+	want := map[string][]string{"api/": {"core/"}, "web/": {"api/"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func Test_cascade(t *testing.T) {
+	mods, err := parseModules([]byte(`{"modules": [
+		{"path": "libs/core", "prefix": "core/"},
+		{"path": "services/api", "prefix": "api/"},
+		{"path": "services/web", "prefix": "web/"},
+		{"path": "a", "prefix": "a/"},
+		{"path": "b", "prefix": "b/"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deps := map[string][]string{"api/": {"core/"}, "web/": {"api/"}, "a/": {"b/"}, "b/": {"a/"}}
+
+	mods, err = cascade(mods, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		file string
+		want []string
+	}{
+		{file: "libs/core/core.go", want: []string{"core/", "api/", "web/"}},
+		{file: "services/api/main.go", want: []string{"api/", "web/"}},
+		{file: "services/web/main.go", want: []string{"web/"}},
+		{file: "a/a.go", want: []string{"a/", "b/"}},
+		{file: "b/b.go", want: []string{"a/", "b/"}},
+	}
+	for _, tc := range tcs {
+		t.Run(tc.file, func(t *testing.T) {
+			var got []string
+			for _, m := range changedModules(mods, []string{tc.file}, nil) {
+				got = append(got, m.Prefix)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if want := `(?:^services/web/)|(?:^services/api/)|(?:^libs/core/)`; mods[2].Files != want {
+		t.Errorf("got files %q for web/, want %q", mods[2].Files, want)
+	}
+}
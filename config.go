@@ -0,0 +1,561 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// config holds the settings read from the environment.
+type config struct {
+	prefix          string
+	prefixes        []string // to release one after the other, when TAG_PREFIX lists several
+	seedUnprefixed  bool
+	fileMatch       *regexp.Regexp
+	globalPaths     []string
+	ignoreDeletions bool
+	ignoreRenames   bool
+	noTags          string
+	initialVersion  *version.Version // the first release when bootstrapping, nil to bump v0.0.0
+	minVersion      *version.Version // the least version to release, nil for no floor
+	mixedTags       string
+	conflict        string
+	bumpFromCommit  bool
+	bumpFromCommits bool
+	titleKeywords   []keyword // nil unless bumping from PR titles
+	pathRules       []pathRule
+	bumpFloor       []pathRule // the least bump for matching changes
+	bumpCap         []pathRule // the most bump when all changes match
+	capMajor        bool       // make major bumps minor ones
+	apidiff         bool
+	gorelease       string // "" to not run it
+	scheme          scheme
+	detectV         bool              // follow the existing tags as to starting with a v
+	prerelease      string            // the channel, "" for final releases
+	defaultChannel  string            // PRERELEASE
+	channels        map[string]string // channel by branch
+	dryRun          bool
+	promote         bool   // PROMOTE, for workflow_dispatch inputs
+	promoteLabel    string // asks to promote the last prerelease
+
+	rateLimitMin  int
+	staleAfter    time.Duration // 0 to tag merges of any age
+	soakTime      time.Duration // before a prerelease can be promoted
+	confirmLabel  string        // confirms releasing a stale merge
+	confirmed     bool          // CONFIRM_RELEASE, for re-runs
+	confirmMajor  bool          // hold major bumps until confirmed
+	freeze        bool          // comment the next version instead of releasing it, except on workflow_dispatch
+	line          *releaseLine  // the maintenance line of the branch, if it's a maintenance branch
+	tokenKind     string        // the kind of the token tagging, see detectTokenKind
+	releaseRepo   string        // owner/repo to tag releases in, when it's not this one
+	releaseEvent  string        // the repository_dispatch event type sent on releases, "" for none
+	breakingLabel string        // confirms a major bump
+	rateLimitWarn bool
+
+	commitStatus    bool
+	checkRun        bool
+	releaseBranches bool
+	majorTag        bool     // move the v1 tag along, like Github Actions do
+	pinFiles        []string // where to update the pins of the action
+	pinBranch       string
+	refreshFiles    []string // embedding the version, to refresh after releases
+	refreshBranch   string
+	refreshCommit   bool // commit refreshes to the base branch instead of proposing them
+	provenance      bool
+	releaseLabel    bool
+	issueComments   bool
+
+	manifestBranch string
+	manifestPath   string
+	badgeBranch    string
+	badgePath      string
+	badgeLabel     string
+	notesRef       string
+
+	image           string
+	imageSourceTmpl *template.Template
+	imageLatest     bool
+
+	requireStatus bool
+	approvalEnv   string // the protected environment releases need approval in
+
+	codeowners     bool
+	componentPath  string
+	modulesFile    string // mapping directories to prefixes, to release every changed one
+	modulesCascade bool   // release the modules depending on released ones too
+
+	environment string // to deploy releases to, if any
+
+	email *emailer // nil when not sending emails
+
+	goreleaser     bool
+	goreleaserRun  bool
+	goreleaserArgs string
+
+	aggregate       bool
+	releasePR       bool
+	releasePRBranch string
+	changelogPath   string
+	versionFile     string
+
+	noteCategories []noteCategory
+	fragmentsDir   string
+
+	taggerName  string
+	taggerEmail string
+
+	failureComments  bool
+	digestAfter      int     // pull requests a catch-up run can notify one by one
+	digest           *digest // collects the notifications instead, when set
+	mention          string
+	signature        string
+	quietLabel       string
+	commentTmpl      *template.Template
+	issueCommentTmpl *template.Template
+}
+
+// loadConfig reads the configuration from the environment, exiting on
+// invalid values.
+func loadConfig() *config {
+	cfg := &config{
+		prefix:          os.Getenv("TAG_PREFIX"),
+		seedUnprefixed:  os.Getenv("SEED_FROM_UNPREFIXED") == "true",
+		bumpFromCommit:  os.Getenv("BUMP_FROM_COMMIT") == "true",
+		bumpFromCommits: os.Getenv("BUMP_FROM_COMMITS") == "true",
+		rateLimitWarn:   os.Getenv("RATE_LIMIT_WARN") == "true",
+		commitStatus:    os.Getenv("COMMIT_STATUS") == "true",
+		checkRun:        os.Getenv("CHECK_RUN") == "true",
+		releaseBranches: os.Getenv("RELEASE_BRANCHES") == "true",
+		majorTag:        os.Getenv("MAJOR_TAG") == "true",
+		pinBranch:       "autotagger/pins",
+		refreshBranch:   "autotagger/refresh",
+		refreshCommit:   os.Getenv("REFRESH_COMMIT") == "true",
+		apidiff:         os.Getenv("APIDIFF") == "true",
+		ignoreDeletions: os.Getenv("IGNORE_DELETIONS") == "true",
+		ignoreRenames:   os.Getenv("IGNORE_RENAMES") == "true",
+		dryRun:          os.Getenv("DRY_RUN") == "true",
+		promote:         os.Getenv("PROMOTE") == "true",
+		provenance:      os.Getenv("PROVENANCE") == "true",
+		releaseLabel:    os.Getenv("RELEASE_LABEL") == "true",
+		issueComments:   os.Getenv("ISSUE_COMMENTS") == "true",
+		failureComments: os.Getenv("FAILURE_COMMENTS") == "true",
+		mention:         os.Getenv("MENTION"),
+		signature:       os.Getenv("COMMENT_SIGNATURE"),
+		taggerName:      os.Getenv("TAGGER_NAME"),
+		taggerEmail:     os.Getenv("TAGGER_EMAIL"),
+
+		manifestBranch: os.Getenv("MANIFEST_BRANCH"),
+		manifestPath:   "versions.json",
+		badgeBranch:    os.Getenv("BADGE_BRANCH"),
+		badgeLabel:     "version",
+
+		image:       os.Getenv("IMAGE"),
+		imageLatest: os.Getenv("IMAGE_LATEST") == "true",
+
+		requireStatus: os.Getenv("REQUIRE_STATUS") == "true",
+		approvalEnv:   os.Getenv("APPROVAL_ENVIRONMENT"),
+
+		aggregate:       os.Getenv("AGGREGATE") == "true",
+		releasePR:       os.Getenv("RELEASE_PR") == "true",
+		releasePRBranch: "autotagger/release",
+		changelogPath:   "CHANGELOG.md",
+		versionFile:     os.Getenv("VERSION_FILE"),
+
+		goreleaser:     os.Getenv("GORELEASER") == "true",
+		goreleaserRun:  os.Getenv("GORELEASER_RUN") == "true",
+		goreleaserArgs: "release --clean",
+	}
+
+	if strings.ContainsAny(cfg.prefix, ",\n") {
+		ps, err := parsePrefixes(cfg.prefix)
+		if err != nil {
+			fatalf("invalid TAG_PREFIX: %v", err)
+		}
+		cfg.prefixes, cfg.prefix = ps, ""
+	}
+
+	if ga, ok := os.LookupEnv("GORELEASER_ARGS"); ok {
+		cfg.goreleaserArgs = ga
+	}
+
+	cfg.imageSourceTmpl = parseTemplate("IMAGE_SOURCE_TAG", "sha-{{.ShortSHA}}")
+
+	if to := os.Getenv("EMAIL_TO"); to != "" {
+		cfg.email = &emailer{
+			addr:     os.Getenv("SMTP_ADDR"),
+			username: os.Getenv("SMTP_USERNAME"),
+			password: os.Getenv("SMTP_PASSWORD"),
+			from:     os.Getenv("EMAIL_FROM"),
+			subject:  parseTemplate("EMAIL_SUBJECT", defaultEmailSubject),
+			body:     parseTemplate("EMAIL_TEMPLATE", defaultEmailBody),
+		}
+		for _, addr := range strings.Split(to, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				cfg.email.to = append(cfg.email.to, addr)
+			}
+		}
+		if cfg.email.addr == "" || cfg.email.from == "" {
+			fatal("EMAIL_TO needs SMTP_ADDR and EMAIL_FROM to be set too")
+		}
+	}
+
+	if (cfg.taggerName == "") != (cfg.taggerEmail == "") {
+		fatal("TAGGER_NAME and TAGGER_EMAIL must be set together")
+	}
+
+	cfg.codeowners = os.Getenv("CODEOWNERS") == "true"
+	cfg.componentPath = cfg.prefix
+	if cp, ok := os.LookupEnv("COMPONENT_PATH"); ok {
+		cfg.componentPath = cp
+	}
+
+	cfg.modulesFile = os.Getenv("MODULES_FILE")
+	cfg.modulesCascade = os.Getenv("MODULES_CASCADE") == "true"
+	if cfg.modulesCascade && cfg.modulesFile == "" {
+		fatal("MODULES_CASCADE needs MODULES_FILE to be set too")
+	}
+	if cfg.modulesFile != "" && (cfg.prefix != "" || len(cfg.prefixes) > 0) {
+		fatal("MODULES_FILE and TAG_PREFIX can't be used together, the modules file sets each module's prefix")
+	}
+
+	cfg.badgePath = badgePath(cfg.prefix)
+	if bp, ok := os.LookupEnv("BADGE_PATH"); ok {
+		cfg.badgePath = bp
+	}
+	if bl, ok := os.LookupEnv("BADGE_LABEL"); ok {
+		cfg.badgeLabel = bl
+	}
+
+	if os.Getenv("GIT_NOTES") == "true" {
+		cfg.notesRef = "refs/notes/autotagger"
+		if nr, ok := os.LookupEnv("NOTES_REF"); ok {
+			cfg.notesRef = "refs/notes/" + strings.TrimPrefix(nr, "refs/notes/")
+		}
+	}
+
+	if cfg.aggregate && cfg.releasePR {
+		fatal("AGGREGATE and RELEASE_PR can't be used together")
+	}
+	for _, f := range strings.Split(os.Getenv("PIN_FILES"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			cfg.pinFiles = append(cfg.pinFiles, f)
+		}
+	}
+	if pb, ok := os.LookupEnv("PIN_BRANCH"); ok {
+		cfg.pinBranch = pb
+	}
+	for _, f := range strings.Split(os.Getenv("REFRESH_FILES"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			cfg.refreshFiles = append(cfg.refreshFiles, f)
+		}
+	}
+	if rb, ok := os.LookupEnv("REFRESH_BRANCH"); ok {
+		cfg.refreshBranch = rb
+	}
+	if (cfg.majorTag || len(cfg.pinFiles) > 0) && os.Getenv("VERSION_SCHEME") == "calver" {
+		fatal("MAJOR_TAG and PIN_FILES don't work with VERSION_SCHEME=calver")
+	}
+
+	if rb, ok := os.LookupEnv("RELEASE_PR_BRANCH"); ok {
+		cfg.releasePRBranch = rb
+	}
+	if cp, ok := os.LookupEnv("CHANGELOG_PATH"); ok {
+		cfg.changelogPath = cp
+	}
+
+	if mp, ok := os.LookupEnv("MANIFEST_PATH"); ok {
+		cfg.manifestPath = mp
+	}
+
+	fileRE := ".*"
+	if fe, ok := os.LookupEnv("FILE_REGEXP"); ok {
+		fileRE = fe
+	}
+
+	cfg.fileMatch = regexp.MustCompile(fileRE)
+
+	for _, p := range strings.Split(os.Getenv("GLOBAL_PATHS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			cfg.globalPaths = append(cfg.globalPaths, p)
+		}
+	}
+
+	cfg.commentTmpl = parseTemplate("COMMENT_TEMPLATE", defaultCommentTemplate)
+	cfg.issueCommentTmpl = parseTemplate("ISSUE_COMMENT_TEMPLATE", defaultIssueCommentTemplate)
+
+	envs, err := parseEnvironments(os.Getenv("DEPLOY_ENVIRONMENTS"))
+	if err != nil {
+		fatalf("invalid DEPLOY_ENVIRONMENTS: %v", err)
+	}
+	cfg.environment = envs[cfg.prefix]
+
+	if os.Getenv("BUMP_FROM_TITLE") == "true" {
+		kws := defaultTitleKeywords
+		if tk, ok := os.LookupEnv("TITLE_KEYWORDS"); ok {
+			kws = tk
+		}
+		if cfg.titleKeywords, err = parseKeywords(kws); err != nil {
+			fatalf("invalid TITLE_KEYWORDS: %v", err)
+		}
+	}
+
+	if cfg.pathRules, err = parsePathRules(os.Getenv("BUMP_PATHS")); err != nil {
+		fatalf("invalid BUMP_PATHS: %v", err)
+	}
+	if cfg.bumpFloor, err = parsePathRules(os.Getenv("BUMP_FLOOR")); err != nil {
+		fatalf("invalid BUMP_FLOOR: %v", err)
+	}
+	if cfg.bumpCap, err = parsePathRules(os.Getenv("BUMP_CAP")); err != nil {
+		fatalf("invalid BUMP_CAP: %v", err)
+	}
+	cfg.capMajor = os.Getenv("CAP_MAJOR") == "true"
+
+	cats, err := parseNoteCategories(os.Getenv("NOTES_CATEGORIES"))
+	if err != nil {
+		fatalf("invalid NOTES_CATEGORIES: %v", err)
+	}
+	cfg.noteCategories = cats
+	cfg.fragmentsDir = strings.Trim(os.Getenv("NOTES_FRAGMENTS"), "/")
+
+	cfg.promoteLabel = "promote"
+	if pl, ok := os.LookupEnv("PROMOTE_LABEL"); ok {
+		cfg.promoteLabel = pl
+	}
+
+	cfg.quietLabel = "quiet-release"
+	if ql, ok := os.LookupEnv("QUIET_LABEL"); ok {
+		cfg.quietLabel = ql
+	}
+
+	cfg.noTags = noTagsFail
+	if nt, ok := os.LookupEnv("NO_TAGS"); ok {
+		cfg.noTags = nt
+	}
+	switch cfg.noTags {
+	case noTagsFail, noTagsSkip, noTagsBootstrap:
+	default:
+		fatalf("invalid NO_TAGS %q", cfg.noTags)
+	}
+	if iv, ok := os.LookupEnv("INITIAL_VERSION"); ok {
+		v, err := version.NewSemver(iv)
+		if err != nil || v.Prerelease() != "" || v.Metadata() != "" {
+			fatalf("invalid INITIAL_VERSION %q: must be a version like v0.1.0", iv)
+		}
+		if _, set := os.LookupEnv("NO_TAGS"); set && cfg.noTags != noTagsBootstrap {
+			fatalf("INITIAL_VERSION needs NO_TAGS=bootstrap, not %s", cfg.noTags)
+		}
+		cfg.noTags, cfg.initialVersion = noTagsBootstrap, v
+	}
+	switch bt := os.Getenv("BOOTSTRAP_TAGS"); bt {
+	case "", "false":
+	case "true":
+		if _, set := os.LookupEnv("NO_TAGS"); set && cfg.noTags != noTagsBootstrap {
+			fatalf("BOOTSTRAP_TAGS needs NO_TAGS=bootstrap, not %s", cfg.noTags)
+		}
+		cfg.noTags = noTagsBootstrap
+		if cfg.initialVersion == nil {
+			cfg.initialVersion = version.Must(version.NewSemver(defaultInitialVersion))
+		}
+	default:
+		fatalf("invalid BOOTSTRAP_TAGS %q: must be true or false", bt)
+	}
+	if mv, ok := os.LookupEnv("MIN_VERSION"); ok {
+		v, err := version.NewSemver(mv)
+		if err != nil || v.Prerelease() != "" || v.Metadata() != "" {
+			fatalf("invalid MIN_VERSION %q: must be a version like v2.0.0", mv)
+		}
+		if os.Getenv("VERSION_SCHEME") == "calver" {
+			fatal("MIN_VERSION doesn't work with VERSION_SCHEME=calver")
+		}
+		if cfg.initialVersion != nil && cfg.initialVersion.LessThan(v) {
+			if _, set := os.LookupEnv("INITIAL_VERSION"); set {
+				fatalf("INITIAL_VERSION %s is below MIN_VERSION %s", cfg.initialVersion.Original(), mv)
+			}
+			// BOOTSTRAP_TAGS starts at the floor
+			cfg.initialVersion = v
+		}
+		cfg.minVersion = v
+	}
+
+	switch cfg.gorelease = os.Getenv("GORELEASE"); cfg.gorelease {
+	case "", goreleaseFail, goreleaseComment:
+	default:
+		fatalf("invalid GORELEASE %q", cfg.gorelease)
+	}
+
+	cfg.prerelease = os.Getenv("PRERELEASE")
+	if cfg.prerelease != "" && !prereleaseRE.MatchString(cfg.prerelease) {
+		fatalf("invalid PRERELEASE %q: must be an identifier like rc", cfg.prerelease)
+	}
+	switch vs := os.Getenv("VERSION_SCHEME"); vs {
+	case "", "semver":
+		cfg.scheme = semverScheme{}
+	case "calver":
+		format := "YYYY.0M.MICRO"
+		if cf, ok := os.LookupEnv("CALVER_FORMAT"); ok {
+			format = cf
+		}
+		cv, err := parseCalver(format)
+		if err != nil {
+			fatalf("invalid CALVER_FORMAT: %v", err)
+		}
+		if cfg.prerelease != "" || os.Getenv("BRANCH_CHANNELS") != "" {
+			fatal("PRERELEASE and BRANCH_CHANNELS don't work with VERSION_SCHEME=calver")
+		}
+		cfg.scheme = cv
+	default:
+		fatalf("invalid VERSION_SCHEME %q", vs)
+	}
+	switch vp := os.Getenv("V_PREFIX"); vp {
+	case "":
+		cfg.detectV = true
+	case "true":
+	case "false":
+		vPrefix = ""
+	default:
+		fatalf("invalid V_PREFIX %q: must be true or false", vp)
+	}
+
+	switch vs := os.Getenv("VERSION_SEGMENTS"); vs {
+	case "", "3":
+	case "4":
+		if _, ok := cfg.scheme.(semverScheme); !ok {
+			fatal("VERSION_SEGMENTS doesn't work with VERSION_SCHEME=calver")
+		}
+		segments, patchSegment = 4, 3
+		tagTemplate = template.Must(template.New("tag").Parse(defaultTagTemplate4))
+	default:
+		fatalf("invalid VERSION_SEGMENTS %q: must be 3 or 4", vs)
+	}
+	switch ps := os.Getenv("PATCH_SEGMENT"); {
+	case ps == "":
+	case ps == "3" && segments == 4:
+		patchSegment = 2
+	case ps == strconv.Itoa(segments):
+	default:
+		fatalf("invalid PATCH_SEGMENT %q: must be 3 or, with VERSION_SEGMENTS=4, 4", ps)
+	}
+	if vr, ok := os.LookupEnv("VERSION_RESET"); ok {
+		if resets, err = parseResets(vr); err != nil {
+			fatalf("invalid VERSION_RESET: %v", err)
+		}
+		if _, ok := cfg.scheme.(semverScheme); !ok {
+			fatal("VERSION_RESET doesn't work with VERSION_SCHEME=calver")
+		}
+		// prereleases are known to cover a bump by the zeros after it
+		if !resetsAll(resets) && (cfg.prerelease != "" || os.Getenv("BRANCH_CHANNELS") != "") {
+			fatal("VERSION_RESET doesn't work with prereleases")
+		}
+	}
+
+	if tt, ok := os.LookupEnv("TAG_TEMPLATE"); ok {
+		t, err := template.New("tag").Parse(tt)
+		if err == nil {
+			err = checkTagTemplate(t, cfg.prefix, cfg.prerelease != "" || os.Getenv("BRANCH_CHANNELS") != "")
+		}
+		if err != nil {
+			fatalf("invalid TAG_TEMPLATE: %v", err)
+		}
+		tagTemplate = t
+	}
+	cfg.defaultChannel = cfg.prerelease
+	if cfg.channels, err = parseChannels(os.Getenv("BRANCH_CHANNELS")); err != nil {
+		fatalf("invalid BRANCH_CHANNELS: %v", err)
+	}
+
+	cfg.mixedTags = mixedTagsIgnore
+	if mt, ok := os.LookupEnv("MIXED_TAGS"); ok {
+		cfg.mixedTags = mt
+	}
+	switch cfg.mixedTags {
+	case mixedTagsIgnore, mixedTagsWarn, mixedTagsFail:
+	default:
+		fatalf("invalid MIXED_TAGS %q", cfg.mixedTags)
+	}
+
+	cfg.conflict = conflictFail
+	if tc, ok := os.LookupEnv("TAG_CONFLICT"); ok {
+		cfg.conflict = tc
+	}
+	if !validConflictPolicy(cfg.conflict) {
+		fatalf("invalid TAG_CONFLICT %q", cfg.conflict)
+	}
+
+	if da, ok := os.LookupEnv("DIGEST_AFTER"); ok {
+		n, err := strconv.Atoi(da)
+		if err != nil || n < 0 {
+			fatalf("invalid DIGEST_AFTER %q: must be a number of pull requests", da)
+		}
+		cfg.digestAfter = n
+	}
+
+	cfg.rateLimitMin = 50
+	if rl, ok := os.LookupEnv("RATE_LIMIT_MIN"); ok {
+		n, err := strconv.Atoi(rl)
+		if err != nil {
+			fatalf("invalid RATE_LIMIT_MIN %q: %v", rl, err)
+		}
+		cfg.rateLimitMin = n
+	}
+
+	if st, ok := os.LookupEnv("SOAK_TIME"); ok {
+		d, err := time.ParseDuration(st)
+		if err != nil || d < 0 {
+			fatalf("invalid SOAK_TIME %q: must be a duration like 48h", st)
+		}
+		cfg.soakTime = d
+	}
+
+	if sa, ok := os.LookupEnv("STALE_AFTER"); ok {
+		d, err := time.ParseDuration(sa)
+		if err != nil || d <= 0 {
+			fatalf("invalid STALE_AFTER %q: must be a duration like 336h", sa)
+		}
+		cfg.staleAfter = d
+	}
+	cfg.confirmLabel = "confirm-release"
+	if cl, ok := os.LookupEnv("CONFIRM_LABEL"); ok {
+		cfg.confirmLabel = cl
+	}
+	cfg.confirmed = os.Getenv("CONFIRM_RELEASE") == "true"
+	cfg.confirmMajor = os.Getenv("CONFIRM_MAJOR") == "true"
+	cfg.releaseRepo = os.Getenv("RELEASE_REPOSITORY")
+	if cfg.releaseRepo != "" {
+		if _, _, err := splitRepository(cfg.releaseRepo); err != nil {
+			fatalf("invalid RELEASE_REPOSITORY: %v", err)
+		}
+	} else if os.Getenv("RELEASE_TOKEN") != "" {
+		fatal("RELEASE_TOKEN needs RELEASE_REPOSITORY to be set too")
+	}
+	cfg.tokenKind = detectTokenKind(os.Getenv("GITHUB_TOKEN"))
+	if tok := os.Getenv("RELEASE_TOKEN"); tok != "" {
+		cfg.tokenKind = detectTokenKind(tok)
+	}
+	if tk, ok := os.LookupEnv("TOKEN_KIND"); ok {
+		switch tk {
+		case tokenGithub, tokenApp, tokenPAT:
+			cfg.tokenKind = tk
+		default:
+			fatalf("invalid TOKEN_KIND %q: must be github_token, app or pat", tk)
+		}
+	}
+	cfg.releaseEvent = os.Getenv("RELEASE_EVENT")
+	switch fz := os.Getenv("FREEZE"); fz {
+	case "", "false":
+	case "true":
+		cfg.freeze = true
+	default:
+		fatalf("invalid FREEZE %q: must be true or false", fz)
+	}
+	cfg.breakingLabel = "confirmed-breaking"
+	if bl, ok := os.LookupEnv("BREAKING_LABEL"); ok {
+		cfg.breakingLabel = bl
+	}
+
+	return cfg
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// confirmMajorCommand is the PR comment maintainers confirm a major bump
+// with.
+const confirmMajorCommand = "/confirm-major"
+
+// isMajorBump reports whether releasing tag after previous, both under
+// prefix, is a major bump. The first release isn't.
+func isMajorBump(previous, tag, prefix string) bool {
+	if previous == "" {
+		return false
+	}
+	pv, err := version.NewSemver(strings.TrimPrefix(previous, prefix))
+	if err != nil {
+		return false
+	}
+	v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+	if err != nil {
+		return false
+	}
+	return v.Segments()[0] > pv.Segments()[0]
+}
+
+// majorConfirmed reports whether the major bump of the pull request numbered
+// number is confirmed: by the BREAKING_LABEL label, or by a maintainer, with
+// write access or more, commenting /confirm-major.
+func (c *client) majorConfirmed(ctx context.Context, cfg *config, number int) (bool, error) {
+	ok, err := c.hasCurrentLabel(ctx, number, cfg.breakingLabel)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	opt := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := c.c.Issues.ListComments(ctx, c.owner, c.repo, number, opt)
+		if err != nil {
+			return false, err
+		}
+
+		for _, cm := range comments {
+			if !strings.EqualFold(strings.TrimSpace(cm.GetBody()), confirmMajorCommand) {
+				continue
+			}
+			login := cm.GetUser().GetLogin()
+			p, _, err := c.c.Repositories.GetPermissionLevel(ctx, c.owner, c.repo, login)
+			if err != nil {
+				return false, fmt.Errorf("could not get permission of %s: %v", login, err)
+			}
+			if perm := p.GetPermission(); perm == "admin" || perm == "write" {
+				tracef("Major bump confirmed by %s", login)
+				return true, nil
+			}
+			fmt.Printf("Ignoring %s from %s, who can't write to the repository\n", confirmMajorCommand, login)
+		}
+
+		if resp.NextPage == 0 {
+			return false, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// holdMajorBump stops the major release d of pull request pr unless it's
+// confirmed, commenting how to confirm it and exiting with EX_CONFIG.
+func (c *client) holdMajorBump(ctx context.Context, cfg *config, d decision, pr *github.PullRequest) {
+	ok, err := c.majorConfirmed(ctx, cfg, pr.GetNumber())
+	if err != nil {
+		fatalf("could not check confirmation of PR #%d: %v", pr.GetNumber(), err)
+	}
+	if ok {
+		return
+	}
+
+	body := fmt.Sprintf("Major bump to **%s** pending confirmation (%s). Label this PR `%s`, or comment `%s` as a maintainer, then re-run the release.",
+		d.version, d.reason, cfg.breakingLabel, confirmMajorCommand)
+	if err := c.upsertComment(ctx, pr.GetNumber(), cfg.prefix, signed(body, cfg.signature)); err != nil {
+		fatalf("could not comment on #%d: %v", pr.GetNumber(), err)
+	}
+	fmt.Printf("Major bump of PR #%d to %s isn't confirmed, not tagging\n", pr.GetNumber(), d.version)
+	exitSkipped("major_unconfirmed")
+}
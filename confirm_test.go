@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func Test_isMajorBump(t *testing.T) {
+	tests := []struct {
+		previous, tag string
+		want          bool
+	}{
+		{"api/v1.4.0", "api/v2.0.0", true},
+		{"api/v0.9.3", "api/v1.0.0", true},
+		{"api/v1.4.0", "api/v1.5.0", false},
+		{"api/v2.0.0-rc.2", "api/v2.0.0", false},
+		{"", "api/v1.0.0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.previous+" "+tt.tag, func(t *testing.T) {
+			if got := isMajorBump(tt.previous, tt.tag, "api/"); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
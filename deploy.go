@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// parseEnvironments parses a comma-separated list of prefix=environment
+// pairs, e.g. "api/=api-production,web/=web-production". An empty prefix
+// maps unprefixed tags.
+func parseEnvironments(s string) (map[string]string, error) {
+	envs := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid environment %q, expected prefix=environment", pair)
+		}
+		envs[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	return envs, nil
+}
+
+// deploy creates a deployment of the release tag to environment, so the
+// environment's protection rules and deployment history follow the
+// component's releases. It's left pending for a workflow listening to
+// deployment events to carry out.
+func (c *client) deploy(ctx context.Context, environment, tag, prefix string) (int64, error) {
+	dep, _, err := c.c.Repositories.CreateDeployment(ctx, c.owner, c.repo, &github.DeploymentRequest{
+		Ref:              github.String(tag),
+		Task:             github.String("deploy"),
+		AutoMerge:        github.Bool(false),
+		RequiredContexts: &[]string{},
+		Payload:          map[string]string{"version": tag, "prefix": prefix},
+		Environment:      github.String(environment),
+		Description:      github.String("Release " + tag),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	fmt.Printf("Created deployment %d of %s to %s\n", dep.GetID(), tag, environment)
+	return dep.GetID(), setOutput("deployment_id", strconv.FormatInt(dep.GetID(), 10))
+}
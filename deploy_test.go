@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseEnvironments(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+		err  bool
+	}{
+		{in: "", want: map[string]string{}},
+		{in: "api/=api-production, web/ = web-production", want: map[string]string{"api/": "api-production", "web/": "web-production"}},
+		{in: "=production", want: map[string]string{"": "production"}},
+		{in: "api/", err: true},
+		{in: "api/=", err: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parseEnvironments(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error %v", err, tc.err)
+			}
+			if !tc.err && !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// digest collects the releases of a catch-up run, backfill or an aggregate
+// release, so their pull requests, issues and lists hear about them once
+// rather than for every tag. It's used when the run covers more than
+// DIGEST_AFTER pull requests.
+type digest struct {
+	releases []digestRelease
+}
+
+// digestRelease is one release in a digest.
+type digestRelease struct {
+	version    string
+	compareURL string
+	prs        []int
+	notes      string
+}
+
+// useDigest starts a digest for a run notifying n pull requests, when
+// that's more than DIGEST_AFTER.
+func (cfg *config) useDigest(n int) {
+	cfg.digest = nil
+	if cfg.digestAfter > 0 && n > cfg.digestAfter {
+		fmt.Printf("%d pull requests is more than DIGEST_AFTER=%d, sending a digest instead of notifying each\n", n, cfg.digestAfter)
+		cfg.digest = &digest{}
+	}
+}
+
+// add records the release of version covering prs.
+func (d *digest) add(cd commentData, prs []*github.PullRequest, notes string) {
+	r := digestRelease{version: cd.Version, compareURL: cd.CompareURL, notes: notes}
+	for _, p := range prs {
+		r.prs = append(r.prs, p.GetNumber())
+	}
+	d.releases = append(d.releases, r)
+}
+
+// subject names the releases in the digest, like "Released v1.2.4 to
+// v1.2.9".
+func (d *digest) subject() string {
+	first, last := d.releases[0].version, d.releases[len(d.releases)-1].version
+	if first == last {
+		return "Released " + first
+	}
+	return fmt.Sprintf("Released %s to %s", first, last)
+}
+
+// summary lists the releases and the pull requests each one covers, in
+// markdown, with their notes when withNotes is set.
+func (d *digest) summary(withNotes bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s:\n", d.subject())
+	for _, r := range d.releases {
+		prs := make([]string, len(r.prs))
+		for i, n := range r.prs {
+			prs[i] = fmt.Sprintf("#%d", n)
+		}
+		fmt.Fprintf(&b, "\n- **%s**: %s", r.version, strings.Join(prs, ", "))
+		if r.compareURL != "" {
+			fmt.Fprintf(&b, " ([compare](%s))", r.compareURL)
+		}
+	}
+	b.WriteString("\n")
+
+	if withNotes {
+		for _, r := range d.releases {
+			if r.notes != "" {
+				fmt.Fprintf(&b, "\n## %s\n\n%s\n", r.version, strings.TrimSpace(r.notes))
+			}
+		}
+	}
+	return b.String()
+}
+
+// sendDigest announces the digest's releases all at once: as the digest
+// output, in one comment on pr, the newest of them, and in one email.
+func (c *client) sendDigest(ctx context.Context, cfg *config, pr *github.PullRequest) {
+	d := cfg.digest
+	if d == nil || len(d.releases) == 0 {
+		return
+	}
+
+	summary := d.summary(false)
+	fmt.Printf("\n%s", summary)
+	if err := setOutput("digest", summary); err != nil {
+		fatalf("could not set digest output: %v", err)
+	}
+
+	if cfg.quietLabel != "" && hasLabel(pr.Labels, cfg.quietLabel) {
+		fmt.Printf("PR #%d is labeled %q, not commenting\n", pr.GetNumber(), cfg.quietLabel)
+	} else {
+		body := signed(summary+mentions(cfg.mention), cfg.signature)
+		if err := c.upsertComment(ctx, pr.GetNumber(), cfg.prefix, body); err != nil {
+			fatalf("could not comment on #%d: %v", pr.GetNumber(), err)
+		}
+	}
+
+	if cfg.email != nil {
+		fmt.Println("Emailing", strings.Join(cfg.email.to, ", "))
+		if err := cfg.email.send(d.subject(), d.summary(true)); err != nil {
+			fatalf("could not send release email: %v", err)
+		}
+	}
+}
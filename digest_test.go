@@ -0,0 +1,46 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func Test_digest(t *testing.T) {
+	pr := func(n int) *github.PullRequest { return &github.PullRequest{Number: github.Int(n)} }
+
+	d := &digest{}
+	d.add(newCommentData("v1.2.4", "v1.2.3", "https://github.com/o/r", 12), []*github.PullRequest{pr(12)}, "- Fix it (#12)\n")
+	if got, want := d.subject(), "Released v1.2.4"; got != want {
+		t.Errorf("got subject %q, want %q", got, want)
+	}
+
+	d.add(newCommentData("v1.3.0", "v1.2.4", "https://github.com/o/r", 14), []*github.PullRequest{pr(13), pr(14)}, "")
+	if got, want := d.subject(), "Released v1.2.4 to v1.3.0"; got != want {
+		t.Errorf("got subject %q, want %q", got, want)
+	}
+
+	want := "Released v1.2.4 to v1.3.0:\n" +
+		"\n- **v1.2.4**: #12 ([compare](https://github.com/o/r/compare/v1.2.3...v1.2.4))" +
+		"\n- **v1.3.0**: #13, #14 ([compare](https://github.com/o/r/compare/v1.2.4...v1.3.0))\n"
+	if got := d.summary(false); got != want {
+		t.Errorf("got summary\n%s\nwant\n%s", got, want)
+	}
+	if got, want := d.summary(true), want+"\n## v1.2.4\n\n- Fix it (#12)\n"; got != want {
+		t.Errorf("got summary with notes\n%s\nwant\n%s", got, want)
+	}
+}
+
+func Test_useDigest(t *testing.T) {
+	cfg := &config{}
+	if cfg.useDigest(100); cfg.digest != nil {
+		t.Error("digest without DIGEST_AFTER")
+	}
+	cfg.digestAfter = 5
+	if cfg.useDigest(5); cfg.digest != nil {
+		t.Error("digest for 5 pull requests with DIGEST_AFTER=5")
+	}
+	if cfg.useDigest(6); cfg.digest == nil {
+		t.Error("no digest for 6 pull requests with DIGEST_AFTER=5")
+	}
+}
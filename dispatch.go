@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// dispatchEvent is the part of a workflow_dispatch event payload used here.
+// Inputs are strings, or booleans for boolean inputs.
+type dispatchEvent struct {
+	Ref    string                 `json:"ref"`
+	Inputs map[string]interface{} `json:"inputs"`
+	Sender *github.User           `json:"sender"`
+}
+
+// dispatchInputs are what a workflow_dispatch asks to release.
+type dispatchInputs struct {
+	level   bump
+	ref     string // the branch, the event's by default
+	version string // the exact version, "" to bump by level
+}
+
+// parseDispatchInputs reads the level, ref and version inputs of e.
+func parseDispatchInputs(e dispatchEvent) (dispatchInputs, error) {
+	input := func(name string) string {
+		if v, ok := e.Inputs[name]; ok && v != nil {
+			return strings.TrimSpace(fmt.Sprint(v))
+		}
+		return ""
+	}
+
+	in := dispatchInputs{level: bumpPatch, ref: input("ref"), version: input("version")}
+	if l := input("level"); l != "" {
+		b, err := parseBump(l)
+		if err != nil {
+			return in, fmt.Errorf("invalid level input: %v", err)
+		}
+		in.level = b
+	}
+	if in.ref == "" {
+		in.ref = e.Ref
+	}
+	in.ref = strings.TrimPrefix(in.ref, "refs/heads/")
+	if in.ref == "" {
+		return in, fmt.Errorf("no ref to release")
+	}
+	return in, nil
+}
+
+// dispatchCmd cuts a release on demand, from a workflow_dispatch event with
+// the level, ref and version inputs. The head of the ref branch is tagged,
+// covering everything merged into it since the last release, like an
+// aggregate release.
+func dispatchCmd(cfg *config) {
+	b, err := ioutil.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+	if err != nil {
+		fatalf("could not read event info: %v", err)
+	}
+	var e dispatchEvent
+	if err := json.Unmarshal(b, &e); err != nil {
+		fatalf("could not unmarshal event info: %v", err)
+	}
+	in, err := parseDispatchInputs(e)
+	if err != nil {
+		fatal(err)
+	}
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	br, _, err := cli.c.Repositories.GetBranch(ctx, cli.owner, cli.repo, in.ref)
+	if err != nil {
+		fatalf("could not get branch %s: %v", in.ref, err)
+	}
+	ref := br.GetCommit().GetSHA()
+	reportContext["repository"] = cli.owner + "/" + cli.repo
+	reportContext["sha"] = ref
+	tracef("Release of %s (%s) requested by %s", in.ref, ref, e.Sender.GetLogin())
+
+	cfg.useBranch(in.ref)
+	last, previous, err := cli.lastRelease(ctx, cfg)
+	if err != nil {
+		fatal(err)
+	}
+	if previous == "" {
+		fatalf("no versions under %q yet, tag the first release before dispatching", cfg.prefix)
+	}
+
+	since, err := cli.commitDate(ctx, previous)
+	if err != nil {
+		fatalf("could not get date of %s: %v", previous, err)
+	}
+	prs, err := cli.mergedSince(ctx, in.ref, since)
+	if err != nil {
+		fatalf("could not list pull requests: %v", err)
+	}
+	if len(prs) == 0 {
+		fmt.Printf("Nothing merged into %s since %s\n", in.ref, previous)
+		exitSkipped("nothing_merged")
+	}
+
+	var d decision
+	if cfg.promote {
+		d = cli.decidePromotion(ctx, cfg, last, previous, ref)
+	} else {
+		d = cli.decideDispatch(ctx, cfg, in, last, previous, ref)
+		d.prs = prs
+	}
+	if cfg.dryRun {
+		cli.preview(ctx, cfg, d, prs[len(prs)-1])
+		fmt.Println("Done")
+		return
+	}
+	if d.skip == "" {
+		if reason := cli.gate(ctx, cfg, d); reason != "" {
+			fatalf("release of %s is blocked: %s", d.version, reason)
+		}
+	}
+	cfg.useDigest(len(prs))
+	cli.apply(ctx, cfg, d, prs[len(prs)-1])
+	cli.sendDigest(ctx, cfg, prs[len(prs)-1])
+	if cfg.promote && d.skip == "" {
+		if err := cli.supersedeRelease(ctx, previous, d.version); err != nil {
+			fatalf("could not mark release %s as superseded: %v", previous, err)
+		}
+	}
+
+	if d.skip == skipConflict || d.skip == skipNoPrerelease || d.skip == skipSoaking {
+		os.Exit(exConfig)
+	}
+	fmt.Println("Done")
+}
+
+// decideDispatch works out the release of ref asked for by in: the exact
+// version when there's one, otherwise last bumped by the level.
+func (c *client) decideDispatch(ctx context.Context, cfg *config, in dispatchInputs, last *version.Version, previous, ref string) decision {
+	d := decision{ref: ref, previous: previous}
+
+	prevSHA, err := c.getTagSHA(ctx, previous)
+	if err != nil {
+		fatalf("could not look up tag %s: %v", previous, err)
+	}
+	if prevSHA == ref {
+		tracef("%s is already tagged as %s", ref, previous)
+		d.previous, d.version, d.action = "", previous, tagExists
+		return d
+	}
+
+	var next string
+	if in.version != "" {
+		if next, err = overrideVersion(in.version, cfg.prefix, last); err != nil {
+			fatalf("invalid version input: %v", err)
+		}
+		d.reason = "version " + in.version + " asked for by workflow_dispatch"
+	} else {
+		if l := cfg.line; l != nil && in.level > l.maxBump() {
+			fatalf("a %s bump would leave the %s maintenance line of %s", in.level, l, in.ref)
+		}
+		next = bumpVersion(cfg, last, in.level)
+		d.reason = fmt.Sprintf("%s bump asked for by workflow_dispatch", in.level)
+		if floor, ok := floorTag(cfg, last, next); ok {
+			next, d.reason = floor, d.reason+", raised to MIN_VERSION"
+		}
+	}
+	tracef("Bumping %s to %s: %s", previous, next, d.reason)
+
+	d.version, d.action = c.resolveConflict(ctx, next, ref, cfg.prefix, cfg.conflict)
+	if d.action == tagSkip {
+		d.skip = skipConflict
+	}
+	return d
+}
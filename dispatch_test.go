@@ -0,0 +1,53 @@
+package main
+
+import "testing"
+
+func Test_parseDispatchInputs(t *testing.T) {
+	tests := []struct {
+		name string
+		e    dispatchEvent
+		want dispatchInputs
+		ok   bool
+	}{
+		{
+			name: "defaults",
+			e:    dispatchEvent{Ref: "refs/heads/main"},
+			want: dispatchInputs{level: bumpPatch, ref: "main"},
+			ok:   true,
+		},
+		{
+			name: "inputs",
+			e: dispatchEvent{Ref: "refs/heads/main", Inputs: map[string]interface{}{
+				"level": "Minor", "ref": "release/v1.4", "version": "",
+			}},
+			want: dispatchInputs{level: bumpMinor, ref: "release/v1.4"},
+			ok:   true,
+		},
+		{
+			name: "version",
+			e:    dispatchEvent{Ref: "refs/heads/main", Inputs: map[string]interface{}{"version": " v2.0.0 "}},
+			want: dispatchInputs{level: bumpPatch, ref: "main", version: "v2.0.0"},
+			ok:   true,
+		},
+		{
+			name: "bad level",
+			e:    dispatchEvent{Ref: "refs/heads/main", Inputs: map[string]interface{}{"level": "huge"}},
+		},
+		{
+			name: "no ref",
+			e:    dispatchEvent{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDispatchInputs(tt.e)
+			if (err == nil) != tt.ok {
+				t.Fatalf("got error %v, want ok %v", err, tt.ok)
+			}
+			if tt.ok && got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// emailData is what the email templates are rendered with.
+type emailData struct {
+	commentData
+	Changelog string
+}
+
+const (
+	defaultEmailSubject = "Released {{.Version}}"
+	defaultEmailBody    = "{{.Version}} has been released.\n\n{{.Changelog}}" +
+		"{{if .CompareURL}}\nChanges: {{.CompareURL}}\n{{end}}"
+)
+
+// emailer sends release announcements over SMTP.
+type emailer struct {
+	addr     string // host:port
+	username string
+	password string
+	from     string
+	to       []string
+
+	subject *template.Template
+	body    *template.Template
+}
+
+// announce emails the release to the configured lists.
+func (e *emailer) announce(d emailData) error {
+	var subject, body strings.Builder
+	if err := e.subject.Execute(&subject, d); err != nil {
+		return fmt.Errorf("could not render EMAIL_SUBJECT: %v", err)
+	}
+	if err := e.body.Execute(&body, d); err != nil {
+		return fmt.Errorf("could not render EMAIL_TEMPLATE: %v", err)
+	}
+	return e.send(subject.String(), body.String())
+}
+
+// send emails the message to the configured lists.
+func (e *emailer) send(subject, body string) error {
+	var auth smtp.Auth
+	if e.username != "" {
+		host, _, err := net.SplitHostPort(e.addr)
+		if err != nil {
+			return fmt.Errorf("invalid SMTP_ADDR %q: %v", e.addr, err)
+		}
+		auth = smtp.PlainAuth("", e.username, e.password, host)
+	}
+
+	return smtp.SendMail(e.addr, auth, e.from, e.to, emailMessage(e.from, e.to, subject, body, time.Now()))
+}
+
+// emailMessage builds a plain text RFC 5322 message.
+func emailMessage(from string, to []string, subject, body string, date time.Time) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", strings.NewReplacer("\r", "", "\n", " ").Replace(subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.Replace(strings.Replace(body, "\r\n", "\n", -1), "\n", "\r\n", -1))
+	return []byte(b.String())
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_emailMessage(t *testing.T) {
+	date := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := string(emailMessage("bot@example.com", []string{"a@example.com", "b@example.com"}, "Released\nv1.2.4", "Hi\n\n- thing\n", date))
+
+	want := "From: bot@example.com\r\n" +
+		"To: a@example.com, b@example.com\r\n" +
+		"Subject: Released v1.2.4\r\n" +
+		"Date: Thu, 02 Jan 2020 03:04:05 +0000\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Hi\r\n\r\n- thing\r\n"
+
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
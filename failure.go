@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// onFailure is called with the error when a run fails, once it's known
+// which merged pull request it releases. It's only called once.
+var onFailure func(msg string)
+
+// failureInfo is what's known about a failed release, to suggest how to
+// finish it.
+type failureInfo struct {
+	run     string // the workflow run URL
+	version string // the version that was being released, if decided
+	sha     string // the merge commit
+	branch  string // the PR's base branch
+}
+
+// failureComment describes the failure msg for the PR comment, with hints
+// for common causes and how to retry or tag by hand.
+func failureComment(msg string, f failureInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Releasing this PR failed:\n\n```\n%s\n```\n", msg)
+
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.Contains(lower, "403") || strings.Contains(lower, "not accessible") || strings.Contains(lower, "permission"):
+		b.WriteString("\nThe token can't do this. Give the workflow `contents: write` and `pull-requests: write` permissions, or a token that has them.\n")
+	case strings.Contains(lower, "rate limit"):
+		b.WriteString("\nThe API rate limit ran out, retry once it's reset.\n")
+	case strings.Contains(lower, "already exists") || strings.Contains(lower, "conflict"):
+		b.WriteString("\nThe tag is taken. `TAG_CONFLICT=bump-again` picks the next free version, or release an exact version as below.\n")
+	}
+
+	b.WriteString("\nTo retry:\n\n")
+	if f.run != "" {
+		fmt.Fprintf(&b, "- re-run the failed jobs of [the workflow run](%s)\n", f.run)
+	}
+	if f.version != "" {
+		fmt.Fprintf(&b, "- run the release workflow from the Actions UI, with the inputs `ref: %s` and `version: %s`\n", f.branch, f.version)
+		if f.sha != "" {
+			fmt.Fprintf(&b, "- or tag it by hand: `git tag %s %s && git push origin %s`\n", f.version, f.sha, f.version)
+		}
+	} else {
+		fmt.Fprintf(&b, "- run the release workflow from the Actions UI, with the inputs `ref: %s` and `level: patch` (or minor, major)\n", f.branch)
+	}
+	return b.String()
+}
+
+// commentFailures sets onFailure to comment on the PR numbered number,
+// merged into branch, when the run fails.
+func (c *client) commentFailures(ctx context.Context, cfg *config, number int, branch string) {
+	onFailure = func(msg string) {
+		info := failureInfo{branch: branch}
+		if v, ok := reportContext["run"]; ok {
+			info.run = fmt.Sprint(v)
+		}
+		if v, ok := reportContext["version"]; ok {
+			info.version = fmt.Sprint(v)
+		}
+		if v, ok := reportContext["sha"]; ok {
+			info.sha = fmt.Sprint(v)
+		}
+
+		body := signed(failureComment(msg, info), cfg.signature)
+		if err := c.upsertComment(ctx, number, cfg.prefix, body); err != nil {
+			log.Printf("Could not comment the failure on #%d: %v", number, err)
+		}
+	}
+}
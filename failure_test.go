@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_failureComment(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		info failureInfo
+		want []string
+	}{
+		{
+			name: "permissions",
+			msg:  "could not create tag for ref abc123: POST .../git/refs: 403 Resource not accessible by integration",
+			info: failureInfo{run: "https://github.com/o/r/actions/runs/1", version: "v1.2.4", sha: "abc123", branch: "main"},
+			want: []string{
+				"`contents: write`",
+				"[the workflow run](https://github.com/o/r/actions/runs/1)",
+				"`ref: main` and `version: v1.2.4`",
+				"`git tag v1.2.4 abc123 && git push origin v1.2.4`",
+			},
+		},
+		{
+			name: "undecided",
+			msg:  "could not get diff",
+			info: failureInfo{branch: "main"},
+			want: []string{"`ref: main` and `level: patch`"},
+		},
+		{
+			name: "conflict",
+			msg:  "tag v1.2.4 already exists on another commit",
+			info: failureInfo{version: "v1.2.4", branch: "main"},
+			want: []string{"TAG_CONFLICT=bump-again"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := failureComment(tt.msg, tt.info)
+			if !strings.Contains(got, tt.msg) {
+				t.Errorf("comment doesn't hold the error:\n%s", got)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("comment doesn't hold %q:\n%s", w, got)
+				}
+			}
+		})
+	}
+}
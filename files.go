@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// updateFile commits a change to the file at path on branch. update gets the
+// current contents, nil if the file doesn't exist yet, and returns the new
+// ones. The update is retried when the file changed under us.
+func (c *client) updateFile(ctx context.Context, branch, path, message string, update func(old []byte) ([]byte, error)) error {
+	const attempts = 3
+
+	for i := 0; ; i++ {
+		var old []byte
+		var sha *string
+
+		fc, _, resp, err := c.c.Repositories.GetContents(ctx, c.owner, c.repo, path, &github.RepositoryContentGetOptions{Ref: branch})
+		switch {
+		case resp != nil && resp.StatusCode == http.StatusNotFound:
+		case err != nil:
+			return err
+		case fc == nil:
+			return fmt.Errorf("%s is a directory", path)
+		default:
+			content, err := fc.GetContent()
+			if err != nil {
+				return fmt.Errorf("could not decode %s: %v", path, err)
+			}
+			old, sha = []byte(content), fc.SHA
+		}
+
+		content, err := update(old)
+		if err != nil {
+			return err
+		}
+		if old != nil && string(content) == string(old) {
+			fmt.Printf("%s on %s is already up to date\n", path, branch)
+			return nil
+		}
+
+		_, resp, err = c.c.Repositories.UpdateFile(ctx, c.owner, c.repo, path, &github.RepositoryContentFileOptions{
+			Message: github.String(message),
+			Content: content,
+			SHA:     sha,
+			Branch:  github.String(branch),
+		})
+		if resp != nil && resp.StatusCode == http.StatusConflict && i < attempts-1 {
+			fmt.Printf("%s on %s changed while updating it, retrying\n", path, branch)
+			continue
+		}
+		return err
+	}
+}
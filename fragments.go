@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// fragment is a release note written by a PR's author as its own file in
+// the fragments directory, so notes never conflict the way CHANGELOG.md
+// edits do.
+type fragment struct {
+	path string
+	text string
+}
+
+// readFragments reads the note fragments in dir as of ref, skipping dot
+// files like .gitkeep. It returns none when the directory doesn't exist.
+func (c *client) readFragments(ctx context.Context, dir, ref string) ([]fragment, error) {
+	opt := &github.RepositoryContentGetOptions{Ref: ref}
+	_, entries, resp, err := c.c.Repositories.GetContents(ctx, c.owner, c.repo, dir, opt)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var frags []fragment
+	for _, e := range entries {
+		if e.GetType() != "file" || strings.HasPrefix(e.GetName(), ".") {
+			continue
+		}
+
+		fc, _, _, err := c.c.Repositories.GetContents(ctx, c.owner, c.repo, e.GetPath(), opt)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %v", e.GetPath(), err)
+		}
+		text, err := fc.GetContent()
+		if err != nil {
+			return nil, fmt.Errorf("could not decode %s: %v", e.GetPath(), err)
+		}
+		frags = append(frags, fragment{path: e.GetPath(), text: text})
+	}
+
+	sort.Slice(frags, func(i, j int) bool { return frags[i].path < frags[j].path })
+	return frags, nil
+}
+
+// fragmentNotes renders the fragments as a "Notes" section to go after the
+// release notes, one list item per fragment, in file name order.
+func fragmentNotes(frags []fragment) string {
+	var b strings.Builder
+	for _, f := range frags {
+		text := strings.TrimSpace(f.text)
+		if text == "" {
+			continue
+		}
+		lines := strings.Split(strings.TrimPrefix(text, "- "), "\n")
+		for i, l := range lines {
+			switch {
+			case i == 0:
+				lines[i] = "- " + l
+			case l != "":
+				lines[i] = "  " + l
+			}
+		}
+		b.WriteString(strings.Join(lines, "\n") + "\n")
+	}
+
+	if b.Len() == 0 {
+		return ""
+	}
+	return "\n### Notes\n\n" + b.String()
+}
+
+// removeFragments deletes the released fragments from branch in a single
+// cleanup commit, retrying when the branch moves under us.
+func (c *client) removeFragments(ctx context.Context, branch, message string, frags []fragment) error {
+	const attempts = 3
+
+	remove := map[string]bool{}
+	for _, f := range frags {
+		remove[f.path] = true
+	}
+
+	for i := 0; ; i++ {
+		head, _, err := c.c.Git.GetRef(ctx, c.owner, c.repo, "heads/"+branch)
+		if err != nil {
+			return err
+		}
+		parent, _, err := c.c.Git.GetCommit(ctx, c.owner, c.repo, head.GetObject().GetSHA())
+		if err != nil {
+			return err
+		}
+		tree, _, err := c.c.Git.GetTree(ctx, c.owner, c.repo, parent.GetTree().GetSHA(), true)
+		if err != nil {
+			return err
+		}
+		if tree.GetTruncated() {
+			return fmt.Errorf("the tree of %s is too big to rewrite", branch)
+		}
+
+		// Trees can't have entries deleted against a base, so the new one is
+		// built from every remaining file; GitHub fills in the directories.
+		var entries []github.TreeEntry
+		removed := 0
+		for _, e := range tree.Entries {
+			switch {
+			case e.GetType() == "tree":
+			case remove[e.GetPath()]:
+				removed++
+			default:
+				entries = append(entries, github.TreeEntry{Path: e.Path, Mode: e.Mode, Type: e.Type, SHA: e.SHA})
+			}
+		}
+		if removed == 0 {
+			fmt.Printf("Note fragments are already gone from %s\n", branch)
+			return nil
+		}
+
+		newTree, _, err := c.c.Git.CreateTree(ctx, c.owner, c.repo, "", entries)
+		if err != nil {
+			return fmt.Errorf("could not create tree: %v", err)
+		}
+		commit, _, err := c.c.Git.CreateCommit(ctx, c.owner, c.repo, &github.Commit{
+			Message: github.String(message),
+			Tree:    newTree,
+			Parents: []github.Commit{{SHA: parent.SHA}},
+		})
+		if err != nil {
+			return fmt.Errorf("could not create commit: %v", err)
+		}
+
+		head.Object = &github.GitObject{SHA: commit.SHA}
+		_, resp, err := c.c.Git.UpdateRef(ctx, c.owner, c.repo, head, false)
+		if resp != nil && resp.StatusCode == http.StatusUnprocessableEntity && i < attempts-1 {
+			fmt.Printf("%s moved while removing note fragments, retrying\n", branch)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed %d note fragments from %s/ on %s\n", removed, path.Dir(frags[0].path), branch)
+		return nil
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// freezeComment tells pull request authors their release d is held by
+// FREEZE, and how it goes out later.
+func freezeComment(d decision) string {
+	body := fmt.Sprintf("Releases are frozen. Once the freeze is over, this would be released as **%s** (%s).", d.version, d.reason)
+	return body + " A maintainer can release it, with everything else merged since the last release, by running the release workflow from the Actions UI (workflow_dispatch)."
+}
+
+// holdFrozen stops the release d of pull request pr during a FREEZE,
+// commenting the version it would have been and exiting with EX_CONFIG.
+func (c *client) holdFrozen(ctx context.Context, cfg *config, d decision, pr *github.PullRequest) {
+	if err := c.upsertComment(ctx, pr.GetNumber(), cfg.prefix, signed(freezeComment(d), cfg.signature)); err != nil {
+		fatalf("could not comment on #%d: %v", pr.GetNumber(), err)
+	}
+	if err := setOutput("next_version", d.version); err != nil {
+		log.Printf("Could not set next_version output: %v", err)
+	}
+	fmt.Printf("Releases are frozen, not tagging %s for PR #%d\n", d.version, pr.GetNumber())
+	exitSkipped("frozen")
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_freezeComment(t *testing.T) {
+	got := freezeComment(decision{version: "v1.3.0", reason: `minor bump from the PR's minor label`})
+	for _, want := range []string{"**v1.3.0**", "minor bump from the PR's minor label", "workflow_dispatch"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("comment doesn't hold %q:\n%s", want, got)
+		}
+	}
+}
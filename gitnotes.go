@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// releaseNote renders the git note recording how version came to be.
+func releaseNote(d decision, pr *github.PullRequest, changelog string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "autotagger release %s\n\n", d.version)
+	fmt.Fprintf(&b, "Pull-Request: #%d\n", pr.GetNumber())
+	if d.previous != "" {
+		fmt.Fprintf(&b, "Previous: %s\n", d.previous)
+	}
+	if d.reason != "" {
+		fmt.Fprintf(&b, "Reason: %s\n", d.reason)
+	}
+	if changelog != "" {
+		fmt.Fprintf(&b, "\n%s", changelog)
+	}
+	return b.String()
+}
+
+// addNote attaches note to the commit sha under the notes ref, the way
+// `git notes --ref` would, replacing any note the commit already has there.
+// Notes are kept in a flat tree named after the annotated commits.
+func (c *client) addNote(ctx context.Context, notesRef, sha, note string) error {
+	var parents []string
+	var baseTree string
+
+	r, resp, err := c.c.Git.GetRef(ctx, c.owner, c.repo, notesRef)
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+	case err != nil:
+		return err
+	default:
+		parent, _, err := c.c.Git.GetCommit(ctx, c.owner, c.repo, r.GetObject().GetSHA())
+		if err != nil {
+			return err
+		}
+		parents = []string{parent.GetSHA()}
+		baseTree = parent.GetTree().GetSHA()
+	}
+
+	tree, _, err := c.c.Git.CreateTree(ctx, c.owner, c.repo, baseTree, []github.TreeEntry{{
+		Path:    github.String(sha),
+		Mode:    github.String("100644"),
+		Type:    github.String("blob"),
+		Content: github.String(note),
+	}})
+	if err != nil {
+		return fmt.Errorf("could not create notes tree: %v", err)
+	}
+
+	commit := &github.Commit{
+		Message: github.String("Notes added by autotagger"),
+		Tree:    tree,
+	}
+	for _, p := range parents {
+		commit.Parents = append(commit.Parents, github.Commit{SHA: github.String(p)})
+	}
+	nc, _, err := c.c.Git.CreateCommit(ctx, c.owner, c.repo, commit)
+	if err != nil {
+		return fmt.Errorf("could not create notes commit: %v", err)
+	}
+
+	ref := &github.Reference{
+		Ref:    github.String(notesRef),
+		Object: &github.GitObject{SHA: nc.SHA},
+	}
+	if parents == nil {
+		_, _, err = c.c.Git.CreateRef(ctx, c.owner, c.repo, ref)
+	} else {
+		_, _, err = c.c.Git.UpdateRef(ctx, c.owner, c.repo, ref, false)
+	}
+	return err
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func Test_releaseNote(t *testing.T) {
+	pr := &github.PullRequest{Number: github.Int(7)}
+
+	tests := []struct {
+		name      string
+		d         decision
+		changelog string
+		want      string
+	}{
+		{
+			"full",
+			decision{version: "v1.3.0", previous: "v1.2.0", reason: "minor bump from the PR's minor label"},
+			"- Add things (#7)\n",
+			"autotagger release v1.3.0\n\nPull-Request: #7\nPrevious: v1.2.0\nReason: minor bump from the PR's minor label\n\n- Add things (#7)\n",
+		},
+		{
+			"first release",
+			decision{version: "v0.1.0"},
+			"",
+			"autotagger release v0.1.0\n\nPull-Request: #7\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := releaseNote(tt.d, pr, tt.changelog); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_addNote(t *testing.T) {
+	var tree struct {
+		BaseTree string             `json:"base_tree"`
+		Tree     []github.TreeEntry `json:"tree"`
+	}
+	var commit struct {
+		Parents []string `json:"parents"`
+	}
+	var moved *github.Reference
+	c, done := newTestClient(t, nil, map[string]interface{}{
+		"GET /repos/o/r/git/refs/notes/autotagger": &github.Reference{
+			Ref:    github.String("refs/notes/autotagger"),
+			Object: &github.GitObject{SHA: github.String("notes1")},
+		},
+		"GET /repos/o/r/git/commits/notes1": &github.Commit{
+			SHA:  github.String("notes1"),
+			Tree: &github.Tree{SHA: github.String("tree1")},
+		},
+		"POST /repos/o/r/git/trees": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&tree)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"sha": "tree2"}`))
+		},
+		"POST /repos/o/r/git/commits": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&commit)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"sha": "notes2"}`))
+		},
+		"PATCH /repos/o/r/git/refs/notes/autotagger": func(w http.ResponseWriter, r *http.Request) {
+			var body struct {
+				SHA string `json:"sha"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			moved = &github.Reference{Object: &github.GitObject{SHA: github.String(body.SHA)}}
+			json.NewEncoder(w).Encode(moved)
+		},
+	})
+	defer done()
+
+	if err := c.addNote(context.Background(), "refs/notes/autotagger", "merge", "autotagger release v1.3.0\n"); err != nil {
+		t.Fatal(err)
+	}
+	if tree.BaseTree != "tree1" || len(tree.Tree) != 1 || tree.Tree[0].GetPath() != "merge" || tree.Tree[0].GetContent() != "autotagger release v1.3.0\n" {
+		t.Errorf("got tree %+v, want the note at merge on top of tree1", tree)
+	}
+	if len(commit.Parents) != 1 || commit.Parents[0] != "notes1" {
+		t.Errorf("got parents %q, want notes1", commit.Parents)
+	}
+	if moved.GetObject().GetSHA() != "notes2" {
+		t.Errorf("got the notes ref moved to %q, want notes2", moved.GetObject().GetSHA())
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// policies for when gorelease finds the version too low for the changes
+const (
+	goreleaseFail    = "fail"    // don't tag
+	goreleaseComment = "comment" // tag anyway, warning on the PR
+)
+
+// goreleaseVerdict reads gorelease's report on a planned version. It
+// returns whether the version is fine, and the version gorelease suggests
+// instead when it's not.
+func goreleaseVerdict(report string) (bool, string) {
+	ok, suggested := true, ""
+	s := bufio.NewScanner(strings.NewReader(report))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		switch {
+		case strings.HasSuffix(line, "is not a valid semantic version for this release."):
+			ok = false
+		case strings.HasPrefix(line, "Suggested version:"):
+			if f := strings.Fields(strings.TrimPrefix(line, "Suggested version:")); len(f) > 0 {
+				suggested = f[0]
+			}
+		}
+	}
+	return ok, suggested
+}
+
+// moduleVersion returns the Go module version of tag, like v1.2.3: without
+// prefix, which Go takes as the module's directory, and with a v even when
+// V_PREFIX=false leaves it out of tags.
+func moduleVersion(tag, prefix string) (string, error) {
+	v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+	if err != nil {
+		return "", err
+	}
+	return "v" + v.String(), nil
+}
+
+// gorelease runs gorelease on the module at dir, relative to the workspace,
+// checked out at d's commit, to check d's version is high enough for the
+// changes to its API since the previous one. It returns the report when the
+// version is too low.
+func gorelease(cfg *config, d decision) (string, error) {
+	if err := needTools("GORELEASE", "git", "gorelease"); err != nil {
+		return "", err
+	}
+	workspace := os.Getenv("GITHUB_WORKSPACE")
+	tmp, err := ioutil.TempDir("", "autotagger-gorelease")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	defer git(workspace, "worktree", "prune")
+
+	wt, err := worktree(workspace, filepath.Join(tmp, "release"), d.ref)
+	if err != nil {
+		return "", err
+	}
+
+	base := "none"
+	if d.previous != "" {
+		if base, err = moduleVersion(d.previous, cfg.prefix); err != nil {
+			return "", fmt.Errorf("invalid previous version %s: %v", d.previous, err)
+		}
+	}
+	next, err := moduleVersion(d.version, cfg.prefix)
+	if err != nil {
+		return "", fmt.Errorf("invalid version %s: %v", d.version, err)
+	}
+	args := []string{"-base=" + base, "-version=" + next}
+
+	// gorelease exits with an error for a version that's too low, so that's
+	// told apart by the report
+	out, err := run(filepath.Join(wt, cfg.componentPath), "gorelease", args...)
+	tracef("gorelease %s:\n%s", strings.Join(args, " "), out)
+	if ok, _ := goreleaseVerdict(out); !ok {
+		return out, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("%v: %s", err, out)
+	}
+	return "", nil
+}
+
+// checkGorelease applies the GORELEASE policy to the release decided in d
+// for pr. The warning has its own section of the bot's comment, after the
+// component's, so reruns update it rather than post it again.
+func (c *client) checkGorelease(ctx context.Context, cfg *config, d decision, pr *github.PullRequest) {
+	report, err := gorelease(cfg, d)
+	if err != nil {
+		fatalf("could not run gorelease: %v", err)
+	}
+	if report == "" {
+		return
+	}
+
+	_, suggested := goreleaseVerdict(report)
+	if cfg.gorelease == goreleaseFail {
+		fatalf("gorelease says %s is too low for the API changes (suggested: %s)", d.version, suggested)
+	}
+
+	body := fmt.Sprintf(":warning: gorelease says %s is too low for the API changes since %s, it suggests %s%s.\n\n```\n%s\n```",
+		d.version, d.previous, cfg.prefix, suggested, strings.TrimSpace(report))
+	if err := c.upsertComment(ctx, pr.GetNumber(), cfg.prefix+"gorelease", signed(body, cfg.signature)); err != nil {
+		fatalf("could not comment on PR #%d: %v", pr.GetNumber(), err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func Test_goreleaseVerdict(t *testing.T) {
+	tests := []struct {
+		name      string
+		report    string
+		ok        bool
+		suggested string
+	}{
+		{"fine", "# summary\nv1.3.0 is a valid semantic version for this release.\n", true, ""},
+		{
+			"too low",
+			"# example.com/m\n## compatible changes\nNew: added\n\n# summary\nv1.2.4 is not a valid semantic version for this release.\nSuggested version: v1.3.0\n",
+			false, "v1.3.0",
+		},
+		{"no suggestion", "v1.2.4 is not a valid semantic version for this release.\nSuggested version:\n", false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, suggested := goreleaseVerdict(tt.report)
+			if ok != tt.ok || suggested != tt.suggested {
+				t.Errorf("got %v, %q, want %v, %q", ok, suggested, tt.ok, tt.suggested)
+			}
+		})
+	}
+}
+
+func Test_moduleVersion(t *testing.T) {
+	tests := []struct {
+		tag, prefix, want string
+	}{
+		{"v1.2.3", "", "v1.2.3"},
+		{"1.2.3", "", "v1.2.3"},
+		{"api/v1.2.3", "api/", "v1.2.3"},
+		{"api/1.3.0-rc.1", "api/", "v1.3.0-rc.1"},
+	}
+
+	for _, tt := range tests {
+		got, err := moduleVersion(tt.tag, tt.prefix)
+		if err != nil || got != tt.want {
+			t.Errorf("moduleVersion(%q, %q): got %q, %v, want %q", tt.tag, tt.prefix, got, err, tt.want)
+		}
+	}
+}
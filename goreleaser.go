@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// goreleaser hands the release over to goreleaser: later steps get the
+// GORELEASER_CURRENT_TAG and GORELEASER_PREVIOUS_TAG variables, and when run
+// is set goreleaser is invoked right away with args.
+func goreleaser(d decision, run bool, args string) error {
+	vars := map[string]string{"GORELEASER_CURRENT_TAG": d.version}
+	if d.previous != "" {
+		vars["GORELEASER_PREVIOUS_TAG"] = d.previous
+	}
+
+	for k, v := range vars {
+		if err := setEnv(k, v); err != nil {
+			return err
+		}
+	}
+
+	if !run {
+		return nil
+	}
+
+	if err := needTools("GORELEASER_RUN", "goreleaser"); err != nil {
+		return err
+	}
+	fmt.Println("Running goreleaser", args)
+	cmd := exec.Command("goreleaser", strings.Fields(args)...)
+	cmd.Dir = os.Getenv("GITHUB_WORKSPACE")
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = os.Environ()
+	for k, v := range vars {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	return cmd.Run()
+}
+
+// setEnv exports a variable to the following steps of the job through the
+// GITHUB_ENV file. Outside of Actions it's only printed.
+func setEnv(name, value string) error {
+	path := os.Getenv("GITHUB_ENV")
+	if path == "" {
+		fmt.Printf("%s=%s\n", name, value)
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "%s=%s\n", name, value)
+	return err
+}
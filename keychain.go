@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// keychain entry the token is stored under
+const (
+	keychainService = "autotagger"
+	keychainAccount = "github"
+)
+
+var errNoKeychain = errors.New("no supported keychain on this system")
+
+// authCmd manages the Github token stored in the OS keychain, used for local
+// runs when GITHUB_TOKEN isn't set.
+func authCmd(args []string) {
+	fs := flag.NewFlagSet("auth", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Println("Usage: autotagger auth login|logout")
+	}
+	fs.Parse(args)
+
+	switch fs.Arg(0) {
+	case "login":
+		fmt.Print("Github token: ")
+		tok, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && tok == "" {
+			fatalf("could not read token: %v", err)
+		}
+		tok = strings.TrimSpace(tok)
+		if tok == "" {
+			fatal("no token given")
+		}
+
+		if err := keychainSet(tok); err != nil {
+			fatalf("could not store token: %v", err)
+		}
+		fmt.Println("Token stored in the keychain")
+	case "logout":
+		if err := keychainDelete(); err != nil {
+			fatalf("could not remove token: %v", err)
+		}
+		fmt.Println("Token removed from the keychain")
+	default:
+		fs.Usage()
+		os.Exit(fatalExit)
+	}
+}
+
+// ghToken returns the token the Github CLI is logged in with for the
+// GITHUB_SERVER_URL host, so developers using gh don't have to manage a
+// second token. It returns "" when gh isn't installed or logged in.
+func ghToken() string {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return ""
+	}
+
+	out, err := exec.Command("gh", ghTokenArgs(os.Getenv("GITHUB_SERVER_URL"))...).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// ghTokenArgs returns the gh arguments printing the token for serverURL's
+// host, or gh's default host when it's unset.
+func ghTokenArgs(serverURL string) []string {
+	args := []string{"auth", "token"}
+	if u, err := url.Parse(serverURL); err == nil && u.Host != "" {
+		args = append(args, "--hostname", u.Host)
+	}
+	return args
+}
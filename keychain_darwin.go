@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// The macOS Keychain, through the security command line tool.
+
+func keychainGet() (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", keychainService, "-a", keychainAccount, "-w").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keychainSet(tok string) error {
+	// -w last without a value makes security prompt for the password, twice,
+	// and read it from stdin, keeping it out of ps
+	cmd := exec.Command("security", "add-generic-password", "-U", "-s", keychainService, "-a", keychainAccount, "-w")
+	cmd.Stdin = strings.NewReader(tok + "\n" + tok + "\n")
+	return cmd.Run()
+}
+
+func keychainDelete() error {
+	return exec.Command("security", "delete-generic-password", "-s", keychainService, "-a", keychainAccount).Run()
+}
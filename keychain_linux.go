@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+)
+
+// The Secret Service (GNOME Keyring, KWallet), through secret-tool.
+
+func keychainGet() (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", errNoKeychain
+	}
+
+	out, err := exec.Command("secret-tool", "lookup", "service", keychainService, "account", keychainAccount).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keychainSet(tok string) error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return errNoKeychain
+	}
+
+	// secret-tool reads the secret from stdin, keeping it out of ps
+	cmd := exec.Command("secret-tool", "store", "--label=autotagger Github token", "service", keychainService, "account", keychainAccount)
+	cmd.Stdin = strings.NewReader(tok)
+	return cmd.Run()
+}
+
+func keychainDelete() error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return errNoKeychain
+	}
+
+	return exec.Command("secret-tool", "clear", "service", keychainService, "account", keychainAccount).Run()
+}
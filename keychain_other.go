@@ -0,0 +1,18 @@
+//go:build !darwin && !linux && !windows
+// +build !darwin,!linux,!windows
+
+package main
+
+// There's no keychain support on other systems (yet).
+
+func keychainGet() (string, error) {
+	return "", errNoKeychain
+}
+
+func keychainSet(string) error {
+	return errNoKeychain
+}
+
+func keychainDelete() error {
+	return errNoKeychain
+}
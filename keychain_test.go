@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func Test_ghTokenArgs(t *testing.T) {
+	tests := []struct {
+		serverURL string
+		want      []string
+	}{
+		{serverURL: "", want: []string{"auth", "token"}},
+		{serverURL: "https://github.com", want: []string{"auth", "token", "--hostname", "github.com"}},
+		{serverURL: "https://ghe.example.com/", want: []string{"auth", "token", "--hostname", "ghe.example.com"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.serverURL, func(t *testing.T) {
+			if got := ghTokenArgs(tc.serverURL); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_ghToken(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gh is a shell script")
+	}
+
+	dir, err := ioutil.TempDir("", "autotagger")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir)
+	if got := ghToken(); got != "" {
+		t.Errorf("got %q without gh, want none", got)
+	}
+
+	script := "#!/bin/sh\n[ \"$*\" = \"auth token --hostname ghe.example.com\" ] && echo gho_token\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "gh"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GITHUB_SERVER_URL", "https://ghe.example.com")
+	defer os.Unsetenv("GITHUB_SERVER_URL")
+	if got := ghToken(); got != "gho_token" {
+		t.Errorf("got %q, want the token gh prints", got)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Windows' Credential Manager, called directly since it has no command line
+// tool that reads credentials back.
+
+var (
+	advapi32        = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW   = advapi32.NewProc("CredReadW")
+	procCredWriteW  = advapi32.NewProc("CredWriteW")
+	procCredDeleteW = advapi32.NewProc("CredDeleteW")
+	procCredFree    = advapi32.NewProc("CredFree")
+)
+
+const (
+	credTypeGeneric         = 1
+	credPersistLocalMachine = 2 // kept for the user on this machine, across logons
+)
+
+// credential is the CREDENTIALW structure.
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credTarget is the name the token is stored under.
+func credTarget() (*uint16, error) {
+	return syscall.UTF16PtrFromString(keychainService + ":" + keychainAccount)
+}
+
+func keychainGet() (string, error) {
+	target, err := credTarget()
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	if r, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); r == 0 {
+		return "", err
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	n := int(cred.CredentialBlobSize)
+	if n == 0 {
+		return "", nil
+	}
+	blob := (*[1 << 20]byte)(unsafe.Pointer(cred.CredentialBlob))[:n:n]
+	return string(blob), nil
+}
+
+func keychainSet(tok string) error {
+	target, err := credTarget()
+	if err != nil {
+		return err
+	}
+	user, err := syscall.UTF16PtrFromString(keychainAccount)
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(tok)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           user,
+	}
+	if r, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); r == 0 {
+		return err
+	}
+	return nil
+}
+
+func keychainDelete() error {
+	target, err := credTarget()
+	if err != nil {
+		return err
+	}
+
+	if r, _, err := procCredDeleteW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); r == 0 {
+		return err
+	}
+	return nil
+}
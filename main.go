@@ -1,10 +1,11 @@
 // This is a Github Action (https://developer.github.com/actions/) that attempts
 // to auto-tag releases.
 //
-// This action is meant to be triggered by a 'pull_request' change and therefore
-// receives from Github a PullRequestEvent from which to infer the information
-// needed to work its magic. It only increments the revision. For major and
-// minor changes, we can manually set a new tag.
+// This action is meant to be triggered by a 'pull_request' (or
+// 'pull_request_target') change and therefore receives from Github a
+// PullRequestEvent from which to infer the information needed to work its
+// magic. By default it only increments the revision, major and minor bumps
+// come from labels on the pull request or its commit message.
 package main
 
 import (
@@ -14,9 +15,16 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/google/go-github/v29/github"
 	"github.com/hashicorp/go-version"
@@ -26,20 +34,152 @@ import (
 var (
 	exConfig  = 78 // special code github actions take as "no error, but stop processing after that"
 	fatalExit = 1  // error code to exit with on fatal/fatalf
+
+	trace []string // decision trace, reported in the check run
 )
 
 func usage() {
-	fmt.Println("Usage: autotagger")
+	fmt.Println("Usage: autotagger [command] [flags]")
+	fmt.Println()
+	fmt.Println("Without a command, tags the pull request merge commit described by the Github event,")
+	fmt.Println("or the branch a workflow_dispatch event asks for with its level, ref and version inputs.")
+	fmt.Println("Commands run against GITHUB_REPOSITORY (owner/repo), use -h for their flags:")
+	fmt.Println("    backfill         tag the PRs merged since the last tag, or only report what would be tagged.")
+	fmt.Println("    migrate          re-tag the versions under one prefix with another, e.g. after moving to a monorepo.")
+	fmt.Println("    last             print the last version's tag, also setting outputs, e.g. for cache keys.")
+	fmt.Println("    versions         list the versions per prefix, flagging malformed and duplicate tags.")
+	fmt.Println("    stats            report the release cadence per prefix, as markdown or JSON.")
+	fmt.Println("    promote          tag the commit of a prerelease (-from v1.4.0-rc.3) as the stable version.")
+	fmt.Println("    org              backfill every repository of an organization, or those in REPOSITORIES.")
+	fmt.Println("    auth             login or logout, storing a token in the OS keychain for local runs.")
+	fmt.Println("    flush            release the queued PRs whose release gates have cleared.")
+	fmt.Println("    release          with AGGREGATE, tag everything merged since the last release as one version.")
+	fmt.Println("    replay           run the decision for a RECORD-ed event offline: replay <recording.json>.")
+	fmt.Println("    test             check the release policy against a table of recorded scenarios: test <scenarios.json>.")
+	fmt.Println()
 	fmt.Println("You can also set the following environment variables:")
 	fmt.Println("    NO_EX_CONFIG     disables the EX_CONFIG returns, returning success instead")
 	fmt.Println("    NEVER_FAIL       in cases where the bot should fail, it will return EX_CONFIG instead")
+	fmt.Println("    DRY_RUN          only print the tag and release notes it would create (and check run with CHECK_RUN).")
 	fmt.Println("    FILE_REGEXP      only tag when changes since the last tag include files that match this regex (default: .*).")
-	fmt.Println("    TAG_PREFIX       prefix your tag with this. Great for Go modules in a subdir!")
+	fmt.Println("    GLOBAL_PATHS     comma-separated paths, directories/ or globs that match for every component.")
+	fmt.Println("    IGNORE_DELETIONS don't count deleted files as changes.")
+	fmt.Println("    IGNORE_RENAMES   don't count renames as changes, unless the content changed too.")
+	fmt.Println("    TAG_PREFIX       prefix your tag with this. Great for Go modules in a subdir! Comma-separated for several.")
+	fmt.Println("    SEED_FROM_UNPREFIXED  start a new TAG_PREFIX from the highest unprefixed version.")
+	fmt.Println("    NO_TAGS          what to do when there are no versions under TAG_PREFIX yet:")
+	fmt.Println("                     fail, skip or bootstrap the first version (default: fail).")
+	fmt.Println("    BOOTSTRAP_TAGS   true to release v0.1.0, or INITIAL_VERSION, when there are no versions yet.")
+	fmt.Println("    INITIAL_VERSION  the first version when bootstrapping, e.g. v0.1.0 (implies NO_TAGS=bootstrap).")
+	fmt.Println("    MIN_VERSION      the least version to release, e.g. v2.0.0 when the last one is below it.")
+	fmt.Println("    MIXED_TAGS       what to do when unprefixed versions overlap the ones under TAG_PREFIX:")
+	fmt.Println("                     ignore, warn or fail (default: ignore).")
+	fmt.Println("    VERSION_SCHEME   semver or calver, numbering versions by release date (default: semver).")
+	fmt.Println("    CALVER_FORMAT    the calver format, like YY.0M.0D (default: YYYY.0M.MICRO).")
+	fmt.Println("    VERSION_SEGMENTS 3, or 4 for versions like v1.2.3.4 (default: 3).")
+	fmt.Println("    PATCH_SEGMENT    the number patch bumps increment, 3 or 4 (default: the last).")
+	fmt.Println("    VERSION_RESET    the bumps resetting the numbers after theirs, or none (default: major,minor,patch).")
+	fmt.Println("    V_PREFIX         false to tag 1.2.3 rather than v1.2.3 (default: like the existing tags).")
+	fmt.Println("    TAG_TEMPLATE     Go template for tags, e.g. {{.Prefix}}v{{.Major}}.{{.Minor}}.{{.Patch}}.")
+	fmt.Println("    PRERELEASE       tag prereleases on this channel (e.g. rc for vX.Y.Z-rc.N) instead of final releases.")
+	fmt.Println("    BRANCH_CHANNELS  branch=channel pairs picking PRERELEASE by base branch, e.g. develop=beta,main=.")
+	fmt.Println("    TAG_CONFLICT     what to do when the next tag already exists on another commit:")
+	fmt.Println("                     fail, skip, bump-again or force-move (prereleases only) (default: fail).")
+	fmt.Println("    BUMP_FROM_TITLE  read the bump from keywords in the PR title, see TITLE_KEYWORDS.")
+	fmt.Println("    TITLE_KEYWORDS   keyword=bump pairs for BUMP_FROM_TITLE (default: [major]=major,breaking=major,...).")
+	fmt.Println("    APIDIFF          bump according to the Go API changes apidiff finds in the module at COMPONENT_PATH.")
+	fmt.Println("    GORELEASE        check the version with gorelease before tagging: fail, or comment and tag anyway.")
+	fmt.Println("    BUMP_FLOOR       pattern=bump pairs for the least bump when matching files change, e.g. api/=minor.")
+	fmt.Println("    BUMP_CAP         pattern=bump pairs for the most bump when only matching files change, e.g. internal/=patch.")
+	fmt.Println("    CAP_MAJOR        never bump the major version, major bumps are minor ones.")
+	fmt.Println("    BUMP_PATHS       pattern=bump pairs bumping according to the changed files, e.g. api/=minor.")
+	fmt.Println("    BUMP_FROM_COMMITS  read the bump from the Conventional Commits in the PR.")
+	fmt.Println("    BUMP_FROM_COMMIT read the bump from the Conventional Commits in a squash merge's message.")
+	fmt.Println("    STALE_AFTER      don't release merges older than this (e.g. 336h) without confirmation.")
+	fmt.Println("    CONFIRM_LABEL    label confirming the release of a stale merge (default: confirm-release).")
+	fmt.Println("    CONFIRM_RELEASE  confirm the release of a stale merge, for re-runs.")
+	fmt.Println("    TOKEN_KIND       github_token, app or pat, when it can't be told from GITHUB_TOKEN.")
+	fmt.Println("    RELEASE_EVENT    repository_dispatch event sent on releases, like autotagger-release (default: none).")
+	fmt.Println("    RELEASE_REPOSITORY  owner/repo to tag releases in, like a public mirror, commenting on PRs here.")
+	fmt.Println("    RELEASE_TOKEN    token for RELEASE_REPOSITORY (default: GITHUB_TOKEN).")
+	fmt.Println("    REPOSITORIES     comma-separated owner/repo list for autotagger org, when -repos isn't given.")
+	fmt.Println("    FREEZE           true to comment the next version on merged PRs without tagging it, for code freezes.")
+	fmt.Println("    CONFIRM_MAJOR    hold major bumps until a label or a maintainer's /confirm-major comment confirms them.")
+	fmt.Println("    BREAKING_LABEL   label confirming a major bump (default: confirmed-breaking).")
+	fmt.Println("    RATE_LIMIT_MIN   minimum remaining API requests needed to start (default: 50).")
+	fmt.Println("    RATE_LIMIT_WARN  only warn instead of aborting when below RATE_LIMIT_MIN.")
+	fmt.Println("    RETRY_ATTEMPTS   attempts at each request failing with a server error or rate limit (default: 3).")
+	fmt.Println("    RETRY_BACKOFF    wait before the first retry, doubling after that (default: 1s).")
+	fmt.Println("    RETRY_JITTER     fraction of the wait added at random, 0 to 1 (default: 0.5).")
+	fmt.Println("    MAX_PAGES        stop after fetching this many pages from the API in one run (default: unlimited).")
+	fmt.Println("    MAX_TAGS         stop after scanning this many tags in one run (default: unlimited).")
+	fmt.Println("    MAX_MUTATIONS    stop after this many write requests in one run (default: unlimited).")
+	fmt.Println("    RELEASE_LABEL    label the PR with the version it was released in (\"released: vX.Y.Z\").")
+	fmt.Println("    ISSUE_COMMENTS   comment \"Fixed in vX.Y.Z\" on the issues the PR closes.")
+	fmt.Println("    COMMIT_STATUS    report the outcome as an autotagger/release status on the merge commit.")
+	fmt.Println("    RELEASE_BRANCHES cut a release/vX.Y branch at minor and major releases, for hotfixes.")
+	fmt.Println("    MAJOR_TAG        move the major version tag (v1) to each release, for Github Actions.")
+	fmt.Println("    PIN_FILES        comma-separated files whose uses: pins are updated in a PR after releases.")
+	fmt.Println("    PIN_BRANCH       branch the pins PR is made from (default: autotagger/pins).")
+	fmt.Println("    REFRESH_FILES    comma-separated files, like badges or version.go, whose version is updated in a PR after releases.")
+	fmt.Println("    REFRESH_BRANCH   branch the refresh PR is made from (default: autotagger/refresh).")
+	fmt.Println("    REFRESH_COMMIT   commit REFRESH_FILES to the base branch instead of opening a PR.")
+	fmt.Println("    CHECK_RUN        report the decision trace as an autotagger check run on the merge commit.")
+	fmt.Println("    PROVENANCE       attach a signed statement of each release decision as a check run.")
+	fmt.Println("    MENTION          comma-separated users or teams (org/team) to @-mention in the comment.")
+	fmt.Println("    COMMENT_SIGNATURE  line to sign comments with, e.g. the name of your release bot.")
+	fmt.Println("    BOT_LOGIN        login the token comments as, like my-app[bot] for a Github App (default: detected).")
+	fmt.Println("    TAGGER_NAME      create annotated tags with this tagger name (needs TAGGER_EMAIL).")
+	fmt.Println("    TAGGER_EMAIL     create annotated tags with this tagger email (needs TAGGER_NAME).")
+	fmt.Println("    PROMOTE_LABEL    PRs with this label promote the last prerelease instead (default: promote).")
+	fmt.Println("    SOAK_TIME        how long the last prerelease must be out, with no newer one, to be promoted (e.g. 48h).")
+	fmt.Println("    PROMOTE          promote the last prerelease instead of releasing the merge, for workflow inputs.")
+	fmt.Println("    DIGEST_AFTER     backfills and aggregate releases covering more PRs send one digest instead.")
+	fmt.Println("    FAILURE_COMMENTS comment on the PR how to retry when its release fails.")
+	fmt.Println("    QUIET_LABEL      PRs with this label are tagged without a comment (default: quiet-release).")
+	fmt.Println("    COMMENT_TEMPLATE        Go template for the PR comment.")
+	fmt.Println("    ISSUE_COMMENT_TEMPLATE  Go template for the ISSUE_COMMENTS comment.")
+	fmt.Println("    AGGREGATE        don't tag merges, leave it to a scheduled or manual autotagger release.")
+	fmt.Println("    RELEASE_PR       propose releases in a PR updating the changelog, and tag when it's merged.")
+	fmt.Println("    RELEASE_PR_BRANCH  branch the release PR is made from (default: autotagger/release).")
+	fmt.Println("    CHANGELOG_PATH   changelog the release PR prepends the release notes to (default: CHANGELOG.md).")
+	fmt.Println("    VERSION_FILE     file the release PR writes the version to, if any.")
+	fmt.Println("    NOTES_FRAGMENTS  directory of per-PR note files, e.g. changes.d, to add to the release notes and then remove.")
+	fmt.Println("    NOTES_CATEGORIES group the release notes by PR label, e.g. kind/feature=Features,kind/bug=Bug Fixes.")
+	fmt.Println("    RECORD           record the event and API responses to this file, for bug reports.")
+	fmt.Println("    ERROR_WEBHOOK    URL to POST a JSON report to on fatal errors and panics.")
+	fmt.Println("    MANIFEST_BRANCH  keep a JSON manifest of the latest version per prefix on this branch.")
+	fmt.Println("    MANIFEST_PATH    path of the manifest on MANIFEST_BRANCH (default: versions.json).")
+	fmt.Println("    BADGE_BRANCH     publish a shields.io endpoint badge of the latest version on this branch.")
+	fmt.Println("    BADGE_PATH       path of the badge on BADGE_BRANCH (default: badge.json, or <prefix>-badge.json).")
+	fmt.Println("    BADGE_LABEL      label shown on the badge (default: version).")
+	fmt.Println("    GIT_NOTES        record the release metadata as a git note on the tagged commit.")
+	fmt.Println("    NOTES_REF        notes ref used by GIT_NOTES (default: refs/notes/autotagger).")
+	fmt.Println("    IMAGE            container image to tag with the version too, e.g. ghcr.io/org/app.")
+	fmt.Println("    IMAGE_SOURCE_TAG Go template for the image tag to copy (default: sha-{{.ShortSHA}}).")
+	fmt.Println("    IMAGE_LATEST     also tag the image as latest, except for prereleases.")
+	fmt.Println("    REGISTRY_USERNAME, REGISTRY_PASSWORD  credentials for the IMAGE registry.")
+	fmt.Println("    GORELEASER       export GORELEASER_CURRENT_TAG and GORELEASER_PREVIOUS_TAG to later steps.")
+	fmt.Println("    GORELEASER_RUN   also run goreleaser right after tagging.")
+	fmt.Println("    GORELEASER_ARGS  arguments for GORELEASER_RUN (default: release --clean).")
+	fmt.Println("    CODEOWNERS       add the component's CODEOWNERS owners to the release output and trace.")
+	fmt.Println("    COMPONENT_PATH   the component's directory, for CODEOWNERS (default: TAG_PREFIX).")
+	fmt.Println("    MODULES_FILE     JSON file mapping directories to prefixes, to tag every module a PR changes.")
+	fmt.Println("    MODULES_CASCADE  also tag the modules depending on changed ones, by their depends and go.mod.")
+	fmt.Println("    DEPLOY_ENVIRONMENTS  prefix=environment pairs to create a deployment of each release to.")
+	fmt.Println("    REQUIRE_STATUS   hold back releases until the merge commit's status is successful.")
+	fmt.Println("    APPROVAL_ENVIRONMENT  hold back releases until approved in this protected environment.")
+	fmt.Println("    EMAIL_TO         comma-separated addresses to email release announcements to.")
+	fmt.Println("    EMAIL_FROM       sender of the announcements.")
+	fmt.Println("    EMAIL_SUBJECT, EMAIL_TEMPLATE  Go templates for the announcement subject and body.")
+	fmt.Println("    SMTP_ADDR        host:port of the SMTP server, SMTP_USERNAME and SMTP_PASSWORD to log in.")
 
 	os.Exit(fatalExit)
 }
 
 func main() {
+	defer reportPanic()
+
 	if os.Getenv("NO_EX_CONFIG") == "true" {
 		exConfig = 0
 	}
@@ -49,30 +189,62 @@ func main() {
 		fatalExit = exConfig
 	}
 
-	fileRE := ".*"
-	if fe, ok := os.LookupEnv("FILE_REGEXP"); ok {
-		fileRE = fe
+	if len(os.Args) > 1 && os.Args[1] == "auth" {
+		authCmd(os.Args[2:])
+		return
 	}
 
-	fileMatch := regexp.MustCompile(fileRE)
+	cfg := loadConfig()
 
-	prefix := os.Getenv("TAG_PREFIX")
+	if len(os.Args) > 1 && len(cfg.prefixes) > 0 && os.Args[1] != "stats" {
+		fatalf("TAG_PREFIX lists several prefixes, set one for autotagger %s", os.Args[1])
+	}
 
-	// limit this action to pull requests only
-	triggerName := os.Getenv("GITHUB_EVENT_NAME")
-	if triggerName != "pull_request" {
-		log.Printf("Ignoring trigger %s", triggerName)
-		os.Exit(exConfig)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backfill":
+			backfillCmd(cfg, os.Args[2:])
+		case "migrate":
+			migrateCmd(cfg, os.Args[2:])
+		case "last":
+			lastCmd(cfg, os.Args[2:])
+		case "versions":
+			versionsCmd(cfg, os.Args[2:])
+		case "stats":
+			statsCmd(cfg, os.Args[2:])
+		case "promote":
+			promoteCmd(cfg, os.Args[2:])
+		case "org":
+			orgCmd(cfg, os.Args[2:])
+		case "flush":
+			flushCmd(cfg, os.Args[2:])
+		case "replay":
+			replayCmd(cfg, os.Args[2:])
+		case "test":
+			testCmd(cfg, os.Args[2:])
+		case "release":
+			releaseCmd(cfg, os.Args[2:])
+		default:
+			usage()
+		}
+		return
 	}
 
-	// create a github client
-	tok := os.Getenv("GITHUB_TOKEN")
-	if tok == "" {
-		fatal("You must enable GITHUB_TOKEN access for this action")
+	// limit this action to pull requests, and releases on demand. Pull
+	// request_target carries the same payload, but runs with the base
+	// repository's token for PRs from forks too.
+	triggerName := os.Getenv("GITHUB_EVENT_NAME")
+	if triggerName == "workflow_dispatch" {
+		if len(cfg.prefixes) > 0 {
+			fatal("TAG_PREFIX lists several prefixes, set the one to release on workflow_dispatch")
+		}
+		dispatchCmd(cfg)
+		return
+	}
+	if triggerName != "pull_request" && triggerName != "pull_request_target" {
+		log.Printf("Ignoring trigger %s", triggerName)
+		exitSkipped("not_pull_request")
 	}
-	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: tok})
-	oc := oauth2.NewClient(context.Background(), ts)
-	c := github.NewClient(oc)
 
 	// Read the trigger event information
 	b, err := ioutil.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
@@ -84,112 +256,831 @@ func main() {
 	if err := json.Unmarshal(b, &se); err != nil {
 		fatalf("could not unmarshal event info: %v", err)
 	}
+	if se.PullRequest == nil || se.Repo == nil {
+		fatal("event info is missing the pull request or repository")
+	}
 
-	if *se.Action != "closed" || !*se.PullRequest.Merged {
-		fmt.Printf("PR not ready to tag (action: %s, merged: %v)\n", *se.Action, *se.PullRequest.Merged)
-		os.Exit(exConfig)
+	// merged is null in some payloads, like PRs merged by automation. Those
+	// are resolved through the API below.
+	if se.GetAction() != "closed" || (se.PullRequest.Merged != nil && !*se.PullRequest.Merged) {
+		fmt.Printf("PR not ready to tag (action: %s, merged: %v)\n", se.GetAction(), se.PullRequest.GetMerged())
+		exitSkipped("not_merged")
 	}
 
-	ref := se.PullRequest.GetMergeCommitSHA()
-	if ref == "" {
-		fatal("Could not find the merge commit")
+	reportContext["repository"] = se.GetRepo().GetFullName()
+	reportContext["pull_request"] = se.PullRequest.GetNumber()
+	if id := os.Getenv("GITHUB_RUN_ID"); id != "" {
+		reportContext["run"] = fmt.Sprintf("%s/actions/runs/%s", se.GetRepo().GetHTMLURL(), id)
+	}
+
+	if err := checkRepos(&se, os.Getenv("GITHUB_REPOSITORY")); err != nil {
+		fatal(err)
+	}
+
+	if triggerName == "pull_request_target" {
+		if err := checkTargetSafety(cfg, &se); err != nil {
+			fatal(err)
+		}
 	}
 
 	ctx := context.Background()
 
 	owner, repo := se.GetRepo().GetOwner().GetLogin(), se.GetRepo().GetName()
-	cli := &client{c, owner, repo}
+	cli := &client{c: newGithubClient(ctx), owner: owner, repo: repo, url: se.GetRepo().GetHTMLURL()}
+
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	ref := se.PullRequest.GetMergeCommitSHA()
+	if se.PullRequest.Merged == nil || ref == "" {
+		ref, err = cli.mergeCommit(ctx, se.PullRequest)
+		if err != nil {
+			fatal(err)
+		}
+		if ref == "" {
+			fmt.Printf("PR #%d was closed without being merged\n", se.PullRequest.GetNumber())
+			exitSkipped("not_merged")
+		}
+	}
+	reportContext["sha"] = ref
+	if cfg.modulesFile != "" {
+		cli.releaseModules(ctx, cfg, se.PullRequest)
+		return
+	}
+	if len(cfg.prefixes) > 0 {
+		releasePrefixes(cfg)
+		return
+	}
+	if cfg.failureComments {
+		cli.commentFailures(ctx, cfg, se.PullRequest.GetNumber(), se.PullRequest.GetBase().GetRef())
+	}
+	cfg.useBranch(se.PullRequest.GetBase().GetRef())
 
-	lastVersion, err := cli.getLastVersion(ctx, prefix)
+	if cfg.staleAfter > 0 && !cfg.confirmed {
+		if age := time.Since(se.PullRequest.GetMergedAt()); se.PullRequest.MergedAt != nil && age > cfg.staleAfter {
+			ok, err := cli.hasCurrentLabel(ctx, se.PullRequest.GetNumber(), cfg.confirmLabel)
+			if err != nil {
+				fatalf("could not get labels of PR #%d: %v", se.PullRequest.GetNumber(), err)
+			}
+			if !ok {
+				fmt.Printf("PR #%d was merged %s ago, longer than STALE_AFTER. Label it %q or set CONFIRM_RELEASE to release it.\n",
+					se.PullRequest.GetNumber(), age.Round(time.Hour), cfg.confirmLabel)
+				exitSkipped("stale")
+			}
+			tracef("PR #%d was merged %s ago, releasing as confirmed by its %q label", se.PullRequest.GetNumber(), age.Round(time.Hour), cfg.confirmLabel)
+		}
+	}
+
+	lastVersion, base, err := cli.lastRelease(ctx, cfg)
+	if err == errNoVersions && cfg.noTags == noTagsSkip {
+		fmt.Printf("No versions under %q yet, not tagging\n", cfg.prefix)
+		exitSkipped("no_versions")
+	}
 	if err != nil {
 		fatal(err)
 	}
 
-	base := prefix + "v" + lastVersion.String()
+	if base != "" {
+		tracef("Last version is %s", base)
+	}
+
+	promoting := cfg.promote || (cfg.promoteLabel != "" && hasLabel(se.PullRequest.Labels, cfg.promoteLabel))
 
-	if !cli.shouldTag(ctx, base, ref, fileMatch) {
-		fmt.Println("No changes matching pattern. This code won't be tagged.")
+	var d decision
+	if promoting {
+		d = cli.decidePromotion(ctx, cfg, lastVersion, base, ref)
+	} else if cfg.aggregate {
+		tracef("Merged into the next aggregate release, tagged by autotagger release")
+		d = decision{ref: ref, previous: base, skip: skipAggregate}
+	} else if cfg.releasePR && isReleasePR(cfg, se.PullRequest) {
+		d = cli.decideReleasePR(ctx, cfg, base, ref, se.PullRequest)
+	} else {
+		d = cli.decide(ctx, cfg, se.PullRequest, lastVersion, base, base, ref)
+		if cfg.releasePR && !cfg.dryRun && d.skip == "" && d.action != tagExists {
+			if err := cli.proposeRelease(ctx, cfg, d, se.PullRequest); err != nil {
+				fatalf("could not propose release: %v", err)
+			}
+			if err := setOutput("skip_reason", "release_pr"); err != nil {
+				log.Printf("Could not set skip_reason output: %v", err)
+			}
+			fmt.Println("Done")
+			return
+		}
+	}
+	if d.version != "" {
+		reportContext["version"] = d.version
+	}
+	if cfg.freeze && !cfg.dryRun && d.skip == "" && d.action != tagExists {
+		cli.holdFrozen(ctx, cfg, d, se.PullRequest)
+	}
+	if cfg.confirmMajor && !cfg.dryRun && d.skip == "" && d.action != tagExists && isMajorBump(d.previous, d.version, cfg.prefix) {
+		cli.holdMajorBump(ctx, cfg, d, se.PullRequest)
+	}
+	if cfg.dryRun {
+		cli.preview(ctx, cfg, d, se.PullRequest)
+		fmt.Println("Done")
 		return
 	}
+	if d.skip == "" {
+		if reason := cli.gate(ctx, cfg, d); reason != "" {
+			tracef("Release of %s is blocked: %s. Queueing it for autotagger flush.", d.version, reason)
+			if err := cli.enqueue(ctx, se.PullRequest.GetNumber()); err != nil {
+				fatalf("could not queue release: %v", err)
+			}
+			d.skip = skipGated
+		}
+	}
+	cli.apply(ctx, cfg, d, se.PullRequest)
+	if promoting && d.skip == "" {
+		if err := cli.supersedeRelease(ctx, base, d.version); err != nil {
+			fatalf("could not mark release %s as superseded: %v", base, err)
+		}
+	}
 
-	version := nextVersion(lastVersion, prefix)
+	if d.skip == skipConflict || d.skip == skipGated || d.skip == skipAggregate || d.skip == skipNoPrerelease || d.skip == skipSoaking {
+		os.Exit(exConfig)
+	}
+	fmt.Println("Done")
+}
 
-	_, _, err = c.Git.CreateRef(ctx, owner, repo, &github.Reference{
-		Ref:    github.String(fmt.Sprintf("refs/tags/%s", version)),
-		Object: &github.GitObject{SHA: &ref, Type: github.String("commit")},
-	})
-	if err != nil {
-		fatalf("could not create tag for ref %s: %v", ref, err)
+// checkRepos makes sure the event's repository, where the tag is created, is
+// where the PR was merged and where this workflow runs, the workflowRepo if
+// it's known. Forks and transferred repos can otherwise point us at the
+// wrong place.
+func checkRepos(se *github.PullRequestEvent, workflowRepo string) error {
+	eventRepo := se.GetRepo().GetFullName()
+	if baseRepo := se.PullRequest.GetBase().GetRepo().GetFullName(); !strings.EqualFold(baseRepo, eventRepo) {
+		return fmt.Errorf("PR base repository %s doesn't match event repository %s", baseRepo, eventRepo)
+	}
+	if workflowRepo != "" && !strings.EqualFold(workflowRepo, eventRepo) {
+		return fmt.Errorf("event repository %s doesn't match workflow repository %s", eventRepo, workflowRepo)
 	}
+	return nil
+}
 
-	fmt.Println("Tagged version", version)
+// checkTargetSafety refuses pull_request_target runs that would execute code
+// from the workspace, since the token can write to the repository and the
+// workspace may hold a fork's code. Everything else only reads the event and
+// talks to the API about the base repository.
+func checkTargetSafety(cfg *config, se *github.PullRequestEvent) error {
+	if head := se.PullRequest.GetHead().GetRepo().GetFullName(); !strings.EqualFold(head, se.GetRepo().GetFullName()) {
+		tracef("PR comes from fork %s, working on %s only", head, se.GetRepo().GetFullName())
+	}
 
-	_, _, err = c.Issues.CreateComment(ctx, owner, repo, se.PullRequest.GetNumber(), &github.IssueComment{
-		Body: github.String(fmt.Sprintf("Your friendly autotagging bot has tagged this as release **%s**", version)),
-	})
-	if err != nil {
-		fatalf("could not create comment: %v", err)
+	if cfg.goreleaserRun {
+		return errors.New("GORELEASER_RUN runs code from the workspace and isn't allowed on pull_request_target, use GORELEASER to hand off to a separate workflow instead")
 	}
-	fmt.Println("Done")
+	return nil
+}
+
+// newGithubClient creates a github client authenticated with GITHUB_TOKEN,
+// or for local runs, with the token stored by `autotagger auth login` or the
+// one the Github CLI is logged in with.
+func newGithubClient(ctx context.Context) *github.Client {
+	tok := os.Getenv("GITHUB_TOKEN")
+	if tok == "" {
+		tok, _ = keychainGet()
+	}
+	if tok == "" {
+		tok = ghToken()
+	}
+	if tok == "" {
+		fatal("You must enable GITHUB_TOKEN access for this action")
+	}
+	return newTokenClient(ctx, tok)
+}
+
+// apiTransport sends the run's API requests, retrying, recording and
+// charging them to the budget. It's set up with the first client and shared
+// by the others, whatever their token.
+var apiTransport http.RoundTripper
+
+// newTokenClient creates a github client authenticated with tok.
+func newTokenClient(ctx context.Context, tok string) *github.Client {
+	if apiTransport == nil {
+		var t http.RoundTripper = &retryTransport{http.DefaultTransport, newRetryPolicy()}
+		if path := os.Getenv("RECORD"); path != "" {
+			t = newRecordTransport(t, path)
+		}
+		runBudget = newBudget()
+		apiTransport = &budgetTransport{t, runBudget}
+	}
+
+	ts := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: tok})
+	base := context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: apiTransport})
+	return github.NewClient(oauth2.NewClient(base, ts))
 }
 
 type client struct {
 	c     *github.Client
 	owner string
 	repo  string
+	url   string // the repository's web page
+
+	release *client // the repository releases are tagged in, when it's another one
+	login   string  // the account commenting, see commenter
 }
 
-func (c *client) getLastVersion(ctx context.Context, prefix string) (*version.Version, error) {
+// mergeCommit resolves the commit a closed PR resulted in, for payloads that
+// don't say. The PR is fetched again since the event can be stale, and when
+// there's still no merge commit the PR's last commit is used, as long as it
+// made it onto the base branch. It returns "" if the PR wasn't merged.
+func (c *client) mergeCommit(ctx context.Context, pr *github.PullRequest) (string, error) {
+	fresh, _, err := c.c.PullRequests.Get(ctx, c.owner, c.repo, pr.GetNumber())
+	if err != nil {
+		return "", fmt.Errorf("could not get PR #%d: %v", pr.GetNumber(), err)
+	}
+	if !fresh.GetMerged() {
+		return "", nil
+	}
+	if sha := fresh.GetMergeCommitSHA(); sha != "" {
+		tracef("Resolved merge commit %s from the API", sha)
+		return sha, nil
+	}
+
+	var last string
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		commits, resp, err := c.c.PullRequests.ListCommits(ctx, c.owner, c.repo, pr.GetNumber(), opt)
+		if err != nil {
+			return "", fmt.Errorf("could not list commits of PR #%d: %v", pr.GetNumber(), err)
+		}
+		if len(commits) > 0 {
+			last = commits[len(commits)-1].GetSHA()
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+	if last == "" {
+		return "", errors.New("Could not find the merge commit")
+	}
+
+	// Rebase merges put new commits on the base branch, so the PR's own
+	// commit only counts when it's there unchanged.
+	base := fresh.GetBase().GetRef()
+	cmp, _, err := c.c.Repositories.CompareCommits(ctx, c.owner, c.repo, base, last)
+	if err != nil {
+		return "", fmt.Errorf("could not compare %s with %s: %v", last, base, err)
+	}
+	if st := cmp.GetStatus(); st != "identical" && st != "behind" {
+		return "", fmt.Errorf("Could not find the merge commit: %s is not on %s", last, base)
+	}
+
+	tracef("Resolved merge commit %s from the PR's commits", last)
+	return last, nil
+}
+
+// errNoVersions is returned when there's no version tag yet.
+var errNoVersions = errors.New("could not find any versions")
+
+// getLastVersion returns the highest version tagged under prefix, along with
+// the name of its tag. With keep, only versions it keeps count.
+func (c *client) getLastVersion(ctx context.Context, prefix string, keep func(*version.Version) bool) (*version.Version, string, error) {
 	last, err := version.NewSemver("v0.0.0")
 	if err != nil {
-		return nil, fmt.Errorf("could not create base version: %v", err)
+		return nil, "", fmt.Errorf("could not create base version: %v", err)
+	}
+	var lastTag string
+
+	err = c.forEachTag(ctx, func(name string, r *github.Reference) {
+		if !strings.HasPrefix(name, prefix) {
+			return
+		}
+
+		tag := strings.TrimPrefix(name, prefix)
+		v, err := version.NewSemver(tag)
+		if err != nil {
+			fmt.Printf("Tag %v is not a valid semver, ignoring", tag)
+			return
+		}
+		if keep != nil && !keep(v) {
+			return
+		}
+		if v.GreaterThan(last) {
+			fmt.Println("Found newer version:", v)
+			last, lastTag = v, name
+		}
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	if lastTag == "" {
+		return nil, "", errNoVersions
+	}
+
+	return last, lastTag, nil
+}
+
+// policies for when there are no versions under the prefix yet
+const (
+	noTagsFail      = "fail"      // exit with an error
+	noTagsSkip      = "skip"      // don't tag anything
+	noTagsBootstrap = "bootstrap" // release the first version
+)
+
+// policies for when both prefixed and unprefixed versions exist ambiguously
+const (
+	mixedTagsIgnore = "ignore" // don't check
+	mixedTagsWarn   = "warn"   // say so and carry on
+	mixedTagsFail   = "fail"   // exit with an error
+)
+
+// checkContinuity applies the MIXED_TAGS policy to the tags under the
+// prefix and the unprefixed ones.
+func (c *client) checkContinuity(ctx context.Context, cfg *config) error {
+	h := &historySplit{prefix: cfg.prefix}
+	err := c.forEachTag(ctx, func(name string, r *github.Reference) {
+		h.add(name)
+	})
+	if err != nil {
+		return err
+	}
+
+	problem := h.problem()
+	switch {
+	case problem == "":
+		return nil
+	case cfg.mixedTags == mixedTagsFail:
+		return fmt.Errorf("ambiguous version history: %s", problem)
+	}
+	fmt.Println("Warning: ambiguous version history:", problem)
+	return nil
+}
+
+// splitHistory looks for unprefixed versions overlapping the ones under
+// prefix among the tag names, which happens when releases carry on without
+// the prefix after adopting it, or it's adopted without continuing from
+// the unprefixed versions. It describes the overlap, or returns "".
+func splitHistory(names []string, prefix string) string {
+	h := &historySplit{prefix: prefix}
+	for _, name := range names {
+		h.add(name)
+	}
+	return h.problem()
+}
+
+// historySplit is what splitHistory looks for, kept as tags are listed
+// rather than holding on to all of them.
+type historySplit struct {
+	prefix            string
+	first, last       *version.Version // the first prefixed and last unprefixed versions
+	firstTag, lastTag string
+}
+
+// add takes the tag name into account.
+func (h *historySplit) add(name string) {
+	if strings.HasPrefix(name, h.prefix) {
+		v, err := version.NewSemver(strings.TrimPrefix(name, h.prefix))
+		if err == nil && (h.first == nil || v.LessThan(h.first)) {
+			h.first, h.firstTag = v, name
+		}
+		return
+	}
+
+	v, err := version.NewSemver(name)
+	if err == nil && (h.last == nil || v.GreaterThan(h.last)) {
+		h.last, h.lastTag = v, name
+	}
+}
+
+// problem describes the overlap among the tags added, or returns "".
+func (h *historySplit) problem() string {
+	if h.first == nil || h.last == nil || h.last.LessThan(h.first) {
+		return ""
 	}
+	return fmt.Sprintf("unprefixed %s isn't older than %s, the first version under %q", h.lastTag, h.firstTag, h.prefix)
+}
+
+// lastRelease returns the version to bump from and its tag. When there's no
+// version under the prefix yet, it can be seeded from the unprefixed tags,
+// for repos adopting a prefix after releasing without one. Failing that, the
+// NO_TAGS policy applies: bootstrapping bumps from v0.0.0 with no previous
+// tag, otherwise errNoVersions is returned. With a prefix, the MIXED_TAGS
+// policy checks the prefixed versions continue from the unprefixed ones.
+func (c *client) lastRelease(ctx context.Context, cfg *config) (*version.Version, string, error) {
+	var keep func(*version.Version) bool
+	if len(cfg.channels) > 0 {
+		keep = func(v *version.Version) bool { return onChannel(v, cfg.prerelease) }
+	}
+	if l := cfg.line; l != nil {
+		onLine, ch := l.has, keep
+		keep = func(v *version.Version) bool { return onLine(v) && (ch == nil || ch(v)) }
+	}
+
+	last, tag, err := c.getLastVersion(ctx, cfg.prefix, keep)
+	if err == nil && cfg.mixedTags != mixedTagsIgnore && cfg.prefix != "" {
+		if err := c.checkContinuity(ctx, cfg); err != nil {
+			return nil, "", err
+		}
+	}
+	if err == nil && cfg.detectV {
+		detectVPrefix(tag, cfg.prefix)
+	}
+	if err != errNoVersions {
+		return last, tag, err
+	}
+	if cfg.line != nil {
+		// a line starts with the release it was cut for
+		return nil, "", fmt.Errorf("no versions under %q on the %s maintenance line", cfg.prefix, cfg.line)
+	}
+
+	if cfg.seedUnprefixed && cfg.prefix != "" {
+		last, tag, err = c.getLastVersion(ctx, "", keep)
+		if err == nil {
+			tracef("No versions under %q yet, seeding from unprefixed %s", cfg.prefix, tag)
+			if cfg.detectV {
+				detectVPrefix(tag, "")
+			}
+			return last, tag, nil
+		}
+		if err != errNoVersions {
+			return nil, "", err
+		}
+	}
+
+	if cfg.noTags != noTagsBootstrap {
+		return nil, "", errNoVersions
+	}
+
+	tracef("No versions under %q yet, bootstrapping the first one", cfg.prefix)
+	last, err = version.NewSemver("v0.0.0")
+	return last, "", err
+}
+
+// defaultInitialVersion is the first version with BOOTSTRAP_TAGS and no
+// INITIAL_VERSION.
+const defaultInitialVersion = "v0.1.0"
+
+// initialTag returns the tag of the INITIAL_VERSION, or of its first
+// prerelease on the channel. Calendar versions are tagged as written.
+func initialTag(cfg *config) string {
+	return versionTag(cfg, cfg.initialVersion)
+}
+
+// versionTag returns the tag of v, or of its first prerelease on the
+// channel.
+func versionTag(cfg *config, v *version.Version) string {
+	if _, ok := cfg.scheme.(semverScheme); !ok {
+		return cfg.prefix + v.Original()
+	}
+
+	d := newTagData(cfg.prefix, v.Segments())
+	if cfg.prerelease != "" {
+		d.Channel, d.Number = cfg.prerelease, 1
+	}
+	return formatTag(d)
+}
+
+// floorTag returns next, the tag of the version after last, or the tag of
+// MIN_VERSION when next would be below it, as when the history comes from
+// another scheme. It reports whether the floor was applied.
+func floorTag(cfg *config, last *version.Version, next string) (string, bool) {
+	if cfg.minVersion == nil {
+		return next, false
+	}
+	floor := versionTag(cfg, cfg.minVersion)
+	nv, err := version.NewSemver(strings.TrimPrefix(next, cfg.prefix))
+	fv, ferr := version.NewSemver(strings.TrimPrefix(floor, cfg.prefix))
+	if err != nil || ferr != nil || !nv.LessThan(fv) {
+		return next, false
+	}
+	tracef("Last version %s is below MIN_VERSION %s, releasing %s instead of %s", last.Original(), cfg.minVersion.Original(), floor, next)
+	return floor, true
+}
+
+// tagsPerPage is how many tags forEachTag lists per request, the most the
+// API allows.
+const tagsPerPage = 100
 
-	page := 1
+// tagProgressPages is how often, in pages, forEachTag says how far it got.
+const tagProgressPages = 10
+
+// forEachTag calls fn with the name and ref of every tag in the repository.
+// Tags are listed a page at a time and only that page is held, so fn should
+// keep what it needs rather than every tag, for repositories with tens of
+// thousands of them.
+func (c *client) forEachTag(ctx context.Context, fn func(name string, r *github.Reference)) error {
+	if c.release != nil {
+		return c.release.forEachTag(ctx, fn)
+	}
+	page, seen := 1, 0
 	for {
 		lo := &github.ReferenceListOptions{
 			Type: "tag",
 			ListOptions: github.ListOptions{
-				Page: page,
+				Page:    page,
+				PerPage: tagsPerPage,
 			},
 		}
 		refs, resp, err := c.c.Git.ListRefs(ctx, c.owner, c.repo, lo)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
 		for _, r := range refs {
-			fmt.Println("Ref:", r.GetRef())
-
-			tag := strings.TrimPrefix(r.GetRef(), "refs/tags/"+prefix)
-			v, err := version.NewSemver(tag)
-			if err != nil {
-				fmt.Printf("Tag %v is not a valid semver, ignoring", tag)
-				continue
-			}
-			if v.GreaterThan(last) {
-				fmt.Println("Found newer version:", v)
-				last = v
+			if err := runBudget.tag(); err != nil {
+				return err
 			}
+			fn(strings.TrimPrefix(r.GetRef(), "refs/tags/"), r)
+		}
+		seen += len(refs)
+		if page%tagProgressPages == 0 {
+			fmt.Printf("Listed %d tags so far\n", seen)
 		}
 
 		// do we have more?
 		link := resp.Header.Get("Link")
 		if strings.Index(link, "rel=\"next\"") == -1 {
 			// we're done here
-			break
+			return nil
 		}
 		page++
 	}
+}
+
+// commentData is what comment templates are rendered with.
+type commentData struct {
+	Version    string // the released version
+	Previous   string // the previous version, if known
+	RepoURL    string
+	CompareURL string // compare view between Previous and Version, if known
+	Number     int    // the released pull request
+
+	PreviousDate time.Time         // when Previous was released, if known
+	SinceLast    string            // how long ago that was, like "3 days"
+	PreviousMeta map[string]string // the trailers of Previous's annotated tag, like Channel
+}
+
+const (
+	defaultCommentTemplate = "Your friendly autotagging bot has tagged this as release **{{.Version}}**" +
+		"{{if .Previous}}\n\nPrevious release: {{.Previous}}{{if .SinceLast}}, {{.SinceLast}} ago{{end}} ([compare]({{.CompareURL}})){{end}}"
+	defaultIssueCommentTemplate = "Fixed in **{{.Version}}** (#{{.Number}})"
+)
+
+// newCommentData returns the template data announcing version.
+func newCommentData(version, previous, repoURL string, number int) commentData {
+	d := commentData{Version: version, Previous: previous, RepoURL: repoURL, Number: number}
+	if previous != "" {
+		d.CompareURL = fmt.Sprintf("%s/compare/%s...%s", repoURL, previous, version)
+	}
+	return d
+}
 
-	if last.String() == "0.0.0" {
-		return nil, errors.New("could not find any versions")
+// parseTemplate parses the Go template in the named environment variable,
+// falling back to def when it's unset.
+func parseTemplate(env, def string) *template.Template {
+	text := def
+	if t, ok := os.LookupEnv(env); ok {
+		text = t
 	}
 
-	return last, nil
+	t, err := template.New(env).Parse(text)
+	if err != nil {
+		fatalf("invalid %s: %v", env, err)
+	}
+	return t
+}
+
+// renderComment executes a comment template.
+func renderComment(t *template.Template, d commentData) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, d); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// mentions renders a "cc" line @-mentioning the comma-separated users and
+// teams in list, or an empty string if there's no one to mention.
+func mentions(list string) string {
+	var names []string
+	for _, n := range strings.Split(list, ",") {
+		n = strings.TrimPrefix(strings.TrimSpace(n), "@")
+		if n != "" {
+			names = append(names, "@"+n)
+		}
+	}
+
+	if len(names) == 0 {
+		return ""
+	}
+	return "\n\ncc " + strings.Join(names, " ")
+}
+
+// signed appends the signature line to a comment body, if there is one.
+func signed(body, signature string) string {
+	if signature == "" {
+		return body
+	}
+	return body + "\n\n" + signature
 }
 
-func (c *client) shouldTag(ctx context.Context, base, merge string, fileMatch *regexp.Regexp) bool {
+// hasCurrentLabel reports whether the pull request numbered number has the
+// label name now, rather than when the event was sent.
+func (c *client) hasCurrentLabel(ctx context.Context, number int, name string) (bool, error) {
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		labels, resp, err := c.c.Issues.ListLabelsByIssue(ctx, c.owner, c.repo, number, opt)
+		if err != nil {
+			return false, err
+		}
+		if hasLabel(labels, name) {
+			return true, nil
+		}
+		if resp.NextPage == 0 {
+			return false, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// hasLabel reports whether labels contains one called name.
+func hasLabel(labels []*github.Label, name string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l.GetName(), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// closingRE matches GitHub's closing keywords referencing an issue in the
+// same repository, e.g. "Fixes #12".
+var closingRE = regexp.MustCompile(`(?i)\b(?:close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+#(\d+)\b`)
+
+// linkedIssues returns the numbers of the issues the pull request body says
+// it closes, in order of appearance and without duplicates.
+func linkedIssues(body string) []int {
+	var issues []int
+	seen := map[int]bool{}
+	for _, m := range closingRE.FindAllStringSubmatch(body, -1) {
+		n, err := strconv.Atoi(m[1])
+		if err != nil || seen[n] {
+			continue
+		}
+		seen[n] = true
+		issues = append(issues, n)
+	}
+	return issues
+}
+
+// commentMarker is a hidden marker identifying the bot's own comments.
+const commentMarker = "<!-- autotagger -->"
+
+// actionsBot is the account comments made with a workflow's GITHUB_TOKEN
+// are posted as.
+const actionsBot = "github-actions[bot]"
+
+// commenter returns the login of the account the token comments as: that of
+// BOT_LOGIN, the user a personal or OAuth token belongs to, or the Actions
+// bot for tokens that can't tell, like GITHUB_TOKEN. Github Apps need
+// BOT_LOGIN, like my-app[bot].
+func (c *client) commenter(ctx context.Context) string {
+	if c.login != "" {
+		return c.login
+	}
+
+	c.login = os.Getenv("BOT_LOGIN")
+	if c.login == "" {
+		if u, _, err := c.c.Users.Get(ctx, ""); err == nil {
+			c.login = u.GetLogin()
+		} else {
+			c.login = actionsBot
+		}
+	}
+	tracef("Commenting as %s", c.login)
+	return c.login
+}
+
+// findComment returns the bot's previous comment on the pull request, or nil
+// if there isn't one. Only the commenter's comments count: anyone could put
+// the marker in theirs.
+func (c *client) findComment(ctx context.Context, number int) (*github.IssueComment, error) {
+	login := c.commenter(ctx)
+	opt := &github.IssueListCommentsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		comments, resp, err := c.c.Issues.ListComments(ctx, c.owner, c.repo, number, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cm := range comments {
+			if strings.EqualFold(cm.GetUser().GetLogin(), login) && strings.Contains(cm.GetBody(), commentMarker) {
+				return cm, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// upsertComment updates the bot's comment on the pull request with body, or
+// creates it if there's none yet. This keeps retries and follow-up releases
+// from piling up comments. Components of a monorepo released by the same
+// pull request each get their section of the one comment.
+func (c *client) upsertComment(ctx context.Context, number int, component, body string) error {
+	cm, err := c.findComment(ctx, number)
+	if err != nil {
+		return fmt.Errorf("could not list comments: %v", err)
+	}
+
+	switch {
+	case cm == nil:
+		// an attempt that failed may have commented after all, which makes
+		// the comment there to update next time
+		created := func() bool {
+			cm, err := c.findComment(ctx, number)
+			return err == nil && cm != nil
+		}
+		_, _, err = c.c.Issues.CreateComment(withRetryCheck(ctx, created), c.owner, c.repo, number, &github.IssueComment{
+			Body: github.String(mergeComment("", component, body)),
+		})
+		if err != nil && created() {
+			tracef("Commented on PR #%d in an earlier attempt", number)
+			err = nil
+		}
+	case cm.GetBody() == mergeComment(cm.GetBody(), component, body):
+		fmt.Println("Pull request was already commented on")
+	default:
+		fmt.Println("Updating previous comment", cm.GetHTMLURL())
+		_, _, err = c.c.Issues.EditComment(ctx, c.owner, c.repo, cm.GetID(), &github.IssueComment{
+			Body: github.String(mergeComment(cm.GetBody(), component, body)),
+		})
+	}
+	return err
+}
+
+// commentSectionRE matches a component's section of the bot's comment.
+var commentSectionRE = regexp.MustCompile(`(?s)<!-- autotagger:(.*?) -->\n(.*?)\n<!-- /autotagger -->`)
+
+// mergeComment returns the bot's comment old with the section of component,
+// its TAG_PREFIX, set to body. The other components' sections are kept, in
+// the order of their prefixes. Comments from before there were sections are
+// replaced.
+func mergeComment(old, component, body string) string {
+	sections := map[string]string{}
+	for _, m := range commentSectionRE.FindAllStringSubmatch(old, -1) {
+		sections[m[1]] = m[2]
+	}
+	sections[component] = body
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := []string{commentMarker}
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("<!-- autotagger:%s -->\n%s\n<!-- /autotagger -->", name, sections[name]))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// labelRelease adds a "released: <version>" label to the pull request,
+// creating the label first when it doesn't exist yet.
+func (c *client) labelRelease(ctx context.Context, number int, version string) error {
+	name := "released: " + version
+
+	_, resp, err := c.c.Issues.GetLabel(ctx, c.owner, c.repo, name)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		_, _, err = c.c.Issues.CreateLabel(ctx, c.owner, c.repo, &github.Label{
+			Name:        github.String(name),
+			Color:       github.String("ededed"),
+			Description: github.String("Released in " + version),
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.c.Issues.AddLabelsToIssue(ctx, c.owner, c.repo, number, []string{name})
+	return err
+}
+
+// shouldTag reports whether the changes between base and merge warrant a
+// release, and what they say about the bump.
+func (c *client) shouldTag(ctx context.Context, cfg *config, base, merge string) (bool, changes) {
+	if base == "" {
+		// nothing to compare with, it's all new
+		tracef("No previous version, releasing %s as it is", merge)
+		return true, changes{}
+	}
+
+	// the tag is in the release repository, its commit is here too
+	if c.release != nil {
+		sha, err := c.release.getTagSHA(ctx, base)
+		if err != nil {
+			fatalf("could not look up tag %s in %s/%s: %v", base, c.release.owner, c.release.repo, err)
+		}
+		base = sha
+	}
 
 	// repositories service compare commits
 	cmp, _, err := c.c.Repositories.CompareCommits(ctx, c.owner, c.repo, base, merge)
@@ -197,28 +1088,384 @@ func (c *client) shouldTag(ctx context.Context, base, merge string, fileMatch *r
 		fatal("error getting diff:", err)
 	}
 
+	var matched []string
 	for _, cf := range cmp.Files {
-		if fileMatch.MatchString(*cf.Filename) {
+		paths := changedPaths(&cf, cfg.ignoreDeletions, cfg.ignoreRenames)
+		var note string
+		switch cf.GetStatus() {
+		case "removed":
+			note = " (deleted)"
+		case "renamed":
+			note = " (renamed from " + cf.GetPreviousFilename() + ")"
+		}
+
+		switch {
+		case anyMatch(paths, cfg.fileMatch.MatchString):
+			tracef("- %s%s", cf.GetFilename(), note)
+		case anyMatch(paths, func(p string) bool { return matchesPath(cfg.globalPaths, p) }):
+			tracef("- %s%s (affects everything)", cf.GetFilename(), note)
+		default:
+			continue
+		}
+		matched = append(matched, cf.GetFilename())
+	}
+	tracef("%d of %d changed files since %s match %s", len(matched), len(cmp.Files), base, cfg.fileMatch)
+
+	if len(matched) == 0 {
+		return false, changes{}
+	}
+
+	ch := changes{files: matched, paths: filesBump(cfg.pathRules, matched)}
+	if cfg.apidiff {
+		if ch.api, err = apiDiff(cfg.componentPath, base, merge); err != nil {
+			fatalf("could not compare APIs: %v", err)
+		}
+	}
+	return true, ch
+}
+
+// changedPaths returns the paths a changed file counts as a change to: its
+// name, and for a rename its previous name too, as moving a file out of a
+// component changes it as well. Deletions and renames without changes to
+// the content can be ignored.
+func changedPaths(cf *github.CommitFile, ignoreDeletions, ignoreRenames bool) []string {
+	switch cf.GetStatus() {
+	case "removed":
+		if ignoreDeletions {
+			return nil
+		}
+	case "renamed":
+		if ignoreRenames {
+			if cf.GetChanges() == 0 {
+				return nil
+			}
+			break
+		}
+		return []string{cf.GetFilename(), cf.GetPreviousFilename()}
+	}
+	return []string{cf.GetFilename()}
+}
+
+// anyMatch reports whether match is true for one of paths.
+func anyMatch(paths []string, match func(string) bool) bool {
+	for _, p := range paths {
+		if match(p) {
 			return true
 		}
 	}
+	return false
+}
 
+// matchesPath reports whether file matches one of patterns: a path, a
+// directory ending in a slash, or a glob like *.mk.
+func matchesPath(patterns []string, file string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, file); ok {
+			return true
+		}
+		if strings.HasSuffix(p, "/") && strings.HasPrefix(file, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRateLimit exits, or warns when configured to, if there are fewer API
+// requests left than the run is expected to need. It's better to bail out
+// before paginating through tags than halfway through.
+func (c *client) checkRateLimit(ctx context.Context, cfg *config) {
+	remaining, reset, err := c.rateLimit(ctx)
+	if err != nil {
+		log.Printf("Could not check rate limit, continuing anyway: %v", err)
+		return
+	}
+	if remaining >= cfg.rateLimitMin {
+		return
+	}
+
+	msg := fmt.Sprintf("Only %d API requests left (need %d), rate limit resets at %s", remaining, cfg.rateLimitMin, reset.Format(time.RFC3339))
+	if !cfg.rateLimitWarn {
+		fatal(msg)
+	}
+	log.Print("Warning: ", msg)
+}
+
+// rateLimit returns the number of core API requests left for the token and
+// when that limit resets. Checking it doesn't count against the limit.
+func (c *client) rateLimit(ctx context.Context) (int, time.Time, error) {
+	rl, _, err := c.c.RateLimits(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return rl.GetCore().Remaining, rl.GetCore().Reset.Time, nil
+}
+
+// setStatus publishes the release outcome as an autotagger/release commit
+// status on sha. Statuses have no neutral state, so skips are reported as a
+// success with the reason in the description.
+func (c *client) setStatus(ctx context.Context, sha, description, targetURL string) error {
+	st := &github.RepoStatus{
+		State:       github.String("success"),
+		Description: github.String(description),
+		Context:     github.String("autotagger/release"),
+	}
+	if targetURL != "" {
+		st.TargetURL = github.String(targetURL)
+	}
+
+	_, _, err := c.c.Repositories.CreateStatus(ctx, c.owner, c.repo, sha, st)
+	return err
+}
+
+// maxSummary keeps check run summaries under GitHub's 64k character limit.
+const maxSummary = 60000
+
+// createCheckRun creates a completed autotagger check run on sha, with the
+// decision trace so far as its summary, followed by notes if there are any.
+func (c *client) createCheckRun(ctx context.Context, sha, conclusion, title, notes string) error {
+	if len(notes) > maxSummary/2 {
+		notes = notes[:maxSummary/2] + "\n..."
+	}
+	summary := strings.Join(trace, "\n")
+	if len(summary)+len(notes) > maxSummary {
+		summary = summary[:maxSummary-len(notes)] + "\n..."
+	}
+	summary = "```\n" + summary + "\n```"
+	if notes != "" {
+		summary += "\n\n" + notes
+	}
+
+	_, _, err := c.c.Checks.CreateCheckRun(ctx, c.owner, c.repo, github.CreateCheckRunOptions{
+		Name:        "autotagger",
+		HeadSHA:     sha,
+		Status:      github.String("completed"),
+		Conclusion:  github.String(conclusion),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output: &github.CheckRunOutput{
+			Title:   github.String(title),
+			Summary: github.String(summary),
+		},
+	})
+	return err
+}
+
+// getTagSHA returns the SHA of the commit the given tag points at, or an empty
+// string if there's no such tag. Annotated tags are resolved to their commit.
+func (c *client) getTagSHA(ctx context.Context, tag string) (string, error) {
+	if c.release != nil {
+		return c.release.getTagSHA(ctx, tag)
+	}
+	refs, resp, err := c.c.Git.GetRefs(ctx, c.owner, c.repo, "tags/"+tag)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// GetRefs returns every ref starting with the given name when there's no
+	// exact match, so look for ours explicitly.
+	for _, r := range refs {
+		if r.GetRef() != "refs/tags/"+tag {
+			continue
+		}
+
+		if r.GetObject().GetType() != "tag" {
+			return r.GetObject().GetSHA(), nil
+		}
+
+		t, _, err := c.c.Git.GetTag(ctx, c.owner, c.repo, r.GetObject().GetSHA())
+		if err != nil {
+			return "", fmt.Errorf("could not resolve annotated tag %s: %v", tag, err)
+		}
+		return t.GetObject().GetSHA(), nil
+	}
+
+	return "", nil
+}
+
+// tag conflict policies, applied when the computed tag already exists and
+// points at a different commit than the one we want to tag.
+const (
+	conflictFail      = "fail"       // exit with an error
+	conflictSkip      = "skip"       // don't tag anything
+	conflictBumpAgain = "bump-again" // keep incrementing until we find a free tag
+	conflictForceMove = "force-move" // move the tag, only allowed for prereleases
+)
+
+func validConflictPolicy(p string) bool {
+	switch p {
+	case conflictFail, conflictSkip, conflictBumpAgain, conflictForceMove:
+		return true
+	}
 	return false
 }
 
-func nextVersion(v *version.Version, prefix string) string {
+// what to do with the tag once conflicts are resolved
+type tagAction int
+
+const (
+	tagCreate tagAction = iota // create a new tag
+	tagMove                    // force-move the existing tag
+	tagExists                  // the tag already points at the right commit
+	tagSkip                    // don't tag
+)
+
+// resolveConflict checks whether tag already exists on a commit other than
+// ref and applies the given policy if it does. It returns the tag to use and
+// what to do with it.
+func (c *client) resolveConflict(ctx context.Context, tag, ref, prefix, policy string) (string, tagAction) {
+	for {
+		sha, err := c.getTagSHA(ctx, tag)
+		if err != nil {
+			fatalf("could not look up tag %s: %v", tag, err)
+		}
+		switch sha {
+		case "":
+			return tag, tagCreate
+		case ref:
+			return tag, tagExists
+		}
+
+		tracef("Tag %s already exists on %s, applying conflict policy %q", tag, sha, policy)
+
+		switch policy {
+		case conflictSkip:
+			return tag, tagSkip
+		case conflictBumpAgain:
+			v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+			if err != nil {
+				fatalf("could not parse tag %s: %v", tag, err)
+			}
+			if ch, _ := prereleaseParts(v); ch != "" {
+				tag = nextPrerelease(v, prefix, ch, bumpPatch)
+			} else {
+				tag = nextVersion(v, prefix, bumpPatch)
+			}
+			tracef("Bumped again to %s", tag)
+		case conflictForceMove:
+			v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+			if err != nil {
+				fatalf("could not parse tag %s: %v", tag, err)
+			}
+			if v.Prerelease() == "" {
+				fatalf("tag %s already exists on %s and force-move is only allowed for prerelease tags", tag, sha)
+			}
+			tracef("Moving tag %s from %s to %s", tag, sha, ref)
+			return tag, tagMove
+		default:
+			fatalf("tag %s already exists on %s", tag, sha)
+		}
+	}
+}
+
+// nextVersion returns the tag for the version after v, incrementing the part
+// of it that b says and resetting the ones after.
+func nextVersion(v *version.Version, prefix string, b bump) string {
+	return formatTag(newTagData(prefix, bumpSegments(v, b)))
+}
+
+// bumpSegments returns the numbers of the version after v, incrementing
+// the one that b says and resetting the ones after, unless VERSION_RESET
+// says not to.
+func bumpSegments(v *version.Version, b bump) []int {
 	segs := v.Segments()
-	diff := 3 - len(segs)
-	for i := 0; i < diff; i++ {
+	for len(segs) < segments {
 		segs = append(segs, 0)
 	}
+	segs = segs[:segments]
+
+	i := bumpIndex(b)
+	segs[i]++
+	for j := i + 1; j < len(segs) && resets[b]; j++ {
+		segs[j] = 0
+	}
+	return segs
+}
+
+// bumpIndex returns the index of the version number b increments.
+func bumpIndex(b bump) int {
+	switch b {
+	case bumpMajor:
+		return 0
+	case bumpMinor:
+		return 1
+	default:
+		return patchSegment
+	}
+}
+
+// exitSkipped ends a run that didn't tag anything, setting the skip_reason
+// output for later steps.
+func exitSkipped(reason string) {
+	if err := setOutput("skip_reason", reason); err != nil {
+		log.Printf("Could not set skip_reason output: %v", err)
+	}
+	os.Exit(exConfig)
+}
+
+// setOutput sets an action output, using the GITHUB_OUTPUT file when the
+// runner provides one and the set-output workflow command otherwise.
+func setOutput(name, value string) error {
+	path := os.Getenv("GITHUB_OUTPUT")
+	if path == "" {
+		r := strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+		fmt.Printf("::set-output name=%s::%s\n", name, r.Replace(value))
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	return fmt.Sprintf("%sv%d.%d.%d", prefix, segs[0], segs[1], segs[2]+1)
+	delim := fmt.Sprintf("autotagger_%d", time.Now().UnixNano())
+	_, err = fmt.Fprintf(f, "%s<<%s\n%s\n%s\n", name, delim, value, delim)
+	return err
+}
+
+// setChangelogOutput writes the release notes to a file and exposes both the
+// file and its contents as the changelog_file and changelog outputs.
+func setChangelogOutput(notes string) error {
+	dir := os.Getenv("RUNNER_TEMP")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+
+	path := filepath.Join(dir, "autotagger-changelog.md")
+	if err := ioutil.WriteFile(path, []byte(notes), 0644); err != nil {
+		return err
+	}
+
+	if err := setOutput("changelog_file", path); err != nil {
+		return err
+	}
+	return setOutput("changelog", notes)
+}
+
+// finalVersion returns the release version v is a prerelease of, e.g.
+// v1.4.0 for v1.4.0-rc.3.
+func finalVersion(v *version.Version, prefix string) string {
+	return formatTag(newTagData(prefix, v.Segments()))
+}
+
+// tracef prints a step of the release decision and records it in the trace.
+func tracef(frmt string, a ...interface{}) {
+	line := fmt.Sprintf(frmt, a...)
+	fmt.Println(line)
+	trace = append(trace, line)
 }
 
 // fatal is like log.Fatal but respects NEVER_FAIL
 func fatal(a ...interface{}) {
 	log.Print(a...)
+	reportError(fmt.Sprint(a...))
+	if f := onFailure; f != nil {
+		onFailure = nil
+		f(fmt.Sprint(a...))
+	}
 	os.Exit(fatalExit)
 }
 
@@ -1,14 +1,24 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
 	"testing"
+	"text/template"
 
+	"github.com/google/go-github/v29/github"
 	"github.com/hashicorp/go-version"
 )
 
 func Test_nextVersion(t *testing.T) {
 	tests := []struct {
 		previous string
+		bump     bump
 		want     string
 	}{
 		{
@@ -23,16 +33,26 @@ func Test_nextVersion(t *testing.T) {
 			previous: "v1.2.3+2019-10-08.deadbeef",
 			want:     "v1.2.4",
 		},
+		{
+			previous: "v1.2.3",
+			bump:     bumpMinor,
+			want:     "v1.3.0",
+		},
+		{
+			previous: "v1.2.3",
+			bump:     bumpMajor,
+			want:     "v2.0.0",
+		},
 	}
 
 	for _, tc := range tests {
-		t.Run(tc.previous, func(t *testing.T) {
+		t.Run(tc.previous+" "+tc.bump.String(), func(t *testing.T) {
 			v, err := version.NewSemver(tc.previous)
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			nv := nextVersion(v)
+			nv := nextVersion(v, "", tc.bump)
 
 			if nv != tc.want {
 				t.Errorf("got %s, want %s", nv, tc.want)
@@ -40,3 +60,646 @@ func Test_nextVersion(t *testing.T) {
 		})
 	}
 }
+
+func Test_finalVersion(t *testing.T) {
+	tests := []struct {
+		prerelease string
+		want       string
+	}{
+		{prerelease: "v1.4.0-rc.3", want: "api/v1.4.0"},
+		{prerelease: "v1.4-beta", want: "api/v1.4.0"},
+		{prerelease: "v2.0.1-rc.1+deadbeef", want: "api/v2.0.1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.prerelease, func(t *testing.T) {
+			v, err := version.NewSemver(tc.prerelease)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := finalVersion(v, "api/"); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_initialTag(t *testing.T) {
+	cv, err := parseCalver("YYYY.0M.MICRO")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		initial    string
+		prerelease string
+		scheme     scheme
+		want       string
+	}{
+		{initial: "v0.1.0", scheme: semverScheme{}, want: "api/v0.1.0"},
+		{initial: "1.0", scheme: semverScheme{}, want: "api/v1.0.0"},
+		{initial: "v0.1.0", prerelease: "rc", scheme: semverScheme{}, want: "api/v0.1.0-rc.1"},
+		{initial: "v2024.06.0", scheme: cv, want: "api/v2024.06.0"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.initial+" "+tc.prerelease, func(t *testing.T) {
+			v, err := version.NewSemver(tc.initial)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := &config{prefix: "api/", initialVersion: v, prerelease: tc.prerelease, scheme: tc.scheme}
+
+			if got := initialTag(cfg); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_floorTag(t *testing.T) {
+	tests := []struct {
+		min        string
+		prerelease string
+		next       string
+		want       string
+		floored    bool
+	}{
+		{min: "", next: "api/v0.9.1", want: "api/v0.9.1"},
+		{min: "v2.0.0", next: "api/v0.9.1", want: "api/v2.0.0", floored: true},
+		{min: "v2.0.0", next: "api/v2.0.0", want: "api/v2.0.0"},
+		{min: "v2.0.0", next: "api/v2.1.0", want: "api/v2.1.0"},
+		{min: "v2.0.0", prerelease: "rc", next: "api/v1.0.0-rc.1", want: "api/v2.0.0-rc.1", floored: true},
+		{min: "v2.0.0", prerelease: "rc", next: "api/v2.0.0-rc.2", want: "api/v2.0.0-rc.2"},
+	}
+
+	last := version.Must(version.NewSemver("v0.9.0"))
+	for _, tc := range tests {
+		t.Run(tc.min+" "+tc.next, func(t *testing.T) {
+			cfg := &config{prefix: "api/", prerelease: tc.prerelease, scheme: semverScheme{}}
+			if tc.min != "" {
+				cfg.minVersion = version.Must(version.NewSemver(tc.min))
+			}
+
+			got, floored := floorTag(cfg, last, tc.next)
+			if got != tc.want || floored != tc.floored {
+				t.Errorf("got %s, %v, want %s, %v", got, floored, tc.want, tc.floored)
+			}
+		})
+	}
+}
+
+func Test_renderComment(t *testing.T) {
+	tests := []struct {
+		name     string
+		tmpl     string
+		previous string
+		since    string
+		want     string
+	}{
+		{
+			name: "no previous version",
+			tmpl: defaultCommentTemplate,
+			want: "Your friendly autotagging bot has tagged this as release **v1.2.4**",
+		},
+		{
+			name:     "previous version",
+			tmpl:     defaultCommentTemplate,
+			previous: "v1.2.3",
+			want: "Your friendly autotagging bot has tagged this as release **v1.2.4**\n\n" +
+				"Previous release: v1.2.3 ([compare](https://github.com/o/r/compare/v1.2.3...v1.2.4))",
+		},
+		{
+			name:     "time since previous version",
+			tmpl:     defaultCommentTemplate,
+			previous: "v1.2.3",
+			since:    "3 days",
+			want: "Your friendly autotagging bot has tagged this as release **v1.2.4**\n\n" +
+				"Previous release: v1.2.3, 3 days ago ([compare](https://github.com/o/r/compare/v1.2.3...v1.2.4))",
+		},
+		{
+			name: "issue comment",
+			tmpl: defaultIssueCommentTemplate,
+			want: "Fixed in **v1.2.4** (#42)",
+		},
+		{
+			name:     "custom template",
+			tmpl:     "Publié en {{.Version}} (précédente : {{.Previous}})",
+			previous: "v1.2.3",
+			want:     "Publié en v1.2.4 (précédente : v1.2.3)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tmpl := template.Must(template.New(tc.name).Parse(tc.tmpl))
+			d := newCommentData("v1.2.4", tc.previous, "https://github.com/o/r", 42)
+			d.SinceLast = tc.since
+			got, err := renderComment(tmpl, d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_linkedIssues(t *testing.T) {
+	tests := []struct {
+		body string
+		want []int
+	}{
+		{body: "", want: nil},
+		{body: "Fixes #12", want: []int{12}},
+		{body: "closes #1, resolved #2 and Fixed: #3", want: []int{1, 2, 3}},
+		{body: "fixes #4\nalso fixes #4", want: []int{4}},
+		{body: "see #5, prefixes #6", want: nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.body, func(t *testing.T) {
+			got := linkedIssues(tc.body)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_mentions(t *testing.T) {
+	tests := []struct {
+		list string
+		want string
+	}{
+		{list: "", want: ""},
+		{list: " , ", want: ""},
+		{list: "jbowes", want: "\n\ncc @jbowes"},
+		{list: "@jbowes, manifoldco/core", want: "\n\ncc @jbowes @manifoldco/core"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.list, func(t *testing.T) {
+			if got := mentions(tc.list); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_signed(t *testing.T) {
+	tests := []struct {
+		signature string
+		want      string
+	}{
+		{signature: "", want: "Released v1.2.3"},
+		{signature: "_Tagged by release-bot_", want: "Released v1.2.3\n\n_Tagged by release-bot_"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.signature, func(t *testing.T) {
+			if got := signed("Released v1.2.3", tc.signature); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_matchesPath(t *testing.T) {
+	patterns := []string{"go.work", ".github/workflows/", "*.mk", "build/*.sh"}
+
+	tests := map[string]bool{
+		"go.work":                  true,
+		"api/go.work":              false,
+		".github/workflows/ci.yml": true,
+		".github/dependabot.yml":   false,
+		"rules.mk":                 true,
+		"api/rules.mk":             false,
+		"build/release.sh":         true,
+		"build/scripts/release.sh": false,
+		"api/handlers/things.go":   false,
+	}
+
+	for file, want := range tests {
+		t.Run(file, func(t *testing.T) {
+			if got := matchesPath(patterns, file); got != want {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_splitHistory(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		split bool
+	}{
+		{"only prefixed", []string{"api/v1.0.0", "api/v1.1.0"}, false},
+		{"only unprefixed", []string{"v1.0.0", "v1.1.0"}, false},
+		{"continued", []string{"v1.0.0", "v1.2.3", "api/v1.2.4", "api/v1.3.0"}, false},
+		{"carried on unprefixed", []string{"v1.2.3", "api/v1.2.4", "v1.3.0"}, true},
+		{"restarted", []string{"v1.2.3", "api/v0.1.0"}, true},
+		{"same version", []string{"v1.2.3", "api/v1.2.3"}, true},
+		{"other prefixes", []string{"web/v9.9.9", "api/v1.0.0", "nightly"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitHistory(tt.names, "api/"); (got != "") != tt.split {
+				t.Errorf("got %q, want split %v", got, tt.split)
+			}
+		})
+	}
+}
+
+func Test_changedPaths(t *testing.T) {
+	tests := []struct {
+		name                           string
+		file                           github.CommitFile
+		ignoreDeletions, ignoreRenames bool
+		want                           []string
+	}{
+		{"modified", github.CommitFile{Filename: github.String("a/x.go"), Status: github.String("modified")}, true, true, []string{"a/x.go"}},
+		{"removed", github.CommitFile{Filename: github.String("a/x.go"), Status: github.String("removed")}, false, false, []string{"a/x.go"}},
+		{"removed, ignored", github.CommitFile{Filename: github.String("a/x.go"), Status: github.String("removed")}, true, false, nil},
+		{
+			"renamed",
+			github.CommitFile{Filename: github.String("b/x.go"), PreviousFilename: github.String("a/x.go"), Status: github.String("renamed")},
+			false, false, []string{"b/x.go", "a/x.go"},
+		},
+		{
+			"renamed, ignored",
+			github.CommitFile{Filename: github.String("b/x.go"), PreviousFilename: github.String("a/x.go"), Status: github.String("renamed")},
+			false, true, nil,
+		},
+		{
+			"renamed and changed, ignored",
+			github.CommitFile{Filename: github.String("b/x.go"), PreviousFilename: github.String("a/x.go"), Status: github.String("renamed"), Changes: github.Int(3)},
+			false, true, []string{"b/x.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changedPaths(&tt.file, tt.ignoreDeletions, tt.ignoreRenames)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_mergeComment(t *testing.T) {
+	api := mergeComment("", "api/", "Tagged **api/v1.2.0**")
+	want := "<!-- autotagger -->\n\n<!-- autotagger:api/ -->\nTagged **api/v1.2.0**\n<!-- /autotagger -->"
+	if api != want {
+		t.Errorf("got\n%s\nwant\n%s", api, want)
+	}
+
+	both := mergeComment(api, "web/", "Tagged **web/v0.3.1**")
+	want = "<!-- autotagger -->\n\n<!-- autotagger:api/ -->\nTagged **api/v1.2.0**\n<!-- /autotagger -->" +
+		"\n\n<!-- autotagger:web/ -->\nTagged **web/v0.3.1**\n<!-- /autotagger -->"
+	if both != want {
+		t.Errorf("got\n%s\nwant\n%s", both, want)
+	}
+
+	if got := mergeComment(both, "api/", "Tagged **api/v1.2.0**"); got != both {
+		t.Errorf("the same section changed the comment:\n%s", got)
+	}
+	if got, want := mergeComment("<!-- autotagger -->\nTagged **v1.0.0**", "", "Tagged **v1.0.1**"),
+		"<!-- autotagger -->\n\n<!-- autotagger: -->\nTagged **v1.0.1**\n<!-- /autotagger -->"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+// newTestClient returns a client for the repository o/r on a fake API
+// serving tags, by name, as lightweight tags on their commit, and routes, by
+// method and path like "GET /repos/o/r/pulls/7": a handler, or a response
+// to send as JSON. Any other request fails the test. The server is closed
+// with the returned func.
+func newTestClient(t *testing.T, tags map[string]string, routes map[string]interface{}) (*client, func()) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route, ok := routes[r.Method+" "+r.URL.Path]; ok {
+			if h, ok := route.(func(http.ResponseWriter, *http.Request)); ok {
+				h(w, r)
+			} else {
+				json.NewEncoder(w).Encode(route)
+			}
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, "/repos/o/r/git/refs/tags/") || r.Method != http.MethodGet {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.Error(w, "unexpected", http.StatusInternalServerError)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/repos/o/r/git/refs/tags/")
+		sha, ok := tags[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(github.Reference{
+			Ref:    github.String("refs/tags/" + name),
+			Object: &github.GitObject{Type: github.String("commit"), SHA: github.String(sha)},
+		})
+	}))
+
+	gh := github.NewClient(nil)
+	gh.BaseURL, _ = url.Parse(srv.URL + "/")
+	return &client{c: gh, owner: "o", repo: "r"}, srv.Close
+}
+
+func Test_resolveConflict(t *testing.T) {
+	tags := map[string]string{
+		"v1.2.0":      "merge",
+		"v1.3.0":      "other",
+		"v1.3.1":      "other",
+		"v2.0.0-rc.1": "other",
+		"api/v1.0.0":  "other",
+		"v1.4.0-rc.1": "merge",
+	}
+	c, done := newTestClient(t, tags, nil)
+	defer done()
+
+	tests := []struct {
+		name    string
+		tag     string
+		prefix  string
+		policy  string
+		want    string
+		wantAct tagAction
+	}{
+		{"free", "v1.5.0", "", conflictFail, "v1.5.0", tagCreate},
+		{"same commit", "v1.2.0", "", conflictFail, "v1.2.0", tagExists},
+		{"same commit prerelease", "v1.4.0-rc.1", "", conflictForceMove, "v1.4.0-rc.1", tagExists},
+		{"other commit skip", "v1.3.0", "", conflictSkip, "v1.3.0", tagSkip},
+		{"other commit bump again", "v1.3.0", "", conflictBumpAgain, "v1.3.2", tagCreate},
+		{"other commit bump again prefix", "api/v1.0.0", "api/", conflictBumpAgain, "api/v1.0.1", tagCreate},
+		{"other commit force move", "v2.0.0-rc.1", "", conflictForceMove, "v2.0.0-rc.1", tagMove},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, act := c.resolveConflict(context.Background(), tt.tag, "merge", tt.prefix, tt.policy)
+			if got != tt.want || act != tt.wantAct {
+				t.Errorf("got %s, %v, want %s, %v", got, act, tt.want, tt.wantAct)
+			}
+		})
+	}
+}
+
+func Test_findComment(t *testing.T) {
+	comment := func(id int64, login, body string) *github.IssueComment {
+		return &github.IssueComment{ID: github.Int64(id), User: &github.User{Login: github.String(login)}, Body: github.String(body)}
+	}
+	forbidden := func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message":"Resource not accessible by integration"}`, http.StatusForbidden)
+	}
+
+	tests := []struct {
+		name     string
+		user     interface{} // GET /user
+		comments []*github.IssueComment
+		want     int64
+	}{
+		{"actions bot", forbidden, []*github.IssueComment{
+			comment(1, "mallory", commentMarker+"\nnot the bot"),
+			comment(2, actionsBot, commentMarker+"\nReleased v1.2.3"),
+		}, 2},
+		{"token user", &github.User{Login: github.String("release-bot")}, []*github.IssueComment{
+			comment(1, actionsBot, commentMarker),
+			comment(2, "release-bot", commentMarker),
+		}, 2},
+		{"only others", forbidden, []*github.IssueComment{
+			comment(1, "mallory", commentMarker),
+		}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, done := newTestClient(t, nil, map[string]interface{}{
+				"GET /user":                        tt.user,
+				"GET /repos/o/r/issues/7/comments": tt.comments,
+			})
+			defer done()
+
+			cm, err := c.findComment(context.Background(), 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cm.GetID() != tt.want {
+				t.Errorf("got comment %d, want %d", cm.GetID(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_checkRateLimit(t *testing.T) {
+	c, done := newTestClient(t, nil, map[string]interface{}{
+		"GET /rate_limit": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"core": map[string]interface{}{"limit": 5000, "remaining": 42, "reset": 1700000000},
+			},
+		},
+	})
+	defer done()
+
+	remaining, reset, err := c.rateLimit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 42 || reset.Unix() != 1700000000 {
+		t.Errorf("got %d left until %s, want 42 until 1700000000", remaining, reset)
+	}
+
+	// enough requests left, then too few but only a warning: neither exits
+	c.checkRateLimit(context.Background(), &config{rateLimitMin: 10})
+	c.checkRateLimit(context.Background(), &config{rateLimitMin: 50, rateLimitWarn: true})
+}
+
+func Test_checkRepos(t *testing.T) {
+	event := func(repo, base string) *github.PullRequestEvent {
+		return &github.PullRequestEvent{
+			Repo: &github.Repository{FullName: github.String(repo)},
+			PullRequest: &github.PullRequest{
+				Base: &github.PullRequestBranch{Repo: &github.Repository{FullName: github.String(base)}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		se       *github.PullRequestEvent
+		workflow string
+		ok       bool
+	}{
+		{"same", event("acme/app", "acme/app"), "acme/app", true},
+		{"case", event("Acme/App", "acme/app"), "ACME/app", true},
+		{"no workflow repo", event("acme/app", "acme/app"), "", true},
+		{"other base", event("acme/app", "mallory/app"), "acme/app", false},
+		{"other workflow", event("acme/app", "acme/app"), "acme/other", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkRepos(tt.se, tt.workflow); (err == nil) != tt.ok {
+				t.Errorf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_checkTargetSafety(t *testing.T) {
+	fork := &github.PullRequestEvent{
+		Repo: &github.Repository{FullName: github.String("acme/app")},
+		PullRequest: &github.PullRequest{
+			Head: &github.PullRequestBranch{Repo: &github.Repository{FullName: github.String("mallory/app")}},
+		},
+	}
+
+	tests := []struct {
+		name string
+		cfg  *config
+		ok   bool
+	}{
+		{"api only", &config{}, true},
+		{"hand off to goreleaser", &config{goreleaser: true}, true},
+		{"run goreleaser", &config{goreleaserRun: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkTargetSafety(tt.cfg, fork); (err == nil) != tt.ok {
+				t.Errorf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_labelRelease(t *testing.T) {
+	var created *github.Label
+	var added []string
+	c, done := newTestClient(t, nil, map[string]interface{}{
+		"GET /repos/o/r/labels/released: v1.2.3": func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		},
+		"POST /repos/o/r/labels": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&created)
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(created)
+		},
+		"POST /repos/o/r/issues/7/labels": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&added)
+			w.Write([]byte("[]"))
+		},
+	})
+	defer done()
+
+	if err := c.labelRelease(context.Background(), 7, "v1.2.3"); err != nil {
+		t.Fatal(err)
+	}
+	if created.GetName() != "released: v1.2.3" {
+		t.Errorf("got label %q created, want released: v1.2.3", created.GetName())
+	}
+	if !reflect.DeepEqual(added, []string{"released: v1.2.3"}) {
+		t.Errorf("got labels %q added, want released: v1.2.3", added)
+	}
+}
+
+func Test_setStatus(t *testing.T) {
+	var got github.RepoStatus
+	c, done := newTestClient(t, nil, map[string]interface{}{
+		"POST /repos/o/r/statuses/merge": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+		},
+	})
+	defer done()
+
+	if err := c.setStatus(context.Background(), "merge", "Skipped: no changes matching pattern", "https://github.com/o/r/actions/runs/1"); err != nil {
+		t.Fatal(err)
+	}
+	if got.GetContext() != "autotagger/release" || got.GetState() != "success" {
+		t.Errorf("got %s status %s, want a successful autotagger/release", got.GetContext(), got.GetState())
+	}
+	if got.GetDescription() != "Skipped: no changes matching pattern" || got.GetTargetURL() != "https://github.com/o/r/actions/runs/1" {
+		t.Errorf("got %q linking to %q", got.GetDescription(), got.GetTargetURL())
+	}
+}
+
+func Test_createCheckRun(t *testing.T) {
+	defer func(saved []string) { trace = saved }(trace)
+	trace = []string{"Bumping v1.2.0 to v1.3.0: minor bump from the PR's minor label", "Tagged version v1.3.0"}
+
+	var got github.CreateCheckRunOptions
+	c, done := newTestClient(t, nil, map[string]interface{}{
+		"POST /repos/o/r/check-runs": func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("{}"))
+		},
+	})
+	defer done()
+
+	if err := c.createCheckRun(context.Background(), "merge", "success", "Released v1.3.0", "## Notes"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "autotagger" || got.HeadSHA != "merge" || got.GetConclusion() != "success" {
+		t.Errorf("got %s on %s concluding %s", got.Name, got.HeadSHA, got.GetConclusion())
+	}
+	want := "```\n" + strings.Join(trace, "\n") + "\n```\n\n## Notes"
+	if summary := got.GetOutput().GetSummary(); summary != want {
+		t.Errorf("got summary %q, want %q", summary, want)
+	}
+	if title := got.GetOutput().GetTitle(); title != "Released v1.3.0" {
+		t.Errorf("got title %q", title)
+	}
+}
+
+func Test_mergeCommit(t *testing.T) {
+	fresh := func(merged bool, sha string) *github.PullRequest {
+		return &github.PullRequest{
+			Number:         github.Int(7),
+			Merged:         github.Bool(merged),
+			MergeCommitSHA: github.String(sha),
+			Base:           &github.PullRequestBranch{Ref: github.String("master")},
+		}
+	}
+	commits := []*github.RepositoryCommit{{SHA: github.String("first")}, {SHA: github.String("last")}}
+
+	tests := []struct {
+		name    string
+		pr      *github.PullRequest
+		compare string // status of master...last, "" for no request
+		want    string
+		err     bool
+	}{
+		{"from the API", fresh(true, "merge"), "", "merge", false},
+		{"not merged", fresh(false, ""), "", "", false},
+		{"last commit on the base", fresh(true, ""), "behind", "last", false},
+		{"rebased", fresh(true, ""), "diverged", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := map[string]interface{}{
+				"GET /repos/o/r/pulls/7":         tt.pr,
+				"GET /repos/o/r/pulls/7/commits": commits,
+			}
+			if tt.compare != "" {
+				routes["GET /repos/o/r/compare/master...last"] = &github.CommitsComparison{Status: github.String(tt.compare)}
+			}
+			c, done := newTestClient(t, nil, routes)
+			defer done()
+
+			got, err := c.mergeCommit(context.Background(), &github.PullRequest{Number: github.Int(7)})
+			if got != tt.want || (err != nil) != tt.err {
+				t.Errorf("got %q, %v, want %q, error %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// updateManifest records version as the latest one for prefix in the JSON
+// manifest at path on branch, which maps each prefix to its latest version:
+//
+//	{"": "v1.2.4", "api/": "api/v0.3.0"}
+//
+// Deployment tooling can then look up current versions without going
+// through all the tags.
+func (c *client) updateManifest(ctx context.Context, branch, path, prefix, version string) error {
+	msg := fmt.Sprintf("Update %s to %s", path, version)
+	return c.updateFile(ctx, branch, path, msg, func(old []byte) ([]byte, error) {
+		manifest := map[string]string{}
+		if len(old) > 0 {
+			if err := json.Unmarshal(old, &manifest); err != nil {
+				return nil, fmt.Errorf("could not parse %s: %v", path, err)
+			}
+		}
+
+		manifest[prefix] = version
+
+		b, err := json.MarshalIndent(manifest, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	})
+}
+
+// badge is a shields.io endpoint response, see https://shields.io/endpoint.
+type badge struct {
+	SchemaVersion int    `json:"schemaVersion"`
+	Label         string `json:"label"`
+	Message       string `json:"message"`
+	Color         string `json:"color"`
+}
+
+// badgePath returns where the badge for prefix is published by default:
+// badge.json for unprefixed tags, api-badge.json for api/ and so on.
+func badgePath(prefix string) string {
+	name := strings.Trim(strings.NewReplacer("/", "-", "_", "-").Replace(prefix), "-")
+	if name == "" {
+		return "badge.json"
+	}
+	return name + "-badge.json"
+}
+
+// badgeJSON renders the badge showing version as latest release.
+// Prereleases get a different color to stand out.
+func badgeJSON(label, version string) ([]byte, error) {
+	b := badge{SchemaVersion: 1, Label: label, Message: version, Color: "blue"}
+	if strings.Contains(version, "-") {
+		b.Color = "orange"
+	}
+
+	out, err := json.Marshal(b)
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// updateBadge publishes the version badge to path on branch.
+func (c *client) updateBadge(ctx context.Context, branch, path, label, version string) error {
+	content, err := badgeJSON(label, version)
+	if err != nil {
+		return err
+	}
+
+	msg := fmt.Sprintf("Update %s to %s", path, version)
+	return c.updateFile(ctx, branch, path, msg, func([]byte) ([]byte, error) {
+		return content, nil
+	})
+}
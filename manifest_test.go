@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+func Test_badgePath(t *testing.T) {
+	tests := map[string]string{
+		"":              "badge.json",
+		"api/":          "api-badge.json",
+		"services/api/": "services-api-badge.json",
+		"core_":         "core-badge.json",
+	}
+
+	for prefix, want := range tests {
+		t.Run(prefix, func(t *testing.T) {
+			if got := badgePath(prefix); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func Test_badgeJSON(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		{
+			version: "v1.2.4",
+			want:    `{"schemaVersion":1,"label":"version","message":"v1.2.4","color":"blue"}` + "\n",
+		},
+		{
+			version: "v1.2.4-rc.1",
+			want:    `{"schemaVersion":1,"label":"version","message":"v1.2.4-rc.1","color":"orange"}` + "\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.version, func(t *testing.T) {
+			got, err := badgeJSON("version", tc.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// migration maps an existing tag to its name under the new prefix.
+type migration struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	SHA    string `json:"sha"`
+	Status string `json:"status"` // created, exists, conflict or planned
+}
+
+// migrateCmd re-tags the version history under a new prefix scheme, e.g.
+// from v1.2.3 to service/v1.2.3 when a repo becomes a monorepo. The old tags
+// are kept, the new ones are created on the same commits. Without -create it
+// only reports the mapping.
+func migrateCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	from := fs.String("from", "", "prefix of the existing tags (default: none)")
+	to := fs.String("to", cfg.prefix, "prefix of the new tags (default: TAG_PREFIX)")
+	create := fs.Bool("create", false, "create the new tags instead of only reporting them")
+	report := fs.String("report", "", "also write the mapping report to this file, as JSON")
+	fs.Parse(args)
+
+	if *from == *to {
+		fatalf("-from and -to are both %q, there's nothing to migrate", *from)
+	}
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	// collect everything first, the new tags would show up while listing
+	existing := map[string]*github.Reference{}
+	var versions []*version.Version
+	err := cli.forEachTag(ctx, func(name string, r *github.Reference) {
+		existing[name] = r
+		if !strings.HasPrefix(name, *from) {
+			return
+		}
+		if v, err := version.NewSemver(strings.TrimPrefix(name, *from)); err == nil {
+			versions = append(versions, v)
+		}
+	})
+	if err != nil {
+		fatalf("could not list tags: %v", err)
+	}
+	sort.Sort(version.Collection(versions))
+
+	var migrations []migration
+	for _, v := range versions {
+		m := migration{From: *from + v.Original(), To: *to + v.Original(), Status: "planned"}
+
+		sha, err := cli.getTagSHA(ctx, m.From)
+		if err != nil {
+			fatalf("could not look up tag %s: %v", m.From, err)
+		}
+		m.SHA = sha
+
+		if _, ok := existing[m.To]; ok {
+			toSHA, err := cli.getTagSHA(ctx, m.To)
+			if err != nil {
+				fatalf("could not look up tag %s: %v", m.To, err)
+			}
+			m.Status = "exists"
+			if toSHA != sha {
+				m.Status = "conflict"
+			}
+		} else if *create {
+			t := cli.tags()
+			_, _, err := t.c.Git.CreateRef(ctx, t.owner, t.repo, &github.Reference{
+				Ref:    github.String("refs/tags/" + m.To),
+				Object: &github.GitObject{SHA: github.String(sha), Type: github.String("commit")},
+			})
+			if err != nil {
+				fatalf("could not create tag %s: %v", m.To, err)
+			}
+			m.Status = "created"
+		}
+
+		fmt.Printf("%-30s -> %-30s %.7s %s\n", m.From, m.To, m.SHA, m.Status)
+		migrations = append(migrations, m)
+	}
+
+	if *report != "" {
+		b, err := json.MarshalIndent(migrations, "", "  ")
+		if err != nil {
+			fatalf("could not encode report: %v", err)
+		}
+		if err := ioutil.WriteFile(*report, b, 0644); err != nil {
+			fatalf("could not write report: %v", err)
+		}
+	}
+
+	if !*create {
+		fmt.Printf("\n%d tags to migrate, run with -create to create them\n", len(migrations))
+	}
+}
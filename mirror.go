@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// splitRepository splits an owner/repo name.
+func splitRepository(name string) (owner, repo string, err error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("%q isn't owner/repo", name)
+	}
+	return parts[0], parts[1], nil
+}
+
+// useReleaseRepo makes c tag releases in the RELEASE_REPOSITORY, when it's
+// set, like a public mirror of a private repository. The versions are read
+// from its tags too, while pull requests, comments and statuses stay in c.
+// The mirror has to have the commits being released.
+func (c *client) useReleaseRepo(ctx context.Context, cfg *config) {
+	if cfg.releaseRepo == "" {
+		return
+	}
+	owner, repo, _ := splitRepository(cfg.releaseRepo)
+
+	gh := c.c
+	if tok := os.Getenv("RELEASE_TOKEN"); tok != "" {
+		gh = newTokenClient(ctx, tok)
+	}
+	server := os.Getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+	c.release = &client{c: gh, owner: owner, repo: repo, url: server + "/" + cfg.releaseRepo}
+	tracef("Releasing in %s", cfg.releaseRepo)
+}
+
+// tags returns the client for the repository releases are tagged in.
+func (c *client) tags() *client {
+	if c.release != nil {
+		return c.release
+	}
+	return c
+}
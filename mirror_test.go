@@ -0,0 +1,42 @@
+package main
+
+import "testing"
+
+func Test_splitRepository(t *testing.T) {
+	tcs := []struct {
+		in    string
+		owner string
+		repo  string
+		err   bool
+	}{
+		{in: "example/project", owner: "example", repo: "project"},
+		{in: "example", err: true},
+		{in: "example/", err: true},
+		{in: "/project", err: true},
+		{in: "example/project/extra", err: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			owner, repo, err := splitRepository(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error: %v", err, tc.err)
+			}
+			if owner != tc.owner || repo != tc.repo {
+				t.Errorf("got %s/%s, want %s/%s", owner, repo, tc.owner, tc.repo)
+			}
+		})
+	}
+}
+
+func Test_tags(t *testing.T) {
+	c := &client{owner: "example", repo: "private"}
+	if got := c.tags(); got != c {
+		t.Errorf("got %s/%s, want the client itself", got.owner, got.repo)
+	}
+
+	c.release = &client{owner: "example", repo: "project"}
+	if got := c.tags(); got != c.release {
+		t.Errorf("got %s/%s, want the release repository", got.owner, got.repo)
+	}
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// module is a component of a monorepo, tagged under its own prefix.
+type module struct {
+	Path   string `json:"path"`            // its directory, like services/api
+	Prefix string `json:"prefix"`          // its TAG_PREFIX, like api/
+	Files  string `json:"files,omitempty"` // its FILE_REGEXP (default: the files in Path)
+
+	Depends []string `json:"depends,omitempty"` // prefixes of the modules it depends on, for MODULES_CASCADE
+
+	match *regexp.Regexp // compiled Files
+}
+
+// modulesFile is the MODULES_FILE, listing the modules of a monorepo.
+type modulesFile struct {
+	Modules []module `json:"modules"`
+}
+
+// parseModules parses a MODULES_FILE like
+//
+//	{"modules": [{"path": "services/api", "prefix": "api/"}]}
+//
+// Modules without files match the files in their directory.
+func parseModules(b []byte) ([]module, error) {
+	var f modulesFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, err
+	}
+	if len(f.Modules) == 0 {
+		return nil, fmt.Errorf("no modules")
+	}
+
+	prefixes := map[string]bool{}
+	for i, m := range f.Modules {
+		m.Path = strings.Trim(path.Clean(m.Path), "/")
+		if m.Path == "" || m.Path == "." || strings.HasPrefix(m.Path, "..") {
+			return nil, fmt.Errorf("invalid path %q", f.Modules[i].Path)
+		}
+		if m.Prefix == "" {
+			return nil, fmt.Errorf("no prefix for %s", m.Path)
+		}
+		if prefixes[m.Prefix] {
+			return nil, fmt.Errorf("prefix %q is used twice", m.Prefix)
+		}
+		prefixes[m.Prefix] = true
+
+		if m.Files == "" {
+			m.Files = "^" + regexp.QuoteMeta(m.Path+"/")
+		}
+		var err error
+		if m.match, err = regexp.Compile(m.Files); err != nil {
+			return nil, fmt.Errorf("invalid files of %s: %v", m.Prefix, err)
+		}
+		f.Modules[i] = m
+	}
+	for _, m := range f.Modules {
+		for _, d := range m.Depends {
+			if !prefixes[d] {
+				return nil, fmt.Errorf("%s depends on %q, which isn't a module", m.Prefix, d)
+			}
+		}
+	}
+	return f.Modules, nil
+}
+
+// changedModules returns the modules among mods whose files pattern matches
+// one of the changed files, or every module when one of the files matches
+// globalPaths.
+func changedModules(mods []module, files, globalPaths []string) []module {
+	for _, f := range files {
+		if matchesPath(globalPaths, f) {
+			return mods
+		}
+	}
+
+	var changed []module
+	for _, m := range mods {
+		for _, f := range files {
+			if m.match.MatchString(f) {
+				changed = append(changed, m)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// prFiles returns the files changed by the pull request numbered number,
+// with the previous names of renamed ones.
+func (c *client) prFiles(ctx context.Context, number int) ([]string, error) {
+	var files []string
+	opt := &github.ListOptions{PerPage: 100}
+	for {
+		cfs, resp, err := c.c.PullRequests.ListFiles(ctx, c.owner, c.repo, number, opt)
+		if err != nil {
+			return nil, err
+		}
+		for _, cf := range cfs {
+			files = append(files, cf.GetFilename())
+			if cf.GetPreviousFilename() != "" {
+				files = append(files, cf.GetPreviousFilename())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return files, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// releaseModules releases every module of the MODULES_FILE changed by the
+// merged pull request pr, each the way a workflow with its TAG_PREFIX,
+// COMPONENT_PATH and FILE_REGEXP would.
+func (c *client) releaseModules(ctx context.Context, cfg *config, pr *github.PullRequest) {
+	fc, _, resp, err := c.c.Repositories.GetContents(ctx, c.owner, c.repo, cfg.modulesFile, &github.RepositoryContentGetOptions{Ref: pr.GetBase().GetRef()})
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		fatalf("MODULES_FILE %s doesn't exist on %s", cfg.modulesFile, pr.GetBase().GetRef())
+	}
+	if err != nil {
+		fatalf("could not get MODULES_FILE %s: %v", cfg.modulesFile, err)
+	}
+	if fc == nil {
+		fatalf("MODULES_FILE %s is a directory", cfg.modulesFile)
+	}
+	content, err := fc.GetContent()
+	if err != nil {
+		fatalf("could not decode MODULES_FILE %s: %v", cfg.modulesFile, err)
+	}
+	mods, err := parseModules([]byte(content))
+	if err != nil {
+		fatalf("invalid MODULES_FILE %s: %v", cfg.modulesFile, err)
+	}
+	if cfg.modulesCascade {
+		gomods, err := c.goMods(ctx, mods, pr.GetBase().GetRef())
+		if err != nil {
+			fatal(err)
+		}
+		if mods, err = cascade(mods, moduleDeps(mods, gomods)); err != nil {
+			fatalf("invalid MODULES_FILE %s: %v", cfg.modulesFile, err)
+		}
+	}
+
+	files, err := c.prFiles(ctx, pr.GetNumber())
+	if err != nil {
+		fatalf("could not list files of PR #%d: %v", pr.GetNumber(), err)
+	}
+	changed := changedModules(mods, files, cfg.globalPaths)
+	tracef("PR #%d changes %d of %d modules", pr.GetNumber(), len(changed), len(mods))
+	if len(changed) == 0 {
+		exitSkipped("no_matching_files")
+	}
+
+	var runs []separateRun
+	for _, m := range changed {
+		runs = append(runs, separateRun{fmt.Sprintf("%s (%s)", m.Prefix, m.Path), m.Prefix, moduleEnv(os.Environ(), m)})
+	}
+	releaseSeparately(runs)
+}
+
+// moduleEnv returns environ for releasing m on its own: without
+// MODULES_FILE, and with its TAG_PREFIX, COMPONENT_PATH and FILE_REGEXP.
+func moduleEnv(environ []string, m module) []string {
+	return overrideEnv(environ, map[string]string{
+		"TAG_PREFIX":     m.Prefix,
+		"COMPONENT_PATH": m.Path + "/",
+		"FILE_REGEXP":    m.Files,
+	}, "MODULES_FILE")
+}
+
+// parsePrefixes parses a TAG_PREFIX listing several prefixes, separated by
+// commas or newlines.
+func parsePrefixes(s string) ([]string, error) {
+	var prefixes []string
+	seen := map[string]bool{}
+	for _, p := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if p = strings.TrimSpace(p); p == "" {
+			continue
+		}
+		if seen[p] {
+			return nil, fmt.Errorf("prefix %q is listed twice", p)
+		}
+		seen[p] = true
+		prefixes = append(prefixes, p)
+	}
+	if len(prefixes) == 0 {
+		return nil, fmt.Errorf("no prefixes in %q", s)
+	}
+	return prefixes, nil
+}
+
+// releasePrefixes releases the merged pull request under each of the
+// prefixes TAG_PREFIX lists, independently, as if each had a workflow.
+func releasePrefixes(cfg *config) {
+	var runs []separateRun
+	for _, p := range cfg.prefixes {
+		runs = append(runs, separateRun{p, p, overrideEnv(os.Environ(), map[string]string{"TAG_PREFIX": p})})
+	}
+	releaseSeparately(runs)
+}
+
+// separateRun is a release done by running autotagger again.
+type separateRun struct {
+	name   string
+	prefix string
+	env    []string
+}
+
+// releaseSeparately carries out runs one after the other, each by running
+// autotagger again with its environment, so one failing or exiting early
+// doesn't stop the others. Each comments in its own section, by prefix. It
+// fails once they've all been tried, if any failed.
+func releaseSeparately(runs []separateRun) {
+	self, err := os.Executable()
+	if err != nil {
+		fatalf("could not find the autotagger executable: %v", err)
+	}
+
+	var failed []string
+	for _, r := range runs {
+		fmt.Printf("\n=== %s\n", r.name)
+		cmd := exec.Command(self)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		cmd.Env = r.env
+
+		err := cmd.Run()
+		if ee, ok := err.(*exec.ExitError); ok && ee.ExitCode() == exConfig {
+			err = nil
+		}
+		if err != nil {
+			fmt.Printf("Releasing %s failed: %v\n", r.prefix, err)
+			failed = append(failed, r.prefix)
+		}
+	}
+	if len(failed) > 0 {
+		fatalf("could not release %s", strings.Join(failed, ", "))
+	}
+}
+
+// overrideEnv returns environ with the variables in set set to their
+// values, in name order, and those in unset left out. Names are compared
+// without case on Windows, like the system does.
+func overrideEnv(environ []string, set map[string]string, unset ...string) []string {
+	key := func(name string) string { return name }
+	if runtime.GOOS == "windows" {
+		key = strings.ToUpper
+	}
+
+	drop := map[string]bool{}
+	for _, name := range unset {
+		drop[key(name)] = true
+	}
+	for name := range set {
+		drop[key(name)] = true
+	}
+
+	var env []string
+	for _, kv := range environ {
+		name := kv[:strings.Index(kv+"=", "=")]
+		if drop[key(name)] {
+			continue
+		}
+		env = append(env, kv)
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env = append(env, name+"="+set[name])
+	}
+	return env
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseModules(t *testing.T) {
+	tcs := []struct {
+		scenario string
+		in       string
+		want     []module
+		err      bool
+	}{
+		{
+			scenario: "modules",
+			in:       `{"modules": [{"path": "services/api", "prefix": "api/"}, {"path": "libs/core", "prefix": "core/"}]}`,
+			want: []module{
+				{Path: "services/api", Prefix: "api/", Files: `^services/api/`},
+				{Path: "libs/core", Prefix: "core/", Files: `^libs/core/`},
+			},
+		},
+		{
+			scenario: "slashes are trimmed",
+			in:       `{"modules": [{"path": "/services/api/", "prefix": "api/"}]}`,
+			want:     []module{{Path: "services/api", Prefix: "api/", Files: `^services/api/`}},
+		},
+		{
+			scenario: "files",
+			in:       `{"modules": [{"path": "services/api", "prefix": "api/", "files": "^(services/api|proto/api)/"}]}`,
+			want:     []module{{Path: "services/api", Prefix: "api/", Files: `^(services/api|proto/api)/`}},
+		},
+		{
+			scenario: "invalid files",
+			in:       `{"modules": [{"path": "services/api", "prefix": "api/", "files": "("}]}`,
+			err:      true,
+		},
+		{
+			scenario: "no modules",
+			in:       `{"modules": []}`,
+			err:      true,
+		},
+		{
+			scenario: "no prefix",
+			in:       `{"modules": [{"path": "services/api"}]}`,
+			err:      true,
+		},
+		{
+			scenario: "no path",
+			in:       `{"modules": [{"path": "/", "prefix": "api/"}]}`,
+			err:      true,
+		},
+		{
+			scenario: "outside the repository",
+			in:       `{"modules": [{"path": "../api", "prefix": "api/"}]}`,
+			err:      true,
+		},
+		{
+			scenario: "prefix used twice",
+			in:       `{"modules": [{"path": "services/api", "prefix": "api/"}, {"path": "services/api2", "prefix": "api/"}]}`,
+			err:      true,
+		},
+		{
+			scenario: "unknown dependency",
+			in:       `{"modules": [{"path": "services/api", "prefix": "api/", "depends": ["core/"]}]}`,
+			err:      true,
+		},
+		{
+			scenario: "invalid JSON",
+			in:       `{"modules": `,
+			err:      true,
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.scenario, func(t *testing.T) {
+			got, err := parseModules([]byte(tc.in))
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error: %v", err, tc.err)
+			}
+			for i := range got {
+				got[i].match = nil
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_changedModules(t *testing.T) {
+	mods, err := parseModules([]byte(`{"modules": [
+		{"path": "services/api", "prefix": "api/"},
+		{"path": "services/api-gateway", "prefix": "gateway/"},
+		{"path": "libs/core", "prefix": "core/", "files": "^(libs/core|proto)/"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tcs := []struct {
+		scenario    string
+		files       []string
+		globalPaths []string
+		want        []string
+	}{
+		{
+			scenario: "one module",
+			files:    []string{"services/api/main.go"},
+			want:     []string{"api/"},
+		},
+		{
+			scenario: "several modules",
+			files:    []string{"libs/core/core.go", "services/api/main.go"},
+			want:     []string{"api/", "core/"},
+		},
+		{
+			scenario: "files outside the module's directory",
+			files:    []string{"proto/core.proto"},
+			want:     []string{"core/"},
+		},
+		{
+			scenario: "directory names sharing a start",
+			files:    []string{"services/api-gateway/main.go"},
+			want:     []string{"gateway/"},
+		},
+		{
+			scenario: "outside every module",
+			files:    []string{"README.md", "services/README.md"},
+		},
+		{
+			scenario:    "global path",
+			files:       []string{"go.work"},
+			globalPaths: []string{"go.work"},
+			want:        []string{"api/", "gateway/", "core/"},
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.scenario, func(t *testing.T) {
+			var got []string
+			for _, m := range changedModules(mods, tc.files, tc.globalPaths) {
+				got = append(got, m.Prefix)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_moduleEnv(t *testing.T) {
+	environ := []string{"GITHUB_TOKEN=x", "MODULES_FILE=modules.json", "FILE_REGEXP=.*", "TAG_PREFIX="}
+	want := []string{"GITHUB_TOKEN=x", "COMPONENT_PATH=services/api/", `FILE_REGEXP=^services/api/`, "TAG_PREFIX=api/"}
+
+	got := moduleEnv(environ, module{Path: "services/api", Prefix: "api/", Files: `^services/api/`})
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func Test_parsePrefixes(t *testing.T) {
+	tcs := []struct {
+		in   string
+		want []string
+		err  bool
+	}{
+		{in: "api/,web/", want: []string{"api/", "web/"}},
+		{in: "api/, web/,", want: []string{"api/", "web/"}},
+		{in: "api/\nweb/\n", want: []string{"api/", "web/"}},
+		{in: "api/,api/", err: true},
+		{in: " , ", err: true},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.in, func(t *testing.T) {
+			got, err := parsePrefixes(tc.in)
+			if (err != nil) != tc.err {
+				t.Fatalf("got error %v, want error: %v", err, tc.err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_overrideEnv(t *testing.T) {
+	environ := []string{"GITHUB_TOKEN=x", "TAG_PREFIX=api/,web/", "EMPTY", "DRY_RUN=true"}
+	want := []string{"GITHUB_TOKEN=x", "EMPTY", "TAG_PREFIX=web/"}
+
+	got := overrideEnv(environ, map[string]string{"TAG_PREFIX": "web/"}, "DRY_RUN")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
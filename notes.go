@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// noteCategory is a release notes section gathering the pull requests
+// carrying a label.
+type noteCategory struct {
+	label string
+	title string
+}
+
+// parseNoteCategories parses a comma-separated list of label=Title pairs,
+// e.g. "kind/feature=Features,kind/bug=Bug Fixes". Sections keep that order.
+func parseNoteCategories(s string) ([]noteCategory, error) {
+	var cats []noteCategory
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid category %q, expected label=Title", pair)
+		}
+		cats = append(cats, noteCategory{label: strings.TrimSpace(parts[0]), title: strings.TrimSpace(parts[1])})
+	}
+	return cats, nil
+}
+
+// releaseNotes renders the markdown release notes for version, listing the
+// pull requests that went into it. The heading links to the compare view
+// when the previous version is known. With categories, pull requests are
+// grouped under the first category whose label they carry, the others
+// ending up under "Other changes".
+func releaseNotes(version, previous, repoURL string, prs []*github.PullRequest, categories []noteCategory) string {
+	var b strings.Builder
+
+	if previous != "" {
+		fmt.Fprintf(&b, "## [%s](%s/compare/%s...%s)\n\n", version, repoURL, previous, version)
+	} else {
+		fmt.Fprintf(&b, "## %s\n\n", version)
+	}
+
+	if len(categories) == 0 {
+		writeNoteEntries(&b, prs)
+		return b.String()
+	}
+
+	sections := make([][]*github.PullRequest, len(categories))
+	var other []*github.PullRequest
+	for _, pr := range prs {
+		i := noteCategoryOf(pr, categories)
+		if i < 0 {
+			other = append(other, pr)
+			continue
+		}
+		sections[i] = append(sections[i], pr)
+	}
+
+	for i, cat := range categories {
+		if len(sections[i]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "### %s\n\n", cat.title)
+		writeNoteEntries(&b, sections[i])
+		b.WriteString("\n")
+	}
+	if len(other) > 0 {
+		b.WriteString("### Other changes\n\n")
+		writeNoteEntries(&b, other)
+		b.WriteString("\n")
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// noteCategoryOf returns the index of the first category pr is labeled
+// with, or -1.
+func noteCategoryOf(pr *github.PullRequest, categories []noteCategory) int {
+	for i, cat := range categories {
+		if hasLabel(pr.Labels, cat.label) {
+			return i
+		}
+	}
+	return -1
+}
+
+func writeNoteEntries(b *strings.Builder, prs []*github.PullRequest) {
+	for _, pr := range prs {
+		fmt.Fprintf(b, "- %s (#%d)", strings.TrimSpace(pr.GetTitle()), pr.GetNumber())
+		if login := pr.GetUser().GetLogin(); login != "" {
+			fmt.Fprintf(b, " @%s", login)
+		}
+		b.WriteString("\n")
+	}
+}
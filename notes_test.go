@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func Test_releaseNotes(t *testing.T) {
+	prs := []*github.PullRequest{
+		{
+			Number: github.Int(12),
+			Title:  github.String("Add the thing "),
+			User:   &github.User{Login: github.String("jbowes")},
+			Labels: []*github.Label{{Name: github.String("kind/feature")}},
+		},
+		{
+			Number: github.Int(13),
+			Title:  github.String("Fix the thing"),
+			Labels: []*github.Label{{Name: github.String("kind/bug")}},
+		},
+		{
+			Number: github.Int(14),
+			Title:  github.String("Bump deps"),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		previous   string
+		categories []noteCategory
+		want       string
+	}{
+		{
+			name: "no previous version",
+			want: "## v1.2.4\n\n- Add the thing (#12) @jbowes\n- Fix the thing (#13)\n- Bump deps (#14)\n",
+		},
+		{
+			name:     "previous version",
+			previous: "v1.2.3",
+			want:     "## [v1.2.4](https://github.com/o/r/compare/v1.2.3...v1.2.4)\n\n- Add the thing (#12) @jbowes\n- Fix the thing (#13)\n- Bump deps (#14)\n",
+		},
+		{
+			name: "categories",
+			categories: []noteCategory{
+				{label: "kind/bug", title: "Bug Fixes"},
+				{label: "kind/feature", title: "Features"},
+				{label: "kind/docs", title: "Docs"},
+			},
+			want: "## v1.2.4\n\n" +
+				"### Bug Fixes\n\n- Fix the thing (#13)\n\n" +
+				"### Features\n\n- Add the thing (#12) @jbowes\n\n" +
+				"### Other changes\n\n- Bump deps (#14)\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := releaseNotes("v1.2.4", tc.previous, "https://github.com/o/r", prs, tc.categories)
+			if got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_parseNoteCategories(t *testing.T) {
+	got, err := parseNoteCategories("kind/feature=Features, kind/bug = Bug Fixes,")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []noteCategory{
+		{label: "kind/feature", title: "Features"},
+		{label: "kind/bug", title: "Bug Fixes"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if _, err := parseNoteCategories("kind/feature"); err == nil {
+		t.Error("expected an error for a category without title")
+	}
+}
+
+func Test_fragmentNotes(t *testing.T) {
+	tests := []struct {
+		name  string
+		frags []fragment
+		want  string
+	}{
+		{name: "none", want: ""},
+		{name: "empty", frags: []fragment{{path: "changes.d/12.md", text: "\n"}}, want: ""},
+		{
+			name: "fragments",
+			frags: []fragment{
+				{path: "changes.d/12.md", text: "Added the thing.\n"},
+				{path: "changes.d/13.md", text: "- Fixed the thing.\n\nIt was broken.\n"},
+			},
+			want: "\n### Notes\n\n- Added the thing.\n- Fixed the thing.\n\n  It was broken.\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := fragmentNotes(tc.frags); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
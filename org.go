@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// orgCmd runs backfill over many repositories at once, so a platform team
+// can run a single scheduled autotagger instead of a workflow per
+// repository. The repositories are either listed with -repos (or the
+// REPOSITORIES variable) or every active repository of -org. They all share
+// the settings from the environment. Without -create it only reports what it
+// would do.
+func orgCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("org", flag.ExitOnError)
+	org := fs.String("org", "", "tag every non-archived repository of this organization")
+	repos := fs.String("repos", os.Getenv("REPOSITORIES"), "comma-separated owner/repo list (default: REPOSITORIES)")
+	create := fs.Bool("create", false, "create the tags instead of only reporting them")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	gh := newGithubClient(ctx)
+
+	var names []string
+	for _, r := range strings.Split(*repos, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			names = append(names, r)
+		}
+	}
+
+	if *org != "" {
+		orgRepos, err := listOrgRepos(ctx, gh, *org)
+		if err != nil {
+			fatalf("could not list repositories of %s: %v", *org, err)
+		}
+		names = append(names, orgRepos...)
+	}
+
+	if len(names) == 0 {
+		fs.Usage()
+		fatal("no repositories given, use -org or -repos")
+	}
+	if cfg.releaseRepo != "" {
+		fatal("RELEASE_REPOSITORY can't be used for many repositories")
+	}
+	if *create && cfg.freeze {
+		fmt.Println("Releases are frozen, not backfilling")
+		exitSkipped("frozen")
+	}
+
+	server := os.Getenv("GITHUB_SERVER_URL")
+	if server == "" {
+		server = "https://github.com"
+	}
+
+	var failed []string
+	for _, name := range names {
+		fmt.Printf("\n=== %s\n", name)
+
+		parts := strings.Split(name, "/")
+		if len(parts) != 2 {
+			fmt.Printf("Invalid repository %q, expected owner/repo\n", name)
+			failed = append(failed, name)
+			continue
+		}
+
+		cli := &client{c: gh, owner: parts[0], repo: parts[1], url: server + "/" + name}
+		cli.checkRateLimit(ctx, cfg)
+
+		// one broken repository shouldn't hold up the others
+		if err := cli.backfill(ctx, cfg, "", *create, false); err != nil {
+			fmt.Printf("Could not backfill %s: %v\n", name, err)
+			failed = append(failed, name)
+		}
+	}
+
+	if len(failed) > 0 {
+		fatalf("%d of %d repositories failed: %s", len(failed), len(names), strings.Join(failed, ", "))
+	}
+}
+
+// listOrgRepos returns the full names of the organization's repositories
+// that aren't archived.
+func listOrgRepos(ctx context.Context, gh *github.Client, org string) ([]string, error) {
+	opt := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+
+	var names []string
+	for {
+		repos, resp, err := gh.Repositories.ListByOrg(ctx, org, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range repos {
+			if !r.GetArchived() {
+				names = append(names, r.GetFullName())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return names, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// codeownersPaths are where Github looks for CODEOWNERS, in order.
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// codeowners returns the CODEOWNERS file as of ref, or "" if there's none.
+func (c *client) codeowners(ctx context.Context, ref string) (string, error) {
+	for _, p := range codeownersPaths {
+		fc, _, resp, err := c.c.Repositories.GetContents(ctx, c.owner, c.repo, p, &github.RepositoryContentGetOptions{Ref: ref})
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if fc == nil {
+			continue
+		}
+		return fc.GetContent()
+	}
+	return "", nil
+}
+
+// ownersOf returns the owners CODEOWNERS gives the directory dir (like
+// "api/"), the last matching rule winning as on Github. Only rules that can
+// match a whole directory are considered.
+func ownersOf(codeowners, dir string) []string {
+	var owners []string
+	s := bufio.NewScanner(strings.NewReader(codeowners))
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if ownsDir(fields[0], dir) {
+			owners = fields[1:]
+		}
+	}
+	return owners
+}
+
+// ownsDir reports whether the CODEOWNERS pattern covers the directory dir,
+// itself or through a parent. Patterns with a slash are relative to the
+// root, others match at any depth.
+func ownsDir(pattern, dir string) bool {
+	if pattern == "*" || pattern == "/" || pattern == "**" || pattern == "/**" {
+		return true
+	}
+
+	pattern = strings.TrimSuffix(strings.TrimSuffix(pattern, "/**"), "/*")
+	pattern = strings.TrimSuffix(pattern, "/")
+	rooted := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	for p := strings.Trim(dir, "/"); p != "" && p != "."; p = path.Dir(p) {
+		name := p
+		if !rooted {
+			name = path.Base(p)
+		}
+		if m, _ := path.Match(pattern, name); m {
+			return true
+		}
+	}
+	return false
+}
+
+// releaseInfo is the release output, for downstream automation.
+type releaseInfo struct {
+	Version    string   `json:"version"`
+	Previous   string   `json:"previous,omitempty"`
+	Prefix     string   `json:"prefix"`
+	URL        string   `json:"url"`
+	CompareURL string   `json:"compare_url,omitempty"`
+	Owners     []string `json:"owners,omitempty"`
+}
+
+// setReleaseOutput describes the release as JSON in the release output.
+func setReleaseOutput(info releaseInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return setOutput("release", string(b))
+}
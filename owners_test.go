@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_ownersOf(t *testing.T) {
+	codeowners := `# default owners
+* @manifoldco/core
+
+/api/ @manifoldco/api
+docs @manifoldco/docs
+/services/*/billing/ @manifoldco/billing
+*.go @manifoldco/gophers
+`
+
+	tests := map[string][]string{
+		"":                       {"@manifoldco/core"},
+		"web/":                   {"@manifoldco/core"},
+		"api/":                   {"@manifoldco/api"},
+		"api/v2/":                {"@manifoldco/api"},
+		"lib/api/":               {"@manifoldco/core"},
+		"docs/":                  {"@manifoldco/docs"},
+		"web/docs/":              {"@manifoldco/docs"},
+		"services/eu/billing/":   {"@manifoldco/billing"},
+		"services/eu/invoicing/": {"@manifoldco/core"},
+	}
+
+	for dir, want := range tests {
+		t.Run(dir, func(t *testing.T) {
+			if got := ownersOf(codeowners, dir); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
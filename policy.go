@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resets says which bumps reset the numbers after the one they increment to
+// zero, set from VERSION_RESET. Semver resets them on every bump: v1.2.3 is
+// followed by v1.3.0 or v2.0.0.
+var resets = map[bump]bool{bumpMajor: true, bumpMinor: true, bumpPatch: true}
+
+// parseResets parses VERSION_RESET, the comma-separated bumps resetting the
+// numbers after theirs, like "major,minor", or "none".
+func parseResets(s string) (map[bump]bool, error) {
+	r := map[bump]bool{}
+	if strings.TrimSpace(s) == "none" {
+		return r, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		b, err := parseBump(name)
+		if err != nil {
+			return nil, err
+		}
+		r[b] = true
+	}
+	if len(r) == 0 {
+		return nil, fmt.Errorf("no bumps in %q, expected some of major, minor and patch, or none", s)
+	}
+	return r, nil
+}
+
+// resetsAll reports whether every bump resets the numbers after its own.
+func resetsAll(r map[bump]bool) bool {
+	return r[bumpMajor] && r[bumpMinor] && r[bumpPatch]
+}
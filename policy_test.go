@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+)
+
+func Test_parseResets(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    map[bump]bool
+		wantErr bool
+	}{
+		{"major,minor,patch", map[bump]bool{bumpMajor: true, bumpMinor: true, bumpPatch: true}, false},
+		{"major", map[bump]bool{bumpMajor: true}, false},
+		{" Major , minor", map[bump]bool{bumpMajor: true, bumpMinor: true}, false},
+		{"none", map[bump]bool{}, false},
+		{"", nil, true},
+		{"major,epoch", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseResets(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_nextVersion_resets(t *testing.T) {
+	defer func(r map[bump]bool) { resets = r }(resets)
+
+	tests := []struct {
+		resets string
+		bump   bump
+		want   string
+	}{
+		{"major,minor,patch", bumpMajor, "v2.0.0"},
+		{"major,minor,patch", bumpMinor, "v1.3.0"},
+		{"major", bumpMajor, "v2.0.0"},
+		{"major", bumpMinor, "v1.3.3"},
+		{"none", bumpMajor, "v2.2.3"},
+		{"none", bumpPatch, "v1.2.4"},
+	}
+
+	v := version.Must(version.NewSemver("v1.2.3"))
+	for _, tt := range tests {
+		t.Run(tt.resets+" "+tt.bump.String(), func(t *testing.T) {
+			resets, _ = parseResets(tt.resets)
+			if got := nextVersion(v, "", tt.bump); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
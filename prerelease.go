@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+)
+
+// prereleaseRE matches a PRERELEASE channel, a semver identifier that isn't
+// a number.
+var prereleaseRE = regexp.MustCompile(`^[0-9A-Za-z-]*[A-Za-z-][0-9A-Za-z-]*$`)
+
+// prereleaseParts splits the prerelease of v, like rc.3, into its channel
+// and counter. The counter is 0 when there's none.
+func prereleaseParts(v *version.Version) (string, int) {
+	parts := strings.SplitN(v.Prerelease(), ".", 2)
+	if len(parts) < 2 {
+		return parts[0], 0
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return parts[0], 0
+	}
+	return parts[0], n
+}
+
+// nextPrerelease returns the tag for the prerelease on channel after v,
+// bumped as b says. A prerelease already heads for a version, so it's kept
+// when it's bumped at least as much as b asks and only the counter goes up:
+// after v1.4.0-rc.2, a patch or minor bump is v1.4.0-rc.3 while a major
+// one is v2.0.0-rc.1.
+func nextPrerelease(v *version.Version, prefix, channel string, b bump) string {
+	segs := v.Segments()
+	for len(segs) < segments {
+		segs = append(segs, 0)
+	}
+	covered := b == bumpPatch
+	if !covered {
+		covered = true
+		for _, s := range segs[bumpIndex(b)+1:] {
+			covered = covered && s == 0
+		}
+	}
+
+	n := 0
+	if ch, last := prereleaseParts(v); ch == channel {
+		n = last
+	}
+	if v.Prerelease() == "" || !covered {
+		segs, n = bumpSegments(v, b), 0
+	}
+	d := newTagData(prefix, segs)
+	d.Channel, d.Number = channel, n+1
+	return formatTag(d)
+}
+
+// parseChannels parses BRANCH_CHANNELS, comma-separated branch=channel
+// pairs like "develop=beta,next=alpha". An empty channel, as in "main=",
+// means final releases.
+func parseChannels(s string) (map[string]string, error) {
+	channels := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		branch := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || branch == "" {
+			return nil, fmt.Errorf("invalid channel %q, expected branch=channel", pair)
+		}
+		ch := strings.TrimSpace(parts[1])
+		if ch != "" && !prereleaseRE.MatchString(ch) {
+			return nil, fmt.Errorf("invalid channel %q for %s: must be an identifier like beta", ch, branch)
+		}
+		channels[branch] = ch
+	}
+	return channels, nil
+}
+
+// useBranch picks the prerelease channel and, for a maintenance branch, the
+// maintenance line for releases from branch, as
+// BRANCH_CHANNELS says, falling back to PRERELEASE.
+func (cfg *config) useBranch(branch string) {
+	cfg.prerelease = cfg.defaultChannel
+	if ch, ok := cfg.channels[branch]; ok {
+		cfg.prerelease = ch
+	}
+	if len(cfg.channels) > 0 {
+		tracef("Releasing from %s on the %s channel", branch, channelName(cfg.prerelease))
+	}
+
+	cfg.line = nil
+	if _, ok := cfg.scheme.(semverScheme); ok {
+		cfg.line = branchLine(branch, cfg.prefix)
+	}
+	if cfg.line != nil {
+		tracef("Releasing from %s on the %s maintenance line", branch, cfg.line)
+	}
+}
+
+// channelName names a channel for humans.
+func channelName(ch string) string {
+	if ch == "" {
+		return "final"
+	}
+	return ch
+}
+
+// onChannel reports whether the version v is part of the history of the
+// channel: final versions always are, prereleases only on their own
+// channel. This keeps each channel counting on its own, and final releases
+// from bumping past prereleases of other branches.
+func onChannel(v *version.Version, channel string) bool {
+	ch, _ := prereleaseParts(v)
+	return ch == "" || ch == channel
+}
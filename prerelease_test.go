@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+)
+
+func Test_nextPrerelease(t *testing.T) {
+	tests := []struct {
+		previous string
+		bump     bump
+		want     string
+	}{
+		{"v1.3.2", bumpPatch, "v1.3.3-rc.1"},
+		{"v1.3.2", bumpMinor, "v1.4.0-rc.1"},
+		{"v1.4.0-rc.2", bumpPatch, "v1.4.0-rc.3"},
+		{"v1.4.0-rc.9", bumpMinor, "v1.4.0-rc.10"},
+		{"v1.4.0-rc.2", bumpMajor, "v2.0.0-rc.1"},
+		{"v1.3.3-rc.1", bumpMinor, "v1.4.0-rc.1"},
+		{"v2.0.0-rc.1", bumpMajor, "v2.0.0-rc.2"},
+		{"v1.4.0-beta.4", bumpPatch, "v1.4.0-rc.1"},
+		{"v1.4.0-rc", bumpPatch, "v1.4.0-rc.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.previous+" "+tt.bump.String(), func(t *testing.T) {
+			v, err := version.NewSemver(tt.previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := nextPrerelease(v, "", "rc", tt.bump); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseChannels(t *testing.T) {
+	channels, err := parseChannels("develop=beta, next=alpha,main=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"develop": "beta", "next": "alpha", "main": ""}
+	if !reflect.DeepEqual(channels, want) {
+		t.Errorf("got %v, want %v", channels, want)
+	}
+
+	for _, bad := range []string{"develop", "=beta", "develop=be.ta", "develop=12"} {
+		if _, err := parseChannels(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func Test_onChannel(t *testing.T) {
+	tests := []struct {
+		version, channel string
+		want             bool
+	}{
+		{"v1.4.0", "", true},
+		{"v1.4.0", "beta", true},
+		{"v1.5.0-beta.3", "beta", true},
+		{"v1.5.0-beta.3", "", false},
+		{"v1.5.0-alpha.1", "beta", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version+" "+tt.channel, func(t *testing.T) {
+			v, err := version.NewSemver(tt.version)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := onChannel(v, tt.channel); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
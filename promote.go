@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// reasons a promotion didn't happen
+const (
+	skipNoPrerelease = "no prerelease to promote"
+	skipSoaking      = "prerelease still soaking"
+)
+
+// promoteCmd formalizes the rc → GA handoff: it tags the commit of a
+// prerelease with the matching stable version, e.g. v1.4.0-rc.3 as v1.4.0,
+// and marks the prerelease's Github release, if any, as superseded.
+func promoteCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("promote", flag.ExitOnError)
+	from := fs.String("from", "", "the prerelease tag to promote, e.g. v1.4.0-rc.3 (required)")
+	fs.Parse(args)
+
+	if *from == "" {
+		fs.Usage()
+		fatal("-from is required")
+	}
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	if err := cli.soaked(ctx, cfg, *from); err != nil {
+		fatal(err)
+	}
+	stable, err := cli.promote(ctx, cfg.prefix, *from)
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("Promoted %s to %s\n", *from, stable)
+}
+
+// promote creates the stable tag for the prerelease tag from, on the same
+// commit, and returns it. It's fine for the stable tag to already exist on
+// that commit.
+func (c *client) promote(ctx context.Context, prefix, from string) (string, error) {
+	if c.release != nil {
+		return c.release.promote(ctx, prefix, from)
+	}
+	if !strings.HasPrefix(from, prefix) {
+		return "", fmt.Errorf("tag %s doesn't have the prefix %q", from, prefix)
+	}
+	v, err := version.NewSemver(strings.TrimPrefix(from, prefix))
+	if err != nil {
+		return "", fmt.Errorf("tag %s is not a valid semver: %v", from, err)
+	}
+	if v.Prerelease() == "" {
+		return "", fmt.Errorf("tag %s is not a prerelease", from)
+	}
+
+	sha, err := c.getTagSHA(ctx, from)
+	if err != nil {
+		return "", fmt.Errorf("could not look up tag %s: %v", from, err)
+	}
+	if sha == "" {
+		return "", fmt.Errorf("tag %s doesn't exist", from)
+	}
+
+	stable := finalVersion(v, prefix)
+
+	existing, err := c.getTagSHA(ctx, stable)
+	switch {
+	case err != nil:
+		return "", fmt.Errorf("could not look up tag %s: %v", stable, err)
+	case existing == sha:
+		fmt.Printf("Tag %s already exists on %s\n", stable, sha)
+	case existing != "":
+		return "", fmt.Errorf("tag %s already exists on another commit (%s)", stable, existing)
+	default:
+		_, _, err = c.c.Git.CreateRef(ctx, c.owner, c.repo, &github.Reference{
+			Ref:    github.String("refs/tags/" + stable),
+			Object: &github.GitObject{SHA: github.String(sha), Type: github.String("commit")},
+		})
+		if err != nil {
+			return "", fmt.Errorf("could not create tag %s: %v", stable, err)
+		}
+	}
+
+	if err := c.supersedeRelease(ctx, from, stable); err != nil {
+		return "", fmt.Errorf("could not mark release %s as superseded: %v", from, err)
+	}
+
+	return stable, nil
+}
+
+// decidePromotion works out the promotion of the prerelease last, tagged as
+// tag, to its stable version, on the same commit, instead of releasing ref,
+// the merge commit, with a bump. The
+// previous version is the last stable one, so the notes cover the whole
+// prerelease cycle.
+func (c *client) decidePromotion(ctx context.Context, cfg *config, last *version.Version, tag, ref string) decision {
+	if tag == "" || last.Prerelease() == "" {
+		tracef("Asked to promote, but the last version %s isn't a prerelease", last)
+		return decision{ref: ref, skip: skipNoPrerelease}
+	}
+
+	if err := c.soaked(ctx, cfg, tag); err != nil {
+		tracef("Not promoting %s: %v", tag, err)
+		return decision{ref: ref, skip: skipSoaking}
+	}
+
+	sha, err := c.getTagSHA(ctx, tag)
+	if err != nil {
+		fatalf("could not look up tag %s: %v", tag, err)
+	}
+
+	_, previous, err := c.getLastVersion(ctx, cfg.prefix, func(v *version.Version) bool { return v.Prerelease() == "" })
+	if err != nil && err != errNoVersions {
+		fatal(err)
+	}
+
+	d := decision{ref: sha, previous: previous, reason: "promotion of " + tag}
+	d.version, d.action = c.resolveConflict(ctx, finalVersion(last, cfg.prefix), sha, cfg.prefix, conflictFail)
+	tracef("Promoting %s to %s on %s", tag, d.version, sha)
+	return d
+}
+
+// soaked checks the prerelease tag has soaked long enough to be promoted,
+// as SOAK_TIME says: it's been out that long and there's no newer
+// prerelease of the same version.
+func (c *client) soaked(ctx context.Context, cfg *config, tag string) error {
+	if cfg.soakTime == 0 {
+		return nil
+	}
+
+	v, err := version.NewSemver(strings.TrimPrefix(tag, cfg.prefix))
+	if err != nil {
+		return fmt.Errorf("tag %s is not a valid semver: %v", tag, err)
+	}
+	n := newPrereleaseFinder(cfg.prefix, v)
+	if err := c.forEachTag(ctx, func(name string, r *github.Reference) {
+		n.add(name)
+	}); err != nil {
+		return err
+	}
+	if newer := n.tag; newer != "" {
+		return fmt.Errorf("%s is newer than %s, it has to soak first", newer, tag)
+	}
+
+	at, err := c.tagDate(ctx, tag)
+	if err != nil {
+		return fmt.Errorf("could not get date of %s: %v", tag, err)
+	}
+	if age := time.Since(at); age < cfg.soakTime {
+		return fmt.Errorf("%s has soaked for %s, less than SOAK_TIME (%s)", tag, age.Round(time.Minute), cfg.soakTime)
+	}
+	return nil
+}
+
+// newerPrerelease returns the tag among names of a prerelease of the same
+// version as v under prefix, but newer, or "" if there's none.
+func newerPrerelease(names []string, prefix string, v *version.Version) string {
+	n := newPrereleaseFinder(prefix, v)
+	for _, name := range names {
+		n.add(name)
+	}
+	return n.tag
+}
+
+// prereleaseFinder is what newerPrerelease looks for, kept as tags are
+// listed rather than holding on to all of them.
+type prereleaseFinder struct {
+	prefix string
+	final  string           // the final version v is a prerelease of
+	newest *version.Version // v, or the newest prerelease found
+	tag    string           // the tag of the newest prerelease found
+}
+
+// newPrereleaseFinder looks for prereleases under prefix newer than v.
+func newPrereleaseFinder(prefix string, v *version.Version) *prereleaseFinder {
+	return &prereleaseFinder{prefix: prefix, final: finalVersion(v, prefix), newest: v}
+}
+
+// add takes the tag name into account.
+func (n *prereleaseFinder) add(name string) {
+	if !strings.HasPrefix(name, n.prefix) {
+		return
+	}
+	o, err := version.NewSemver(strings.TrimPrefix(name, n.prefix))
+	if err != nil || o.Prerelease() == "" || finalVersion(o, n.prefix) != n.final {
+		return
+	}
+	if o.GreaterThan(n.newest) {
+		n.newest, n.tag = o, name
+	}
+}
+
+// tagDate returns when tag was made: its tagger's date when it's annotated,
+// or else the date of its commit.
+func (c *client) tagDate(ctx context.Context, tag string) (time.Time, error) {
+	info, err := c.getTagInfo(ctx, tag)
+	return info.date, err
+}
+
+// supersedeRelease notes on the Github release of tag, if there's one, that
+// it was superseded by the given version.
+func (c *client) supersedeRelease(ctx context.Context, tag, by string) error {
+	rel, resp, err := c.c.Repositories.GetReleaseByTag(ctx, c.owner, c.repo, tag)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	note := fmt.Sprintf("**Superseded by %s.**", by)
+	if strings.HasPrefix(rel.GetBody(), note) {
+		return nil
+	}
+
+	_, _, err = c.c.Repositories.EditRelease(ctx, c.owner, c.repo, rel.GetID(), &github.RepositoryRelease{
+		Body: github.String(note + "\n\n" + rel.GetBody()),
+	})
+	return err
+}
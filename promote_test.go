@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-version"
+)
+
+func Test_newerPrerelease(t *testing.T) {
+	names := []string{"api/v1.4.0-rc.1", "api/v1.4.0-rc.3", "api/v1.4.0-rc.10", "api/v1.5.0-rc.1", "v1.4.0-rc.20", "api/v1.3.0"}
+
+	tests := map[string]string{
+		"v1.4.0-rc.3":  "api/v1.4.0-rc.10",
+		"v1.4.0-rc.10": "",
+		"v1.5.0-rc.1":  "",
+		"v1.3.0-rc.1":  "",
+	}
+
+	for from, want := range tests {
+		t.Run(from, func(t *testing.T) {
+			v, err := version.NewSemver(from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := newerPrerelease(names, "api/", v); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
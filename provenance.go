@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// policyEnv are the settings that shape the decision, hashed into the
+// provenance statement so it can be checked against the expected policy.
+var policyEnv = []string{
+	"TAG_PREFIX",
+	"SEED_FROM_UNPREFIXED",
+	"NO_TAGS",
+	"INITIAL_VERSION",
+	"BOOTSTRAP_TAGS",
+	"MIN_VERSION",
+	"MIXED_TAGS",
+	"MODULES_FILE",
+	"MODULES_CASCADE",
+	"RELEASE_REPOSITORY",
+	"FILE_REGEXP",
+	"GLOBAL_PATHS",
+	"IGNORE_DELETIONS",
+	"IGNORE_RENAMES",
+	"TAG_CONFLICT",
+	"VERSION_SCHEME",
+	"VERSION_SEGMENTS",
+	"PATCH_SEGMENT",
+	"VERSION_RESET",
+	"TAG_TEMPLATE",
+	"V_PREFIX",
+	"CALVER_FORMAT",
+	"PRERELEASE",
+	"BRANCH_CHANNELS",
+	"BUMP_FROM_COMMIT",
+	"BUMP_FROM_COMMITS",
+	"BUMP_FROM_TITLE",
+	"TITLE_KEYWORDS",
+	"BUMP_PATHS",
+	"BUMP_FLOOR",
+	"BUMP_CAP",
+	"CAP_MAJOR",
+	"APIDIFF",
+	"GORELEASE",
+	"REQUIRE_STATUS",
+	"APPROVAL_ENVIRONMENT",
+	"STALE_AFTER",
+	"CONFIRM_MAJOR",
+	"FREEZE",
+	"AGGREGATE",
+	"RELEASE_PR",
+	"PROMOTE",
+	"PROMOTE_LABEL",
+	"SOAK_TIME",
+}
+
+// statement describes a release decision and what it was made from.
+type statement struct {
+	Repository string    `json:"repository"`
+	Event      string    `json:"event"`  // the event name
+	Run        string    `json:"run"`    // the workflow run ID
+	Number     int       `json:"number"` // the pull request
+	Commit     string    `json:"commit"`
+	Previous   string    `json:"previous,omitempty"`
+	Version    string    `json:"version"`
+	Reason     string    `json:"reason"`
+	PolicyHash string    `json:"policy_hash"`
+	Time       time.Time `json:"time"`
+}
+
+// policyHash hashes the policyEnv settings, set or not.
+func policyHash(getenv func(string) string) string {
+	h := sha256.New()
+	for _, name := range policyEnv {
+		fmt.Fprintf(h, "%s=%q\n", name, getenv(name))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newStatement describes the decision d about pull request number.
+func (c *client) newStatement(d decision, number int) statement {
+	return statement{
+		Repository: c.owner + "/" + c.repo,
+		Event:      os.Getenv("GITHUB_EVENT_NAME"),
+		Run:        os.Getenv("GITHUB_RUN_ID"),
+		Number:     number,
+		Commit:     d.ref,
+		Previous:   d.previous,
+		Version:    d.version,
+		Reason:     d.reason,
+		PolicyHash: policyHash(os.Getenv),
+		Time:       time.Now().UTC(),
+	}
+}
+
+// oidcToken requests a Github Actions OIDC token for audience. It's signed
+// by Github and names the repository, workflow and run it was issued to.
+// The workflow needs the id-token: write permission. Anyone holding the
+// token can use it until it expires, so it's never published.
+func oidcToken(audience string) (string, error) {
+	reqURL, reqToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"), os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if reqURL == "" || reqToken == "" {
+		return "", fmt.Errorf("no OIDC token available, give the workflow the id-token: write permission")
+	}
+
+	req, err := http.NewRequest(http.MethodGet, reqURL+"&audience="+url.QueryEscape(audience), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+reqToken)
+
+	hc := &http.Client{Timeout: 10 * time.Second}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token request returned %s", resp.Status)
+	}
+
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.Value, nil
+}
+
+// attestDecision signs the statement for d with Sigstore and attaches it to
+// the merge commit as an autotagger/provenance check run. The signing
+// certificate names this workflow run, tying the exact statement to it.
+func (c *client) attestDecision(ctx context.Context, d decision, number int) error {
+	b, err := json.MarshalIndent(c.newStatement(d, number), "", "  ")
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(b)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	token, err := oidcToken("sigstore")
+	if err != nil {
+		return fmt.Errorf("could not sign the decision: %v", err)
+	}
+	sig, err := sigstoreSign(b, token)
+	if err != nil {
+		return fmt.Errorf("could not sign the decision: %v", err)
+	}
+
+	summary := fmt.Sprintf("Statement (%s):\n\n```json\n%s\n```\n\nSignature, in Rekor entry %d (%s):\n\n```\n%s\n```\n\nCertificate:\n\n```\n%s```",
+		digest, b, sig.LogIndex, sig.LogID, sig.Signature, sig.Certificate)
+	_, _, err = c.c.Checks.CreateCheckRun(ctx, c.owner, c.repo, github.CreateCheckRunOptions{
+		Name:        "autotagger/provenance",
+		HeadSHA:     d.ref,
+		Status:      github.String("completed"),
+		Conclusion:  github.String("success"),
+		CompletedAt: &github.Timestamp{Time: time.Now()},
+		Output: &github.CheckRunOutput{
+			Title:   github.String("Provenance of " + d.version),
+			Summary: github.String(summary),
+		},
+	})
+	return err
+}
@@ -0,0 +1,24 @@
+package main
+
+import "testing"
+
+func Test_policyHash(t *testing.T) {
+	env := map[string]string{"TAG_PREFIX": "api/"}
+	getenv := func(name string) string { return env[name] }
+
+	before := policyHash(getenv)
+	if policyHash(getenv) != before {
+		t.Error("hash isn't stable")
+	}
+
+	env["TAG_CONFLICT"] = "skip"
+	if policyHash(getenv) == before {
+		t.Error("hash didn't change with the policy")
+	}
+
+	env["GITHUB_TOKEN"] = "secret"
+	delete(env, "TAG_CONFLICT")
+	if policyHash(getenv) != before {
+		t.Error("hash changed with a setting outside the policy")
+	}
+}
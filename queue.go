@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// gate returns why the release decided in d can't go out yet, or an empty
+// string if nothing blocks it.
+func (c *client) gate(ctx context.Context, cfg *config, d decision) string {
+	if cfg.requireStatus {
+		st, _, err := c.c.Repositories.GetCombinedStatus(ctx, c.owner, c.repo, d.ref, nil)
+		if err != nil {
+			fatalf("could not get status of %s: %v", d.ref, err)
+		}
+		// without any status, Github reports the combined state as pending
+		if st.GetTotalCount() > 0 && st.GetState() != "success" {
+			return fmt.Sprintf("commit status of %s is %s", d.ref, st.GetState())
+		}
+	}
+	if cfg.approvalEnv != "" {
+		if reason := c.approvalGate(ctx, cfg, d); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
+
+// The queue of releases blocked by gates is kept in an issue, so it's
+// visible to maintainers and survives between runs. Anyone can open an issue
+// with the marker, so only issues with the label count: labels can only be
+// applied by those with triage access, and are dropped from issues others
+// open.
+const (
+	queueMarker = "<!-- autotagger-queue -->"
+	queueTitle  = "Pending releases"
+	queueLabel  = "autotagger-queue"
+)
+
+var queueEntryRE = regexp.MustCompile(`(?m)^- #(\d+)`)
+
+// parseQueue returns the pull request numbers queued in the issue body.
+func parseQueue(body string) []int {
+	var prs []int
+	for _, m := range queueEntryRE.FindAllStringSubmatch(body, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			prs = append(prs, n)
+		}
+	}
+	return prs
+}
+
+// renderQueue renders the queue issue body for prs.
+func renderQueue(prs []int) string {
+	var b strings.Builder
+	b.WriteString(queueMarker + "\n")
+	b.WriteString("These merged pull requests are waiting for their release gates to clear. ")
+	b.WriteString("Run `autotagger flush` to release them.\n\n")
+	for _, n := range prs {
+		fmt.Fprintf(&b, "- #%d\n", n)
+	}
+	return b.String()
+}
+
+// findQueue returns the open queue issue, or nil if there's none.
+func (c *client) findQueue(ctx context.Context) (*github.Issue, error) {
+	opt := &github.IssueListByRepoOptions{State: "open", Labels: []string{queueLabel}, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		issues, resp, err := c.c.Issues.ListByRepo(ctx, c.owner, c.repo, opt)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, i := range issues {
+			if i.PullRequestLinks == nil && issueLabeled(i, queueLabel) && strings.Contains(i.GetBody(), queueMarker) {
+				return i, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			return nil, nil
+		}
+		opt.Page = resp.NextPage
+	}
+}
+
+// enqueue adds the pull request to the queue of pending releases.
+func (c *client) enqueue(ctx context.Context, number int) error {
+	q, err := c.findQueue(ctx)
+	if err != nil {
+		return err
+	}
+
+	if q == nil {
+		_, _, err = c.c.Issues.Create(ctx, c.owner, c.repo, &github.IssueRequest{
+			Title:  github.String(queueTitle),
+			Body:   github.String(renderQueue([]int{number})),
+			Labels: &[]string{queueLabel},
+		})
+		return err
+	}
+
+	prs := parseQueue(q.GetBody())
+	for _, n := range prs {
+		if n == number {
+			return nil
+		}
+	}
+	return c.setQueue(ctx, q, append(prs, number))
+}
+
+// setQueue updates the queue issue to hold prs, closing it once it's empty.
+func (c *client) setQueue(ctx context.Context, q *github.Issue, prs []int) error {
+	req := &github.IssueRequest{Body: github.String(renderQueue(prs))}
+	if len(prs) == 0 {
+		req.State = github.String("closed")
+	}
+
+	_, _, err := c.c.Issues.Edit(ctx, c.owner, c.repo, q.GetNumber(), req)
+	return err
+}
+
+// flushCmd releases the queued pull requests whose gates have cleared, in
+// the order they were merged. The others stay queued.
+func flushCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("flush", flag.ExitOnError)
+	fs.Parse(args)
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	if cfg.freeze {
+		fmt.Println("Releases are frozen, not flushing the queue")
+		exitSkipped("frozen")
+	}
+
+	q, err := cli.findQueue(ctx)
+	if err != nil {
+		fatalf("could not find the release queue: %v", err)
+	}
+	if q == nil {
+		fmt.Println("No pending releases")
+		return
+	}
+
+	var prs []*github.PullRequest
+	for _, n := range parseQueue(q.GetBody()) {
+		pr, _, err := cli.c.PullRequests.Get(ctx, cli.owner, cli.repo, n)
+		if err != nil {
+			fatalf("could not get pull request #%d: %v", n, err)
+		}
+		prs = append(prs, pr)
+	}
+	sort.Slice(prs, func(i, j int) bool {
+		return prs[i].GetMergedAt().Before(prs[j].GetMergedAt())
+	})
+
+	pending := cli.flushQueue(ctx, cfg, prs)
+	if err := cli.setQueue(ctx, q, pending); err != nil {
+		fatalf("could not update the release queue: %v", err)
+	}
+	fmt.Printf("\n%d releases still pending\n", len(pending))
+}
+
+// flushQueue releases the queued prs whose gates have cleared, and returns
+// those still blocked. Pull requests that weren't merged are dropped: their
+// merge commit is only GitHub's test merge.
+func (c *client) flushQueue(ctx context.Context, cfg *config, prs []*github.PullRequest) []int {
+	var pending []int
+	for _, pr := range prs {
+		fmt.Printf("\n#%d %s\n", pr.GetNumber(), pr.GetTitle())
+		if !pr.GetMerged() {
+			fmt.Printf("PR #%d isn't merged, dropping it from the queue\n", pr.GetNumber())
+			continue
+		}
+
+		cfg.useBranch(pr.GetBase().GetRef())
+		last, base, err := c.lastRelease(ctx, cfg)
+		if err != nil {
+			fatal(err)
+		}
+
+		d := c.decide(ctx, cfg, pr, last, base, base, pr.GetMergeCommitSHA())
+		if d.skip == "" {
+			if reason := c.gate(ctx, cfg, d); reason != "" {
+				fmt.Printf("Still blocked: %s\n", reason)
+				pending = append(pending, pr.GetNumber())
+				continue
+			}
+		}
+		c.apply(ctx, cfg, d, pr)
+	}
+	return pending
+}
+
+// issueLabeled reports whether i has the label name.
+func issueLabeled(i *github.Issue, name string) bool {
+	for _, l := range i.Labels {
+		if strings.EqualFold(l.GetName(), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+)
+
+func Test_queueRoundTrip(t *testing.T) {
+	tests := [][]int{
+		nil,
+		{12},
+		{12, 7, 42},
+	}
+
+	for _, prs := range tests {
+		got := parseQueue(renderQueue(prs))
+		if !reflect.DeepEqual(got, prs) {
+			t.Errorf("got %v, want %v", got, prs)
+		}
+	}
+}
+
+func Test_flushQueueUnmerged(t *testing.T) {
+	c, done := newTestClient(t, nil, nil)
+	defer done()
+
+	prs := []*github.PullRequest{{
+		Number:         github.Int(7),
+		Merged:         github.Bool(false),
+		MergeCommitSHA: github.String("abc123"),
+		Base:           &github.PullRequestBranch{Ref: github.String("master")},
+	}}
+	if pending := c.flushQueue(context.Background(), &config{}, prs); len(pending) != 0 {
+		t.Errorf("got pending %v, want none", pending)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// recording is a bundle of a run's event and API traffic, for attaching to
+// bug reports and replaying the decision offline.
+type recording struct {
+	EventName string          `json:"event_name"`
+	Event     json.RawMessage `json:"event,omitempty"`
+	Exchanges []exchange      `json:"exchanges"`
+}
+
+// exchange is one recorded API request and its response.
+type exchange struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+	Link   string `json:"link,omitempty"` // pagination
+	Body   string `json:"body"`
+}
+
+// secretRE matches GitHub tokens, in case one ends up in a response.
+var secretRE = regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{20,})\b`)
+
+// redact hides tokens in s.
+func redact(s string) string {
+	return secretRE.ReplaceAllString(s, "[REDACTED]")
+}
+
+// recordTransport saves every API exchange to a recording at path. Request
+// headers, and with them the token, are never recorded. The file is
+// rewritten after each exchange, since the run may exit at any point.
+type recordTransport struct {
+	base http.RoundTripper
+	path string
+
+	mu  sync.Mutex
+	rec recording
+}
+
+// newRecordTransport starts a recording at path with the run's event.
+func newRecordTransport(base http.RoundTripper, path string) *recordTransport {
+	t := &recordTransport{base: base, path: path}
+	t.rec.EventName = os.Getenv("GITHUB_EVENT_NAME")
+	if b, err := ioutil.ReadFile(os.Getenv("GITHUB_EVENT_PATH")); err == nil && json.Valid(b) {
+		t.rec.Event = json.RawMessage(redact(string(b)))
+	}
+	return t
+}
+
+func (t *recordTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.rec.Exchanges = append(t.rec.Exchanges, exchange{
+		Method: req.Method,
+		URL:    req.URL.String(),
+		Status: resp.StatusCode,
+		Link:   resp.Header.Get("Link"),
+		Body:   redact(string(body)),
+	})
+
+	b, err := json.MarshalIndent(t.rec, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(t.path, b, 0600)
+	}
+	if err != nil {
+		fmt.Printf("Could not write recording %s: %v\n", t.path, err)
+	}
+	return resp, nil
+}
+
+// replayTransport answers API requests from a recording, in the order they
+// were made, and refuses anything that wasn't recorded.
+type replayTransport struct {
+	mu        sync.Mutex
+	exchanges []exchange
+	used      []bool
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for i, e := range t.exchanges {
+		if t.used[i] || e.Method != req.Method || e.URL != req.URL.String() {
+			continue
+		}
+		t.used[i] = true
+
+		h := http.Header{"Content-Type": {"application/json"}}
+		if e.Link != "" {
+			h.Set("Link", e.Link)
+		}
+		return &http.Response{
+			StatusCode: e.Status,
+			Status:     fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+			Header:     h,
+			Body:       ioutil.NopCloser(bytes.NewBufferString(e.Body)),
+			Request:    req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%s %s isn't in the recording", req.Method, req.URL)
+}
+
+// replayCmd implements the replay command, which runs the decision for a
+// recorded pull request event again without touching the API, using the
+// configuration from the environment.
+func replayCmd(cfg *config, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: autotagger replay <recording.json>")
+		os.Exit(exConfig)
+	}
+
+	rec, err := readRecording(args[0])
+	if err != nil {
+		fatal(err)
+	}
+
+	pr, d, err := replay(cfg, rec)
+	if err == errNotMerged {
+		fmt.Printf("PR #%d was closed without being merged\n", pr.GetNumber())
+		return
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	fmt.Printf("\nReplayed #%d (%s event, merge commit %s)\n", pr.GetNumber(), rec.EventName, d.ref)
+	if d.skip != "" {
+		fmt.Printf("Would skip: %s\n", d.skip)
+		return
+	}
+	fmt.Printf("Would tag %s as %s (%s)\n", d.ref, d.version, d.reason)
+}
+
+// readRecording reads the recording at path.
+func readRecording(path string) (recording, error) {
+	var rec recording
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return rec, fmt.Errorf("could not read recording: %v", err)
+	}
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return rec, fmt.Errorf("could not parse recording %s: %v", path, err)
+	}
+	return rec, nil
+}
+
+// errNotMerged is returned by replay for pull requests closed without
+// being merged.
+var errNotMerged = errors.New("the pull request wasn't merged")
+
+// replay decides the release of the pull request of the event in rec,
+// answering API requests from it.
+func replay(cfg *config, rec recording) (*github.PullRequest, decision, error) {
+	var se github.PullRequestEvent
+	if err := json.Unmarshal(rec.Event, &se); err != nil || se.PullRequest == nil || se.Repo == nil {
+		return nil, decision{}, errors.New("the recording doesn't hold a pull request event")
+	}
+
+	rt := &replayTransport{exchanges: rec.Exchanges, used: make([]bool, len(rec.Exchanges))}
+	ctx := context.Background()
+	cli := &client{
+		c:     github.NewClient(&http.Client{Transport: rt}),
+		owner: se.GetRepo().GetOwner().GetLogin(), repo: se.GetRepo().GetName(), url: se.GetRepo().GetHTMLURL(),
+	}
+	if cfg.releaseRepo != "" {
+		// recorded with the rest, whichever token it used
+		owner, repo, _ := splitRepository(cfg.releaseRepo)
+		cli.release = &client{c: cli.c, owner: owner, repo: repo}
+	}
+
+	ref := se.PullRequest.GetMergeCommitSHA()
+	if se.PullRequest.Merged == nil || ref == "" {
+		var err error
+		if ref, err = cli.mergeCommit(ctx, se.PullRequest); err != nil {
+			return se.PullRequest, decision{}, err
+		}
+		if ref == "" {
+			return se.PullRequest, decision{}, errNotMerged
+		}
+	}
+
+	cfg.useBranch(se.PullRequest.GetBase().GetRef())
+	last, base, err := cli.lastRelease(ctx, cfg)
+	if err != nil {
+		return se.PullRequest, decision{}, err
+	}
+	return se.PullRequest, cli.decide(ctx, cfg, se.PullRequest, last, base, base, ref), nil
+}
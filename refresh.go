@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// refreshVersion replaces the version prev in text with next, where it
+// stands on its own, with or without a leading v: v1.2.3 in a badge URL like
+// version-v1.2.3-blue or "1.2.3" in a version constant, but not 1.2.3 in
+// 11.2.3 or 1.2.3.4. prev and next are taken without their v.
+func refreshVersion(text, prev, next string) string {
+	if prev == "" {
+		return text
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(text, prev)
+		if i < 0 {
+			b.WriteString(text)
+			return b.String()
+		}
+		end := i + len(prev)
+
+		before := i
+		if before > 0 && (text[before-1] == 'v' || text[before-1] == 'V') {
+			before--
+		}
+		// a dot may end a sentence after it, but not start another number
+		ends := end == len(text) || !versionChar(text[end]) ||
+			text[end] == '.' && (end+1 == len(text) || !isDigit(text[end+1]))
+		standalone := ends && (before == 0 || !versionChar(text[before-1]))
+
+		b.WriteString(text[:i])
+		if standalone {
+			b.WriteString(next)
+		} else {
+			b.WriteString(prev)
+		}
+		text = text[end:]
+	}
+}
+
+// versionChar reports whether c can be part of a version number or a word
+// around it.
+func versionChar(c byte) bool {
+	return c == '.' || isDigit(c) || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// bareVersion returns tag without prefix and the leading v.
+func bareVersion(tag, prefix string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(tag, prefix), "v")
+}
+
+// refreshFiles replaces the previous version with d's in REFRESH_FILES, like
+// badges and version constants, along with the major version tag moving. The
+// change is proposed in a pull request from REFRESH_BRANCH into base, or with
+// REFRESH_COMMIT, committed to base right away. Prereleases and first
+// releases leave them alone.
+func (c *client) refreshFiles(ctx context.Context, cfg *config, d decision, base string) error {
+	if majorTag(d.version, cfg.prefix) == "" || d.previous == "" {
+		return nil
+	}
+	prev, next := bareVersion(d.previous, cfg.prefix), bareVersion(d.version, cfg.prefix)
+
+	branch := base
+	if !cfg.refreshCommit {
+		branch = cfg.refreshBranch
+		if err := c.resetBranch(ctx, branch, d.ref); err != nil {
+			return fmt.Errorf("could not cut %s: %v", branch, err)
+		}
+	}
+
+	changed := false
+	for _, path := range cfg.refreshFiles {
+		err := c.updateFile(ctx, branch, path, "Refresh "+path+" for "+d.version, func(old []byte) ([]byte, error) {
+			if old == nil {
+				return nil, fmt.Errorf("%s doesn't exist", path)
+			}
+			text := refreshVersion(string(old), prev, next)
+			changed = changed || text != string(old)
+			return []byte(text), nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not update %s: %v", path, err)
+		}
+	}
+	if !changed {
+		tracef("%s don't mention %s, nothing to refresh", strings.Join(cfg.refreshFiles, ", "), d.previous)
+		return nil
+	}
+	if cfg.refreshCommit {
+		tracef("Refreshed %s on %s for %s", strings.Join(cfg.refreshFiles, ", "), base, d.version)
+		return nil
+	}
+
+	title := "Refresh version references for " + d.version
+	body := fmt.Sprintf("Updates %s from %s to %s.", strings.Join(cfg.refreshFiles, ", "), d.previous, d.version)
+	number, opened, err := c.openPR(ctx, branch, base, title, body)
+	if err != nil {
+		return err
+	}
+	if opened {
+		tracef("Opened PR #%d refreshing version references for %s", number, d.version)
+	} else {
+		tracef("Updated PR #%d refreshing version references for %s", number, d.version)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func Test_refreshVersion(t *testing.T) {
+	tcs := []struct {
+		scenario string
+		in       string
+		want     string
+	}{
+		{
+			scenario: "version constant",
+			in:       `const Version = "1.4.2"`,
+			want:     `const Version = "1.4.3"`,
+		},
+		{
+			scenario: "badge with a v",
+			in:       "![version](https://img.shields.io/badge/version-v1.4.2-blue)",
+			want:     "![version](https://img.shields.io/badge/version-v1.4.3-blue)",
+		},
+		{
+			scenario: "end of a sentence",
+			in:       "Install v1.4.2.\nOr 1.4.2.",
+			want:     "Install v1.4.3.\nOr 1.4.3.",
+		},
+		{
+			scenario: "longer versions",
+			in:       "11.4.2 1.4.2.1 1.4.20 dev1.4.2",
+			want:     "11.4.2 1.4.2.1 1.4.20 dev1.4.2",
+		},
+		{
+			scenario: "several",
+			in:       "1.4.2,1.4.2",
+			want:     "1.4.3,1.4.3",
+		},
+		{
+			scenario: "not mentioned",
+			in:       "v1.4.1",
+			want:     "v1.4.1",
+		},
+	}
+
+	for _, tc := range tcs {
+		t.Run(tc.scenario, func(t *testing.T) {
+			if got := refreshVersion(tc.in, "1.4.2", "1.4.3"); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func Test_bareVersion(t *testing.T) {
+	for tag, want := range map[string]string{
+		"v1.4.2":     "1.4.2",
+		"1.4.2":      "1.4.2",
+		"api/v1.4.2": "1.4.2",
+	} {
+		if got := bareVersion(tag, "api/"); got != want {
+			t.Errorf("bareVersion(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
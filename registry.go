@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// manifestTypes are the manifest media types accepted when retagging, so the
+// registry hands back the manifest as pushed instead of converting it.
+var manifestTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// registry is a minimal Docker Registry HTTP API v2 client, only able to
+// copy a tag within a repository.
+type registry struct {
+	host     string // e.g. ghcr.io
+	name     string // e.g. org/app
+	username string
+	password string
+
+	hc    *http.Client
+	token string // bearer token, once we have one
+}
+
+// newRegistry returns a client for image, e.g. ghcr.io/org/app.
+func newRegistry(image, username, password string) (*registry, error) {
+	parts := strings.SplitN(image, "/", 2)
+	if len(parts) != 2 || !strings.ContainsAny(parts[0], ".:") {
+		return nil, fmt.Errorf("image %q must include the registry host, e.g. ghcr.io/org/app", image)
+	}
+
+	return &registry{
+		host:     parts[0],
+		name:     parts[1],
+		username: username,
+		password: password,
+		hc:       &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+var invalidTagChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// dockerTag turns a git tag into a valid image tag: no slashes, and only
+// letters, digits, dots, dashes and underscores.
+func dockerTag(tag string) string {
+	return invalidTagChars.ReplaceAllString(tag, "-")
+}
+
+// imageData is what IMAGE_SOURCE_TAG is rendered with.
+type imageData struct {
+	SHA      string
+	ShortSHA string
+	Version  string
+}
+
+// shortSHA abbreviates sha to 7 characters, like git does.
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// mirrorImage tags the image built for the merge commit with the released
+// version, and as latest when configured to, so image tags stay in lockstep
+// with git tags.
+func mirrorImage(cfg *config, d decision) error {
+	var src strings.Builder
+	err := cfg.imageSourceTmpl.Execute(&src, imageData{SHA: d.ref, ShortSHA: shortSHA(d.ref), Version: d.version})
+	if err != nil {
+		return fmt.Errorf("could not render IMAGE_SOURCE_TAG: %v", err)
+	}
+
+	r, err := newRegistry(cfg.image, os.Getenv("REGISTRY_USERNAME"), os.Getenv("REGISTRY_PASSWORD"))
+	if err != nil {
+		return err
+	}
+
+	tags := []string{dockerTag(d.version)}
+	if cfg.imageLatest && !strings.Contains(d.version, "-") {
+		tags = append(tags, "latest")
+	}
+
+	for _, t := range tags {
+		fmt.Printf("Tagging image %s:%s as %s\n", cfg.image, src.String(), t)
+		if err := r.retag(src.String(), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retag points the image tag to at the manifest of the tag from.
+func (r *registry) retag(from, to string) error {
+	req, err := http.NewRequest("GET", r.manifestURL(from), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", strings.Join(manifestTypes, ", "))
+
+	resp, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	manifest, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get manifest of %s:%s: %s", r.name, from, resp.Status)
+	}
+
+	req, err = http.NewRequest("PUT", r.manifestURL(to), bytes.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", resp.Header.Get("Content-Type"))
+
+	put, err := r.do(req)
+	if err != nil {
+		return err
+	}
+	defer put.Body.Close()
+
+	if put.StatusCode != http.StatusCreated && put.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(put.Body)
+		return fmt.Errorf("could not push %s:%s: %s %s", r.name, to, put.Status, b)
+	}
+	return nil
+}
+
+func (r *registry) manifestURL(tag string) string {
+	return fmt.Sprintf("https://%s/v2/%s/manifests/%s", r.host, r.name, tag)
+}
+
+// do sends req, going through the registry's token authentication when
+// challenged for it.
+func (r *registry) do(req *http.Request) (*http.Response, error) {
+	// keep the body around in case we have to send the request again
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	r.authorize(req)
+	resp, err := r.hc.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return nil, fmt.Errorf("registry %s refused our credentials", r.host)
+	}
+	if err := r.fetchToken(challenge); err != nil {
+		return nil, err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	r.authorize(req)
+	return r.hc.Do(req)
+}
+
+func (r *registry) authorize(req *http.Request) {
+	switch {
+	case r.token != "":
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	case r.username != "":
+		req.SetBasicAuth(r.username, r.password)
+	}
+}
+
+var challengeRE = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// fetchToken gets a push and pull token from the auth server named in the
+// registry's Bearer challenge.
+func (r *registry) fetchToken(challenge string) error {
+	params := map[string]string{}
+	for _, m := range challengeRE.FindAllStringSubmatch(challenge, -1) {
+		params[m[1]] = m[2]
+	}
+	if params["realm"] == "" {
+		return fmt.Errorf("registry %s sent a challenge without realm: %s", r.host, challenge)
+	}
+
+	q := url.Values{}
+	q.Set("scope", fmt.Sprintf("repository:%s:pull,push", r.name))
+	if s := params["service"]; s != "" {
+		q.Set("service", s)
+	}
+
+	req, err := http.NewRequest("GET", params["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not get a token for %s: %s", r.host, resp.Status)
+	}
+
+	var tr struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return fmt.Errorf("could not decode token for %s: %v", r.host, err)
+	}
+
+	r.token = tr.Token
+	if r.token == "" {
+		r.token = tr.AccessToken
+	}
+	if r.token == "" {
+		return fmt.Errorf("no token for %s", r.host)
+	}
+	return nil
+}
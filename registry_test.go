@@ -0,0 +1,36 @@
+package main
+
+import "testing"
+
+func Test_dockerTag(t *testing.T) {
+	tests := map[string]string{
+		"v1.2.4":        "v1.2.4",
+		"api/v1.2.4":    "api-v1.2.4",
+		"v1.2.4+build5": "v1.2.4-build5",
+	}
+
+	for tag, want := range tests {
+		t.Run(tag, func(t *testing.T) {
+			if got := dockerTag(tag); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func Test_shortSHA(t *testing.T) {
+	tests := []struct {
+		sha, want string
+	}{
+		{"3f2a9c1d8e7b6a5f4c3d2e1f0a9b8c7d6e5f4a3b", "3f2a9c1"},
+		{"3f2a9c1", "3f2a9c1"},
+		{"merge", "merge"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := shortSHA(tt.sha); got != tt.want {
+			t.Errorf("shortSHA(%q): got %q, want %q", tt.sha, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// reasons for not tagging a merge commit
+const (
+	skipNoChanges = "no changes matching pattern"
+	skipConflict  = "tag conflict"
+	skipGated     = "blocked by release gates"
+	skipNone      = "#none in the PR description"
+)
+
+// skipReasons are the skip_reason outputs for the reasons not to tag.
+var skipReasons = map[string]string{
+	skipNoChanges:    "no_matching_files",
+	skipConflict:     "tag_conflict",
+	skipGated:        "gated",
+	skipNone:         "none_directive",
+	skipAggregate:    "aggregate",
+	skipNoPrerelease: "no_prerelease",
+	skipSoaking:      "soaking",
+}
+
+// decision is what to do about a merge commit.
+type decision struct {
+	ref      string // the merge commit
+	previous string // the previous version, if known
+	version  string // the version to tag ref with
+	action   tagAction
+	reason   string // why this version, e.g. the kind of bump
+	skip     string // why ref won't be tagged, if it won't
+
+	prs []*github.PullRequest // what's released, when it's more than the merged PR
+}
+
+// decide works out whether and how to tag ref, the merge commit of pr, given
+// the last version and its tag name. Changes are looked up between base and ref,
+// base usually being the previous tag. An empty previous and base mean this
+// is the first release, which has nothing to compare against. It doesn't
+// change anything.
+func (c *client) decide(ctx context.Context, cfg *config, pr *github.PullRequest, last *version.Version, previous, base, ref string) decision {
+	d := decision{ref: ref, previous: previous}
+	if bodyDirective(pr.GetBody()) == "none" {
+		d.skip = skipNone
+		return d
+	}
+	if previous == "" {
+		return c.decideNext(ctx, cfg, pr, d, last, changes{})
+	}
+
+	// A previous run may have created the tag and died before finishing, in
+	// which case the merge commit is already the latest version. Pick up
+	// where it left off instead of looking for changes since... itself.
+	prevSHA, err := c.getTagSHA(ctx, previous)
+	if err != nil {
+		fatalf("could not look up tag %s: %v", previous, err)
+	}
+	if prevSHA == ref {
+		tracef("Merge commit %s is already tagged as %s, completing previous run", ref, previous)
+		d.previous, d.version, d.action = "", previous, tagExists
+		return d
+	}
+
+	ok, ch := c.shouldTag(ctx, cfg, base, ref)
+	if !ok {
+		d.skip = skipNoChanges
+		return d
+	}
+
+	return c.decideNext(ctx, cfg, pr, d, last, ch)
+}
+
+// decideNext picks the version after last for d, the decision about pr, and
+// resolves conflicts with existing tags. ch is what the changes say about
+// the bump.
+func (c *client) decideNext(ctx context.Context, cfg *config, pr *github.PullRequest, d decision, last *version.Version, ch changes) decision {
+	var next, reason string
+	if want := versionLabel(pr.Labels); want != "" {
+		v, err := overrideVersion(want, cfg.prefix, last)
+		if err != nil {
+			fatalf("invalid release label on PR #%d: %v", pr.GetNumber(), err)
+		}
+		next, reason = v, fmt.Sprintf("%q label", versionLabelPrefix+" "+want)
+	} else if d.previous == "" && cfg.initialVersion != nil {
+		next, reason = initialTag(cfg), "INITIAL_VERSION"
+	} else {
+		b, r := c.bumpLevel(ctx, cfg, pr, d.ref, ch)
+		next, reason = bumpVersion(cfg, last, b), r
+		if floor, ok := floorTag(cfg, last, next); ok {
+			next, reason = floor, reason+", raised to MIN_VERSION"
+		}
+	}
+	d.reason = reason
+	if d.previous == "" {
+		tracef("First release is %s: %s", next, reason)
+	} else {
+		tracef("Bumping %s to %s: %s", d.previous, next, reason)
+	}
+
+	d.version, d.action = c.resolveConflict(ctx, next, d.ref, cfg.prefix, cfg.conflict)
+	if d.action == tagSkip {
+		d.skip = skipConflict
+	}
+	return d
+}
+
+// versionLabelPrefix starts the labels forcing the next version, like
+// "release: v2.0.0".
+const versionLabelPrefix = "release:"
+
+// versionLabel returns the version a "release: v2.0.0" label asks for, ""
+// if there's none.
+func versionLabel(labels []*github.Label) string {
+	for _, l := range labels {
+		name := l.GetName()
+		if len(name) > len(versionLabelPrefix) && strings.EqualFold(name[:len(versionLabelPrefix)], versionLabelPrefix) {
+			return strings.TrimSpace(name[len(versionLabelPrefix):])
+		}
+	}
+	return ""
+}
+
+// overrideVersion returns the tag for the version want, asked for by a
+// label, under prefix. It has to be greater than last, releases don't go
+// back.
+func overrideVersion(want, prefix string, last *version.Version) (string, error) {
+	want = strings.TrimPrefix(want, prefix)
+	v, err := version.NewSemver(want)
+	if err != nil {
+		return "", fmt.Errorf("%q isn't a version", want)
+	}
+	if !v.GreaterThan(last) {
+		return "", fmt.Errorf("%s isn't greater than the last version %s", want, last.Original())
+	}
+	return prefix + want, nil
+}
+
+// apply carries out the decision for the merged pull request pr: it creates
+// the tag and reports the release, or why there wasn't one.
+func (c *client) apply(ctx context.Context, cfg *config, d decision, pr *github.PullRequest) {
+	if d.skip != "" {
+		tracef("Skipped: %s. This code won't be tagged.", d.skip)
+		if err := setOutput("skip_reason", skipReasons[d.skip]); err != nil {
+			log.Printf("Could not set skip_reason output: %v", err)
+		}
+		if cfg.commitStatus {
+			if err := c.setStatus(ctx, d.ref, "Not released: "+d.skip, ""); err != nil {
+				log.Printf("Could not set commit status: %v", err)
+			}
+		}
+		if cfg.checkRun {
+			if err := c.createCheckRun(ctx, d.ref, "neutral", "Not released: "+d.skip, ""); err != nil {
+				log.Printf("Could not create check run: %v", err)
+			}
+		}
+		return
+	}
+
+	// the previous release's tag tells how long it's been and what it noted
+	var prev tagInfo
+	if d.previous != "" {
+		info, err := c.getTagInfo(ctx, d.previous)
+		if err != nil {
+			log.Printf("Could not look up the previous release %s: %v", d.previous, err)
+		}
+		prev = info
+	}
+
+	if d.action == tagExists {
+		tracef("Tag %s already exists on %s, not creating it again", d.version, d.ref)
+	} else {
+		if cfg.gorelease != "" {
+			c.checkGorelease(ctx, cfg, d, pr)
+		}
+
+		tagRef, err := c.tagRef(ctx, cfg, d, tagMessage(d.version, releaseMeta(d, prev, cfg.prerelease)))
+		if err == nil {
+			t := c.tags()
+			if d.action == tagMove {
+				_, _, err = t.c.Git.UpdateRef(ctx, t.owner, t.repo, tagRef, true)
+			} else {
+				created := func() bool { return c.tagCreated(ctx, d) }
+				_, _, err = t.c.Git.CreateRef(withRetryCheck(ctx, created), t.owner, t.repo, tagRef)
+				if err != nil && created() {
+					err = nil
+				}
+			}
+		}
+		if err != nil {
+			fatalf("could not create tag for ref %s: %v", d.ref, err)
+		}
+	}
+
+	tracef("Tagged version %s", d.version)
+
+	if cfg.releaseBranches {
+		if err := c.cutBranch(ctx, cfg, d); err != nil {
+			fatalf("could not create release branch: %v", err)
+		}
+	}
+
+	if cfg.majorTag {
+		if err := c.moveMajorTag(ctx, cfg, d); err != nil {
+			fatalf("could not move major version tag: %v", err)
+		}
+	}
+	if len(cfg.pinFiles) > 0 {
+		if err := c.proposePins(ctx, cfg, d, pr.GetBase().GetRef()); err != nil {
+			fatalf("could not propose pins: %v", err)
+		}
+	}
+	if len(cfg.refreshFiles) > 0 {
+		if err := c.refreshFiles(ctx, cfg, d, pr.GetBase().GetRef()); err != nil {
+			fatalf("could not refresh version references: %v", err)
+		}
+	}
+	if d.action != tagExists {
+		if w := tokenWarning(cfg.tokenKind, cfg.releaseEvent); w != "" {
+			fmt.Println("Warning:", w)
+		}
+		if cfg.releaseEvent != "" {
+			// the tag is there already, failing now wouldn't undo it
+			if err := c.sendReleaseEvent(ctx, cfg, d); err != nil {
+				fmt.Printf("Warning: could not send the %s event: %v\n", cfg.releaseEvent, err)
+			}
+		}
+	}
+
+	// looked up early to be part of the check run's trace
+	var owners []string
+	if cfg.codeowners {
+		co, err := c.codeowners(ctx, d.ref)
+		if err != nil {
+			fatalf("could not read CODEOWNERS: %v", err)
+		}
+		owners = ownersOf(co, cfg.componentPath)
+		tracef("Owners of %s: %s", cfg.componentPath, strings.Join(owners, " "))
+	}
+
+	if cfg.image != "" {
+		if err := mirrorImage(cfg, d); err != nil {
+			fatalf("could not tag image: %v", err)
+		}
+	}
+
+	if cfg.provenance {
+		if err := c.attestDecision(ctx, d, pr.GetNumber()); err != nil {
+			fatalf("could not attest release: %v", err)
+		}
+	}
+
+	if cfg.environment != "" {
+		if _, err := c.deploy(ctx, cfg.environment, d.version, cfg.prefix); err != nil {
+			fatalf("could not create deployment: %v", err)
+		}
+	}
+
+	if cfg.commitStatus {
+		if err := c.setStatus(ctx, d.ref, "Released "+d.version, c.tags().url+"/releases/tag/"+d.version); err != nil {
+			fatalf("could not set commit status: %v", err)
+		}
+	}
+
+	if cfg.checkRun {
+		if err := c.createCheckRun(ctx, d.ref, "success", "Released "+d.version, ""); err != nil {
+			fatalf("could not create check run: %v", err)
+		}
+	}
+
+	if cfg.manifestBranch != "" {
+		if err := c.updateManifest(ctx, cfg.manifestBranch, cfg.manifestPath, cfg.prefix, d.version); err != nil {
+			fatalf("could not update version manifest: %v", err)
+		}
+	}
+
+	if cfg.badgeBranch != "" {
+		if err := c.updateBadge(ctx, cfg.badgeBranch, cfg.badgePath, cfg.badgeLabel, d.version); err != nil {
+			fatalf("could not update version badge: %v", err)
+		}
+	}
+
+	released := releasedPRs(d, pr)
+
+	// Everyone hears about the release: the released PRs, and pr too when
+	// it only triggered the release, like a release PR does.
+	notified := released
+	if !includesPR(released, pr.GetNumber()) {
+		notified = append([]*github.PullRequest{pr}, released...)
+	}
+
+	if cfg.releaseLabel {
+		for _, p := range notified {
+			if err := c.labelRelease(ctx, p.GetNumber(), d.version); err != nil {
+				fatalf("could not label pull request #%d: %v", p.GetNumber(), err)
+			}
+		}
+	}
+
+	cd := newCommentData(d.version, d.previous, c.tags().url, pr.GetNumber())
+	cd.PreviousMeta = prev.meta
+	if !prev.date.IsZero() {
+		cd.PreviousDate, cd.SinceLast = prev.date, sinceRelease(time.Since(prev.date))
+	}
+	commentFor := func(number int) commentData {
+		pc := cd
+		pc.Number = number
+		return pc
+	}
+
+	if cfg.issueComments && cfg.digest == nil {
+		for _, p := range released {
+			body, err := renderComment(cfg.issueCommentTmpl, commentFor(p.GetNumber()))
+			if err != nil {
+				fatalf("could not render ISSUE_COMMENT_TEMPLATE: %v", err)
+			}
+			body = signed(body, cfg.signature)
+			for _, n := range linkedIssues(p.GetBody()) {
+				fmt.Printf("Commenting on issue #%d\n", n)
+				if err := c.upsertComment(ctx, n, cfg.prefix, body); err != nil {
+					fatalf("could not comment on issue #%d: %v", n, err)
+				}
+			}
+		}
+	}
+
+	notes, frags := c.notesFor(ctx, cfg, d, released)
+	if err := setChangelogOutput(notes); err != nil {
+		fatalf("could not set changelog output: %v", err)
+	}
+
+	info := releaseInfo{Version: d.version, Previous: d.previous, Prefix: cfg.prefix, URL: c.tags().url + "/releases/tag/" + d.version, CompareURL: cd.CompareURL, Owners: owners}
+	if err := setReleaseOutput(info); err != nil {
+		fatalf("could not set release output: %v", err)
+	}
+
+	if cfg.notesRef != "" {
+		if err := c.addNote(ctx, cfg.notesRef, d.ref, releaseNote(d, pr, notes)); err != nil {
+			fatalf("could not add git note: %v", err)
+		}
+	}
+
+	if len(frags) > 0 {
+		msg := fmt.Sprintf("Remove note fragments released in %s", d.version)
+		if err := c.removeFragments(ctx, pr.GetBase().GetRef(), msg, frags); err != nil {
+			fatalf("could not remove note fragments: %v", err)
+		}
+	}
+
+	if cfg.email != nil && cfg.digest == nil {
+		fmt.Println("Emailing", strings.Join(cfg.email.to, ", "))
+		if err := cfg.email.announce(emailData{cd, notes}); err != nil {
+			fatalf("could not send release email: %v", err)
+		}
+	}
+
+	if cfg.goreleaser || cfg.goreleaserRun {
+		if err := goreleaser(d, cfg.goreleaserRun, cfg.goreleaserArgs); err != nil {
+			fatalf("goreleaser failed: %v", err)
+		}
+	}
+
+	if cfg.digest != nil {
+		// announced with the rest of the run's releases, by sendDigest
+		cfg.digest.add(cd, notified, notes)
+		return
+	}
+
+	for _, p := range notified {
+		if cfg.quietLabel != "" && hasLabel(p.Labels, cfg.quietLabel) {
+			fmt.Printf("PR #%d is labeled %q, not commenting\n", p.GetNumber(), cfg.quietLabel)
+			continue
+		}
+
+		body, err := renderComment(cfg.commentTmpl, commentFor(p.GetNumber()))
+		if err != nil {
+			fatalf("could not render COMMENT_TEMPLATE: %v", err)
+		}
+		body = signed(body+mentions(cfg.mention), cfg.signature)
+		if err := c.upsertComment(ctx, p.GetNumber(), cfg.prefix, body); err != nil {
+			fatalf("could not comment on #%d: %v", p.GetNumber(), err)
+		}
+	}
+}
+
+// includesPR reports whether prs holds the pull request numbered number.
+func includesPR(prs []*github.PullRequest, number int) bool {
+	for _, p := range prs {
+		if p.GetNumber() == number {
+			return true
+		}
+	}
+	return false
+}
+
+// tagRef builds the reference for d's tag. With a tagger configured it
+// points at a new annotated tag object with message, so the release is
+// attributed to that identity; otherwise it's a lightweight tag on the merge
+// commit.
+func (c *client) tagRef(ctx context.Context, cfg *config, d decision, message string) (*github.Reference, error) {
+	if c.release != nil {
+		return c.release.tagRef(ctx, cfg, d, message)
+	}
+	obj := &github.GitObject{SHA: github.String(d.ref), Type: github.String("commit")}
+
+	if cfg.taggerName != "" {
+		now := time.Now()
+		tag, _, err := c.c.Git.CreateTag(ctx, c.owner, c.repo, &github.Tag{
+			Tag:     github.String(d.version),
+			Message: github.String(message),
+			Object:  obj,
+			Tagger: &github.CommitAuthor{
+				Name:  github.String(cfg.taggerName),
+				Email: github.String(cfg.taggerEmail),
+				Date:  &now,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("could not create tag object: %v", err)
+		}
+		obj = &github.GitObject{SHA: tag.SHA, Type: github.String("tag")}
+	}
+
+	return &github.Reference{
+		Ref:    github.String("refs/tags/" + d.version),
+		Object: obj,
+	}, nil
+}
+
+// tagCreated reports whether d's tag exists on its commit after all, when a
+// retried request created it but the response got lost.
+func (c *client) tagCreated(ctx context.Context, d decision) bool {
+	sha, err := c.getTagSHA(ctx, d.version)
+	if err != nil || sha != d.ref {
+		return false
+	}
+	tracef("Tag %s was created on %s by an earlier attempt", d.version, d.ref)
+	return true
+}
+
+// releasedPRs returns the pull requests released by d, triggered by pr.
+func releasedPRs(d decision, pr *github.PullRequest) []*github.PullRequest {
+	if len(d.prs) == 0 {
+		return []*github.PullRequest{pr}
+	}
+	return d.prs
+}
+
+// notesFor renders the release notes of d, covering released, along with
+// the note fragments they include.
+func (c *client) notesFor(ctx context.Context, cfg *config, d decision, released []*github.PullRequest) (string, []fragment) {
+	notes := releaseNotes(d.version, d.previous, c.tags().url, released, cfg.noteCategories)
+
+	var frags []fragment
+	if cfg.fragmentsDir != "" {
+		var err error
+		if frags, err = c.readFragments(ctx, cfg.fragmentsDir, d.ref); err != nil {
+			fatalf("could not read note fragments: %v", err)
+		}
+		notes += fragmentNotes(frags)
+	}
+	return notes, frags
+}
+
+// preview reports what apply would do with d without changing anything,
+// for DRY_RUN: the tag and the release notes, as a check run too with
+// CHECK_RUN.
+func (c *client) preview(ctx context.Context, cfg *config, d decision, pr *github.PullRequest) {
+	if d.skip != "" {
+		tracef("Would skip: %s", d.skip)
+		if cfg.checkRun {
+			if err := c.createCheckRun(ctx, d.ref, "neutral", "Dry run, would skip: "+d.skip, ""); err != nil {
+				log.Printf("Could not create check run: %v", err)
+			}
+		}
+		return
+	}
+
+	if reason := c.gate(ctx, cfg, d); reason != "" {
+		tracef("Would be blocked: %s", reason)
+	}
+	tracef("Would tag %s as %s", d.ref, d.version)
+
+	notes, _ := c.notesFor(ctx, cfg, d, releasedPRs(d, pr))
+	fmt.Printf("\nRelease notes:\n\n%s\n", notes)
+	if cfg.checkRun {
+		if err := c.createCheckRun(ctx, d.ref, "neutral", "Dry run, would release "+d.version, notes); err != nil {
+			log.Printf("Could not create check run: %v", err)
+		}
+	}
+}
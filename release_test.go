@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+func Test_versionLabel(t *testing.T) {
+	labels := func(names ...string) []*github.Label {
+		var ls []*github.Label
+		for _, n := range names {
+			ls = append(ls, &github.Label{Name: github.String(n)})
+		}
+		return ls
+	}
+
+	tests := []struct {
+		labels []*github.Label
+		want   string
+	}{
+		{labels("bug", "release: v2.0.0"), "v2.0.0"},
+		{labels("Release:v1.5.0"), "v1.5.0"},
+		{labels("release", "released: v1.4.0"), ""},
+		{labels("release:"), ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := versionLabel(tt.labels); got != tt.want {
+			t.Errorf("got %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func Test_overrideVersion(t *testing.T) {
+	last := version.Must(version.NewSemver("v1.4.0"))
+
+	tests := []struct {
+		want string
+		tag  string
+		ok   bool
+	}{
+		{"v2.0.0", "api/v2.0.0", true},
+		{"api/v1.4.1", "api/v1.4.1", true},
+		{"v1.4.0", "", false},
+		{"v1.3.9", "", false},
+		{"two", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			tag, err := overrideVersion(tt.want, "api/", last)
+			if (err == nil) != tt.ok || tag != tt.tag {
+				t.Errorf("got %q, %v, want %q, ok %v", tag, err, tt.tag, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_decideResume(t *testing.T) {
+	// a previous run tagged the merge commit and died before commenting
+	c, done := newTestClient(t, map[string]string{"v1.2.0": "merge"}, nil)
+	defer done()
+
+	last := version.Must(version.NewSemver("v1.2.0"))
+	d := c.decide(context.Background(), &config{}, &github.PullRequest{}, last, "v1.2.0", "v1.2.0", "merge")
+	if d.skip != "" || d.version != "v1.2.0" || d.action != tagExists {
+		t.Errorf("got %+v, want v1.2.0 to be completed", d)
+	}
+	if d.previous != "" {
+		t.Errorf("got previous %q, want none, it's the release itself", d.previous)
+	}
+}
+
+func Test_tagRef(t *testing.T) {
+	d := decision{version: "v1.2.3", ref: "merge"}
+
+	t.Run("lightweight", func(t *testing.T) {
+		c, done := newTestClient(t, nil, nil)
+		defer done()
+
+		ref, err := c.tagRef(context.Background(), &config{}, d, "Release v1.2.3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref.GetRef() != "refs/tags/v1.2.3" || ref.GetObject().GetType() != "commit" || ref.GetObject().GetSHA() != "merge" {
+			t.Errorf("got %s on %s %s", ref.GetRef(), ref.GetObject().GetType(), ref.GetObject().GetSHA())
+		}
+	})
+
+	t.Run("annotated", func(t *testing.T) {
+		var got struct {
+			Tag    string
+			Object string
+			Type   string
+			Tagger github.CommitAuthor
+		}
+		c, done := newTestClient(t, nil, map[string]interface{}{
+			"POST /repos/o/r/git/tags": func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&got)
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte(`{"sha": "tagobj"}`))
+			},
+		})
+		defer done()
+
+		cfg := &config{taggerName: "Release Bot", taggerEmail: "bot@example.com"}
+		ref, err := c.tagRef(context.Background(), cfg, d, "Release v1.2.3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ref.GetObject().GetType() != "tag" || ref.GetObject().GetSHA() != "tagobj" {
+			t.Errorf("got ref to %s %s, want the tag object", ref.GetObject().GetType(), ref.GetObject().GetSHA())
+		}
+		if got.Tagger.GetName() != "Release Bot" || got.Tagger.GetEmail() != "bot@example.com" {
+			t.Errorf("got tagger %s <%s>", got.Tagger.GetName(), got.Tagger.GetEmail())
+		}
+		if got.Tag != "v1.2.3" || got.Object != "merge" || got.Type != "commit" {
+			t.Errorf("got tag %s on %s %s", got.Tag, got.Type, got.Object)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// In release PR mode merges aren't tagged right away. Instead they're
+// gathered in a pull request proposing the next release, which updates the
+// changelog, and merging that pull request tags the release.
+const (
+	releasePRMarker = "<!-- autotagger-release -->"
+	releasePRTitle  = "Release "
+)
+
+var releasePREntryRE = regexp.MustCompile(`(?m)^<!-- autotagger-pr #(\d+) -->$`)
+
+// parseReleasePR returns the pull request numbers included in the release
+// PR body.
+func parseReleasePR(body string) []int {
+	var prs []int
+	for _, m := range releasePREntryRE.FindAllStringSubmatch(body, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil {
+			prs = append(prs, n)
+		}
+	}
+	return prs
+}
+
+// renderReleasePR renders the release PR body: the release notes, and the
+// included pull requests for later runs to pick up.
+func renderReleasePR(prs []int, notes string) string {
+	var b strings.Builder
+	b.WriteString(releasePRMarker + "\n")
+	b.WriteString("Merging this pull request tags the release below.\n\n")
+	b.WriteString(notes)
+	b.WriteString("\n")
+	for _, n := range prs {
+		fmt.Fprintf(&b, "<!-- autotagger-pr #%d -->\n", n)
+	}
+	return b.String()
+}
+
+// releasePRVersion returns the version a release PR title proposes, or ""
+// if it isn't a release PR title.
+func releasePRVersion(title, prefix string) string {
+	tag := strings.TrimSpace(strings.TrimPrefix(title, releasePRTitle))
+	if tag == title || !strings.HasPrefix(tag, prefix) {
+		return ""
+	}
+	if _, err := version.NewSemver(strings.TrimPrefix(tag, prefix)); err != nil {
+		return ""
+	}
+	return tag
+}
+
+// isReleasePR reports whether pr is the release PR.
+func isReleasePR(cfg *config, pr *github.PullRequest) bool {
+	return pr.GetHead().GetRef() == cfg.releasePRBranch && strings.Contains(pr.GetBody(), releasePRMarker)
+}
+
+// findReleasePR returns the open release PR into base, or nil if there's
+// none.
+func (c *client) findReleasePR(ctx context.Context, cfg *config, base string) (*github.PullRequest, error) {
+	prs, _, err := c.c.PullRequests.List(ctx, c.owner, c.repo, &github.PullRequestListOptions{
+		State: "open",
+		Head:  c.owner + ":" + cfg.releasePRBranch,
+		Base:  base,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range prs {
+		if strings.Contains(pr.GetBody(), releasePRMarker) {
+			return pr, nil
+		}
+	}
+	return nil, nil
+}
+
+// decideReleasePR decides the release for the merged release PR pr: the
+// version it proposed, on its merge commit ref.
+func (c *client) decideReleasePR(ctx context.Context, cfg *config, previous, ref string, pr *github.PullRequest) decision {
+	d := decision{ref: ref, previous: previous, reason: fmt.Sprintf("proposed in release PR #%d", pr.GetNumber())}
+
+	next := releasePRVersion(pr.GetTitle(), cfg.prefix)
+	if next == "" {
+		fatalf("release PR #%d's title %q doesn't name a version", pr.GetNumber(), pr.GetTitle())
+	}
+	tracef("Release PR #%d merged, releasing %s", pr.GetNumber(), next)
+
+	for _, n := range parseReleasePR(pr.GetBody()) {
+		included, _, err := c.c.PullRequests.Get(ctx, c.owner, c.repo, n)
+		if err != nil {
+			fatalf("could not get PR #%d: %v", n, err)
+		}
+		d.prs = append(d.prs, included)
+	}
+
+	d.version, d.action = c.resolveConflict(ctx, next, ref, cfg.prefix, cfg.conflict)
+	if d.action == tagSkip {
+		d.skip = skipConflict
+	}
+	return d
+}
+
+// proposeRelease adds the merged pull request pr to the release PR,
+// opening one if needed. The release branch is cut again from ref with the
+// changelog, and version file if any, updated for the proposed version:
+// the bigger of the already proposed one and d.version.
+func (c *client) proposeRelease(ctx context.Context, cfg *config, d decision, pr *github.PullRequest) error {
+	base := pr.GetBase().GetRef()
+	open, err := c.findReleasePR(ctx, cfg, base)
+	if err != nil {
+		return fmt.Errorf("could not find release PR: %v", err)
+	}
+
+	next := d.version
+	numbers := []int{pr.GetNumber()}
+	if open != nil {
+		if proposed := releasePRVersion(open.GetTitle(), cfg.prefix); proposed != "" && versionLess(next, proposed, cfg.prefix) {
+			next = proposed
+		}
+		numbers = nil
+		for _, n := range parseReleasePR(open.GetBody()) {
+			if n != pr.GetNumber() {
+				numbers = append(numbers, n)
+			}
+		}
+		numbers = append(numbers, pr.GetNumber())
+	}
+
+	var prs []*github.PullRequest
+	for _, n := range numbers {
+		if n == pr.GetNumber() {
+			prs = append(prs, pr)
+			continue
+		}
+		included, _, err := c.c.PullRequests.Get(ctx, c.owner, c.repo, n)
+		if err != nil {
+			return fmt.Errorf("could not get PR #%d: %v", n, err)
+		}
+		prs = append(prs, included)
+	}
+	notes := releaseNotes(next, d.previous, c.tags().url, prs, cfg.noteCategories)
+
+	if err := c.resetBranch(ctx, cfg.releasePRBranch, d.ref); err != nil {
+		return fmt.Errorf("could not cut %s: %v", cfg.releasePRBranch, err)
+	}
+	err = c.updateFile(ctx, cfg.releasePRBranch, cfg.changelogPath, "Update changelog for "+next, func(old []byte) ([]byte, error) {
+		return []byte(notes + "\n" + string(old)), nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not update %s: %v", cfg.changelogPath, err)
+	}
+	if cfg.versionFile != "" {
+		err = c.updateFile(ctx, cfg.releasePRBranch, cfg.versionFile, "Set version to "+next, func([]byte) ([]byte, error) {
+			return []byte(strings.TrimPrefix(next, cfg.prefix) + "\n"), nil
+		})
+		if err != nil {
+			return fmt.Errorf("could not update %s: %v", cfg.versionFile, err)
+		}
+	}
+
+	title, body := releasePRTitle+next, renderReleasePR(numbers, notes)
+	if open == nil {
+		created, _, err := c.c.PullRequests.Create(ctx, c.owner, c.repo, &github.NewPullRequest{
+			Title: github.String(title),
+			Head:  github.String(cfg.releasePRBranch),
+			Base:  github.String(base),
+			Body:  github.String(body),
+		})
+		if err != nil {
+			return fmt.Errorf("could not open release PR: %v", err)
+		}
+		fmt.Printf("Opened release PR #%d for %s\n", created.GetNumber(), next)
+		return nil
+	}
+
+	_, _, err = c.c.PullRequests.Edit(ctx, c.owner, c.repo, open.GetNumber(), &github.PullRequest{
+		Title: github.String(title),
+		Body:  github.String(body),
+	})
+	if err != nil {
+		return fmt.Errorf("could not update release PR #%d: %v", open.GetNumber(), err)
+	}
+	fmt.Printf("Updated release PR #%d for %s\n", open.GetNumber(), next)
+	return nil
+}
+
+// resetBranch points branch at sha, creating it if needed.
+func (c *client) resetBranch(ctx context.Context, branch, sha string) error {
+	ref := &github.Reference{
+		Ref:    github.String("refs/heads/" + branch),
+		Object: &github.GitObject{SHA: github.String(sha)},
+	}
+
+	_, resp, err := c.c.Git.GetRef(ctx, c.owner, c.repo, "heads/"+branch)
+	switch {
+	case resp != nil && resp.StatusCode == http.StatusNotFound:
+		_, _, err = c.c.Git.CreateRef(ctx, c.owner, c.repo, ref)
+	case err != nil:
+	default:
+		_, _, err = c.c.Git.UpdateRef(ctx, c.owner, c.repo, ref, true)
+	}
+	return err
+}
+
+// versionLess reports whether tag a is a lower version than tag b.
+func versionLess(a, b, prefix string) bool {
+	va, err := version.NewSemver(strings.TrimPrefix(a, prefix))
+	if err != nil {
+		return true
+	}
+	vb, err := version.NewSemver(strings.TrimPrefix(b, prefix))
+	if err != nil {
+		return false
+	}
+	return va.LessThan(vb)
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_parseReleasePR(t *testing.T) {
+	prs := []int{12, 13}
+	body := renderReleasePR(prs, "## v1.3.0\n\n- Add the thing (#12)\n- Fix the thing (#13)\n")
+
+	if got := parseReleasePR(body); !reflect.DeepEqual(got, prs) {
+		t.Errorf("got %v, want %v", got, prs)
+	}
+}
+
+func Test_releasePRVersion(t *testing.T) {
+	tests := []struct {
+		title  string
+		prefix string
+		want   string
+	}{
+		{title: "Release v1.3.0", want: "v1.3.0"},
+		{title: "Release api/v1.3.0", prefix: "api/", want: "api/v1.3.0"},
+		{title: "Release v1.3.0", prefix: "api/", want: ""},
+		{title: "Release the thing", want: ""},
+		{title: "v1.3.0", want: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.title, func(t *testing.T) {
+			if got := releasePRVersion(tc.title, tc.prefix); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"time"
+)
+
+// reportContext describes the current run in error reports. It's filled in
+// as soon as the event is known.
+var reportContext = map[string]interface{}{}
+
+// errorReport is the JSON payload posted to ERROR_WEBHOOK.
+type errorReport struct {
+	Message string                 `json:"message"`
+	Context map[string]interface{} `json:"context"`
+	Trace   []string               `json:"trace,omitempty"`
+	Time    time.Time              `json:"time"`
+}
+
+// reportError posts msg to the ERROR_WEBHOOK, if one is configured, so
+// failures across many repositories end up in one place. Failing to report
+// is only logged.
+func reportError(msg string) {
+	url := os.Getenv("ERROR_WEBHOOK")
+	if url == "" {
+		return
+	}
+
+	b, err := json.Marshal(errorReport{
+		Message: msg,
+		Context: reportContext,
+		Trace:   trace,
+		Time:    time.Now().UTC(),
+	})
+	if err != nil {
+		log.Printf("Could not encode error report: %v", err)
+		return
+	}
+
+	hc := &http.Client{Timeout: 10 * time.Second}
+	resp, err := hc.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		log.Printf("Could not report error: %v", err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("Could not report error: webhook returned %s", resp.Status)
+	}
+}
+
+// reportPanic reports a panic before letting it continue. It must be
+// deferred.
+func reportPanic() {
+	if r := recover(); r != nil {
+		reportError(fmt.Sprintf("panic: %v\n%s", r, debug.Stack()))
+		panic(r)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_reportError(t *testing.T) {
+	var got errorReport
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("got content type %q", ct)
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+
+	defer func(ctx map[string]interface{}, tr []string) { reportContext, trace = ctx, tr }(reportContext, trace)
+	reportContext = map[string]interface{}{"repository": "acme/app", "pr": float64(7)}
+	trace = []string{"Bumping v1.2.0 to v1.3.0"}
+
+	os.Setenv("ERROR_WEBHOOK", srv.URL)
+	defer os.Unsetenv("ERROR_WEBHOOK")
+	reportError("could not create tag")
+
+	if got.Message != "could not create tag" {
+		t.Errorf("got message %q", got.Message)
+	}
+	if !reflect.DeepEqual(got.Context, reportContext) || !reflect.DeepEqual(got.Trace, trace) {
+		t.Errorf("got context %v and trace %q", got.Context, got.Trace)
+	}
+	if got.Time.IsZero() {
+		t.Error("report has no time")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// retryPolicy is how mutations are retried when the API has a bad moment.
+type retryPolicy struct {
+	attempts int           // in total, 1 means no retries
+	backoff  time.Duration // before the first retry, doubling after that
+	jitter   float64       // up to this fraction of the backoff is added at random
+}
+
+// newRetryPolicy reads the policy from RETRY_ATTEMPTS, RETRY_BACKOFF and
+// RETRY_JITTER.
+func newRetryPolicy() retryPolicy {
+	p := retryPolicy{attempts: 3, backoff: time.Second, jitter: 0.5}
+
+	if v, ok := os.LookupEnv("RETRY_ATTEMPTS"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			fatalf("invalid RETRY_ATTEMPTS %q: must be a number of 1 or more", v)
+		}
+		p.attempts = n
+	}
+	if v, ok := os.LookupEnv("RETRY_BACKOFF"); ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			fatalf("invalid RETRY_BACKOFF %q: must be a duration like 2s", v)
+		}
+		p.backoff = d
+	}
+	if v, ok := os.LookupEnv("RETRY_JITTER"); ok {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f < 0 || f > 1 {
+			fatalf("invalid RETRY_JITTER %q: must be between 0 and 1", v)
+		}
+		p.jitter = f
+	}
+	return p
+}
+
+// delay returns how long to wait before retry number n, counting from 0,
+// given r, a random number in [0, 1).
+func (p retryPolicy) delay(n int, r float64) time.Duration {
+	d := p.backoff << uint(n)
+	return d + time.Duration(float64(d)*p.jitter*r)
+}
+
+// retryable reports whether a response status is worth retrying: server
+// errors and rate limiting.
+func retryable(status int) bool {
+	return status >= 500 || status == http.StatusTooManyRequests || status == http.StatusForbidden
+}
+
+// idempotent reports whether a request with method can be sent again without
+// doing its work twice. Github's PATCH requests set fields, so sending one
+// again sets them again. A POST can't: one that timed out may have created
+// its comment after all.
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+type retryCheckKey struct{}
+
+// withRetryCheck returns ctx for a POST request that's retried like the
+// others, but only after done reports that the earlier attempt didn't do its
+// work after all, like creating the tag. Once done reports it did, the last
+// failed response is returned, for the caller to check the same way.
+func withRetryCheck(ctx context.Context, done func() bool) context.Context {
+	return context.WithValue(ctx, retryCheckKey{}, done)
+}
+
+// retryTransport retries requests failing with retryable errors. POST
+// requests are only retried with a check, see withRetryCheck.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy retryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, _ := req.Context().Value(retryCheckKey{}).(func() bool)
+	if t.policy.attempts < 2 || !idempotent(req.Method) && done == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	for n := 0; ; n++ {
+		resp, err := t.base.RoundTrip(req)
+		last := n == t.policy.attempts-1 || (req.Body != nil && req.GetBody == nil)
+		switch {
+		case last:
+			return resp, err
+		case err != nil:
+		case !retryable(resp.StatusCode):
+			return resp, nil
+		case resp.StatusCode == http.StatusForbidden && resp.Header.Get("Retry-After") == "":
+			// a plain permission problem, not a rate limit
+			return resp, nil
+		}
+
+		if done != nil && done() {
+			return resp, err
+		}
+
+		wait := t.policy.delay(n, rand.Float64())
+		if resp != nil {
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(s) * time.Second
+			}
+			resp.Body.Close()
+		}
+		reason := fmt.Sprint(err)
+		if err == nil {
+			reason = resp.Status
+		}
+		fmt.Printf("%s %s failed (%s), retrying in %s\n", req.Method, req.URL.Path, reason, wait.Round(time.Millisecond))
+
+		select {
+		case <-time.After(wait):
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_retryPolicy_delay(t *testing.T) {
+	p := retryPolicy{attempts: 4, backoff: time.Second, jitter: 0.5}
+
+	tests := []struct {
+		n    int
+		r    float64
+		want time.Duration
+	}{
+		{n: 0, r: 0, want: time.Second},
+		{n: 1, r: 0, want: 2 * time.Second},
+		{n: 2, r: 0, want: 4 * time.Second},
+		{n: 0, r: 0.5, want: 1250 * time.Millisecond},
+		{n: 1, r: 0.99, want: 2*time.Second + 990*time.Millisecond},
+	}
+
+	for _, tc := range tests {
+		if got := p.delay(tc.n, tc.r); got != tc.want {
+			t.Errorf("delay(%d, %v): got %s, want %s", tc.n, tc.r, got, tc.want)
+		}
+	}
+}
+
+func Test_retryTransport(t *testing.T) {
+	notDone := func() bool { return false }
+	done := func() bool { return true }
+
+	tests := []struct {
+		name   string
+		method string
+		check  func() bool
+		want   int
+	}{
+		{"get", http.MethodGet, nil, 3},
+		{"put", http.MethodPut, nil, 3},
+		{"patch", http.MethodPatch, nil, 3},
+		{"delete", http.MethodDelete, nil, 3},
+		{"post without check", http.MethodPost, nil, 1},
+		{"post not done", http.MethodPost, notDone, 3},
+		{"post done", http.MethodPost, done, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.WriteHeader(http.StatusBadGateway)
+			}))
+			defer srv.Close()
+
+			ctx := context.Background()
+			if tt.check != nil {
+				ctx = withRetryCheck(ctx, tt.check)
+			}
+			rt := &retryTransport{http.DefaultTransport, retryPolicy{attempts: 3}}
+			req, _ := http.NewRequestWithContext(ctx, tt.method, srv.URL, strings.NewReader("{}"))
+			resp, err := rt.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if calls != tt.want {
+				t.Errorf("got %d attempts, want %d", calls, tt.want)
+			}
+		})
+	}
+}
+
+func Test_retryTransport_postRecovers(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	ctx := withRetryCheck(context.Background(), func() bool { return false })
+	rt := &retryTransport{http.DefaultTransport, retryPolicy{attempts: 3}}
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL, strings.NewReader(`{"ref":"refs/tags/v1.0.0"}`))
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("got %s, want 201 Created", resp.Status)
+	}
+	if len(bodies) != 2 || bodies[1] != bodies[0] {
+		t.Errorf("got requests %q, want the same body twice", bodies)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// scenarioFile is a table of release scenarios checking a release policy,
+// for `autotagger test`.
+type scenarioFile struct {
+	Env       map[string]string `json:"env"` // the policy settings, as in the workflow
+	Scenarios []scenario        `json:"scenarios"`
+}
+
+// scenario is a recorded pull request event, and what should be decided
+// about it.
+type scenario struct {
+	Name      string            `json:"name"`
+	Recording string            `json:"recording"` // a RECORD-ed run, relative to the scenario file
+	Env       map[string]string `json:"env"`       // settings on top of the file's
+	Want      scenarioWant      `json:"want"`
+}
+
+// scenarioWant is the expected decision. Only what's set is checked.
+type scenarioWant struct {
+	Version    string `json:"version"`     // the tag
+	SkipReason string `json:"skip_reason"` // as in the skip_reason output
+	Reason     string `json:"reason"`      // part of why this version
+}
+
+// testCmd runs the scenarios in the given file against the release policy,
+// replaying each recorded event offline, and fails when a decision isn't
+// the expected one. Teams can check policy changes with it in CI.
+func testCmd(cfg *config, args []string) {
+	if len(args) != 1 {
+		fmt.Println("Usage: autotagger test <scenarios.json>")
+		os.Exit(exConfig)
+	}
+
+	b, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fatalf("could not read scenarios: %v", err)
+	}
+	var f scenarioFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		fatalf("could not parse scenarios %s: %v", args[0], err)
+	}
+	if len(f.Scenarios) == 0 {
+		fatalf("no scenarios in %s", args[0])
+	}
+
+	failed := 0
+	for _, s := range f.Scenarios {
+		fmt.Printf("\n=== %s\n", s.Name)
+		problems := runScenario(filepath.Dir(args[0]), f.Env, s)
+		if len(problems) == 0 {
+			fmt.Printf("--- PASS: %s\n", s.Name)
+			continue
+		}
+		failed++
+		fmt.Printf("--- FAIL: %s\n", s.Name)
+		for _, p := range problems {
+			fmt.Printf("    %s\n", p)
+		}
+	}
+
+	fmt.Printf("\n%d of %d scenarios passed\n", len(f.Scenarios)-failed, len(f.Scenarios))
+	if failed > 0 {
+		fatalf("%d scenarios failed", failed)
+	}
+}
+
+// runScenario replays s, with the recording relative to dir, under the
+// settings env and the scenario's own. It returns how the decision differs
+// from the expected one.
+func runScenario(dir string, env map[string]string, s scenario) []string {
+	rec, err := readRecording(filepath.Join(dir, s.Recording))
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	usePolicy(env, s.Env)
+	_, d, err := replay(loadConfig(), rec)
+	if err != nil {
+		return []string{err.Error()}
+	}
+	return checkScenario(s.Want, d)
+}
+
+// checkScenario returns how the decision d differs from want.
+func checkScenario(want scenarioWant, d decision) []string {
+	var problems []string
+	skip := skipReasons[d.skip]
+	if d.skip != "" && skip == "" {
+		skip = d.skip
+	}
+
+	if want.SkipReason != "" && skip != want.SkipReason {
+		problems = append(problems, fmt.Sprintf("skip_reason is %q, want %q", skip, want.SkipReason))
+	}
+	if want.Version != "" {
+		switch {
+		case d.skip != "":
+			problems = append(problems, fmt.Sprintf("skipped (%s), want %s", d.skip, want.Version))
+		case d.version != want.Version:
+			problems = append(problems, fmt.Sprintf("version is %s, want %s", d.version, want.Version))
+		}
+	}
+	if want.Reason != "" && !strings.Contains(d.reason, want.Reason) {
+		problems = append(problems, fmt.Sprintf("reason is %q, want it to contain %q", d.reason, want.Reason))
+	}
+	return problems
+}
+
+// usePolicy sets the environment to the policy settings in envs, later ones
+// winning, with the other policy settings unset, and resets what the last
+// configuration left behind, so each scenario starts afresh.
+func usePolicy(envs ...map[string]string) {
+	for _, name := range policyEnv {
+		os.Unsetenv(name)
+	}
+	for _, env := range envs {
+		for k, v := range env {
+			os.Setenv(k, v)
+		}
+	}
+
+	vPrefix, segments, patchSegment = "v", 3, 2
+	tagTemplate = template.Must(template.New("tag").Parse(defaultTagTemplate))
+	resets = map[bump]bool{bumpMajor: true, bumpMinor: true, bumpPatch: true}
+	squashCache = map[string]squashSettings{}
+}
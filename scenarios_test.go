@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func Test_checkScenario(t *testing.T) {
+	tagged := decision{version: "api/v1.3.0", reason: `minor bump from "feat:" in the PR title`}
+	skipped := decision{skip: skipNoChanges}
+
+	tests := []struct {
+		name string
+		want scenarioWant
+		d    decision
+		n    int // problems
+	}{
+		{"version", scenarioWant{Version: "api/v1.3.0"}, tagged, 0},
+		{"wrong version", scenarioWant{Version: "api/v2.0.0"}, tagged, 1},
+		{"reason", scenarioWant{Version: "api/v1.3.0", Reason: "in the PR title"}, tagged, 0},
+		{"wrong reason", scenarioWant{Reason: "label"}, tagged, 1},
+		{"skip", scenarioWant{SkipReason: "no_matching_files"}, skipped, 0},
+		{"unexpected skip", scenarioWant{Version: "api/v1.3.0"}, skipped, 1},
+		{"unexpected release", scenarioWant{SkipReason: "no_matching_files"}, tagged, 1},
+		{"nothing expected", scenarioWant{}, tagged, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkScenario(tt.want, tt.d); len(got) != tt.n {
+				t.Errorf("got problems %q, want %d", got, tt.n)
+			}
+		})
+	}
+}
+
+func Test_usePolicy(t *testing.T) {
+	defer usePolicy()
+	os.Setenv("TAG_PREFIX", "old/")
+	segments, vPrefix = 4, ""
+
+	usePolicy(map[string]string{"TAG_PREFIX": "api/", "CAP_MAJOR": "true"}, map[string]string{"CAP_MAJOR": "false"})
+
+	got := map[string]string{"TAG_PREFIX": os.Getenv("TAG_PREFIX"), "CAP_MAJOR": os.Getenv("CAP_MAJOR")}
+	want := map[string]string{"TAG_PREFIX": "api/", "CAP_MAJOR": "false"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if segments != 3 || vPrefix != "v" {
+		t.Errorf("got segments %d and vPrefix %q, want them reset", segments, vPrefix)
+	}
+}
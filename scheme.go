@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+// scheme is how versions are numbered, picked with VERSION_SCHEME. Tags of
+// every scheme parse as versions, so finding the last one works the same.
+type scheme interface {
+	// next returns the tag for the release after last, bumped as b says.
+	next(cfg *config, last *version.Version, b bump) string
+}
+
+// semverScheme numbers versions with semantic versioning, the default.
+type semverScheme struct{}
+
+func (semverScheme) next(cfg *config, last *version.Version, b bump) string {
+	if cfg.prerelease != "" {
+		return nextPrerelease(last, cfg.prefix, cfg.prerelease, b)
+	}
+	return nextVersion(last, cfg.prefix, b)
+}
+
+// calverScheme numbers versions by release date, following calver.org. The
+// bump doesn't matter, only the date and, when the format has a MICRO, how
+// many releases there were at that date.
+type calverScheme struct {
+	format []string // like YYYY, 0M and MICRO
+	now    func() time.Time
+}
+
+// calverTokens are the supported parts of a calendar version.
+var calverTokens = map[string]bool{
+	"YYYY": true, "YY": true, "0Y": true,
+	"MM": true, "0M": true,
+	"WW": true, "0W": true,
+	"DD": true, "0D": true,
+	"MICRO": true,
+}
+
+// parseCalver parses a CALVER_FORMAT like YYYY.0M.MICRO. MICRO can only be
+// the last part.
+func parseCalver(format string) (*calverScheme, error) {
+	parts := strings.Split(format, ".")
+	for i, p := range parts {
+		if !calverTokens[p] {
+			return nil, fmt.Errorf("unknown part %q in %q", p, format)
+		}
+		if p == "MICRO" && i != len(parts)-1 {
+			return nil, fmt.Errorf("MICRO must be the last part of %q", format)
+		}
+	}
+	return &calverScheme{format: parts, now: func() time.Time { return time.Now().UTC() }}, nil
+}
+
+// datePart renders the date part token of t.
+func datePart(token string, t time.Time) (string, int) {
+	_, week := t.ISOWeek()
+	switch token {
+	case "YYYY":
+		return fmt.Sprint(t.Year()), t.Year()
+	case "YY":
+		return fmt.Sprint(t.Year() - 2000), t.Year() - 2000
+	case "0Y":
+		return fmt.Sprintf("%02d", t.Year()-2000), t.Year() - 2000
+	case "MM":
+		return fmt.Sprint(int(t.Month())), int(t.Month())
+	case "0M":
+		return fmt.Sprintf("%02d", t.Month()), int(t.Month())
+	case "WW":
+		return fmt.Sprint(week), week
+	case "0W":
+		return fmt.Sprintf("%02d", week), week
+	case "DD":
+		return fmt.Sprint(t.Day()), t.Day()
+	default: // 0D
+		return fmt.Sprintf("%02d", t.Day()), t.Day()
+	}
+}
+
+func (s *calverScheme) next(cfg *config, last *version.Version, b bump) string {
+	now := s.now()
+	segs := last.Segments()
+
+	var parts []string
+	sameDate := true
+	for i, token := range s.format {
+		if token == "MICRO" {
+			micro := 0
+			if sameDate && i < len(segs) {
+				micro = segs[i] + 1
+			}
+			parts = append(parts, fmt.Sprint(micro))
+			break
+		}
+
+		text, n := datePart(token, now)
+		if i >= len(segs) || segs[i] != n {
+			sameDate = false
+		}
+		parts = append(parts, text)
+	}
+	return cfg.prefix + vPrefix + strings.Join(parts, ".")
+}
+
+// bumpVersion returns the tag for the release after v, bumped as b says,
+// in the configured scheme.
+func bumpVersion(cfg *config, v *version.Version, b bump) string {
+	return cfg.scheme.next(cfg, v, b)
+}
+
+// vPrefix goes before the version in tags: "v", or "" with V_PREFIX=false
+// or when the existing tags go without.
+var vPrefix = "v"
+
+// detectVPrefix follows the convention of tag, the last version under
+// prefix, as to whether versions start with a v.
+func detectVPrefix(tag, prefix string) {
+	if strings.HasPrefix(strings.TrimPrefix(tag, prefix), "v") {
+		vPrefix = "v"
+	} else {
+		vPrefix = ""
+		tracef("%s goes without a v, so will the next tag", tag)
+	}
+}
+
+// tagData is what TAG_TEMPLATE is rendered with.
+type tagData struct {
+	Prefix              string
+	V                   string // the v before the version, if any
+	Major, Minor, Patch int
+	Revision            int    // the fourth number, with VERSION_SEGMENTS=4
+	Channel             string // the prerelease channel, "" for final versions
+	Number              int    // the prerelease's number on the channel
+}
+
+// defaultTagTemplate renders the tags semver releases get without a
+// TAG_TEMPLATE, like api/v1.4.0 and api/v1.4.0-rc.3.
+const defaultTagTemplate = "{{.Prefix}}{{.V}}{{.Major}}.{{.Minor}}.{{.Patch}}{{if .Channel}}-{{.Channel}}.{{.Number}}{{end}}"
+
+// defaultTagTemplate4 is defaultTagTemplate for four-number versions, like
+// api/v1.4.0.2.
+const defaultTagTemplate4 = "{{.Prefix}}{{.V}}{{.Major}}.{{.Minor}}.{{.Patch}}.{{.Revision}}{{if .Channel}}-{{.Channel}}.{{.Number}}{{end}}"
+
+// segments is how many numbers semver versions have: 3, or 4 with
+// VERSION_SEGMENTS=4 for versions like v1.2.3.4.
+var segments = 3
+
+// patchSegment is the index of the number patch bumps increment, the last
+// one unless PATCH_SEGMENT says otherwise.
+var patchSegment = 2
+
+// newTagData returns the tagData for the numbers segs, padded with zeros
+// or cut to the configured number of segments.
+func newTagData(prefix string, segs []int) tagData {
+	for len(segs) < 4 {
+		segs = append(segs, 0)
+	}
+	d := tagData{Prefix: prefix, Major: segs[0], Minor: segs[1], Patch: segs[2]}
+	if segments == 4 {
+		d.Revision = segs[3]
+	}
+	return d
+}
+
+// tagTemplate renders semver tags, set from TAG_TEMPLATE.
+var tagTemplate = template.Must(template.New("tag").Parse(defaultTagTemplate))
+
+// formatTag renders the tag for d.
+func formatTag(d tagData) string {
+	d.V = vPrefix
+	var b strings.Builder
+	if err := tagTemplate.Execute(&b, d); err != nil {
+		fatalf("could not render TAG_TEMPLATE: %v", err)
+	}
+	return b.String()
+}
+
+// checkTagTemplate makes sure the tags t renders under prefix can be read
+// back as the versions they're for, or they'd never be found as the last
+// version. Prereleases only need to when prereleases are on.
+func checkTagTemplate(t *template.Template, prefix string, prereleases bool) error {
+	want := newTagData(prefix, []int{1, 2, 3, 5})
+	tests := []tagData{want}
+	if prereleases {
+		pre := want
+		pre.Channel, pre.Number = "rc", 4
+		tests = append(tests, pre)
+	}
+
+	for _, d := range tests {
+		d.V = vPrefix
+		var b strings.Builder
+		if err := t.Execute(&b, d); err != nil {
+			return err
+		}
+		tag := b.String()
+
+		v, err := version.NewSemver(strings.TrimPrefix(tag, prefix))
+		if err != nil || !strings.HasPrefix(tag, prefix) {
+			return fmt.Errorf("%s doesn't read back as a version under %q", tag, prefix)
+		}
+		got := newTagData(prefix, v.Segments())
+		got.V = d.V
+		got.Channel, got.Number = prereleaseParts(v)
+		if len(v.Segments()) > segments || got != d {
+			return fmt.Errorf("%s doesn't read back as the version it's for", tag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+func Test_calverScheme(t *testing.T) {
+	now := time.Date(2024, time.June, 3, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		format, last, want string
+	}{
+		{"YYYY.0M.MICRO", "v0.0.0", "v2024.06.0"},
+		{"YYYY.0M.MICRO", "v2024.06.2", "v2024.06.3"},
+		{"YYYY.0M.MICRO", "v2024.05.7", "v2024.06.0"},
+		{"YYYY.0M.MICRO", "v1.4.2", "v2024.06.0"},
+		{"YY.MM.DD", "v24.6.2", "v24.6.3"},
+		{"0Y.0W.MICRO", "v24.23.0", "v24.23.1"},
+		{"YYYY.MM.DD.MICRO", "v2024.6.3.1", "v2024.6.3.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.last, func(t *testing.T) {
+			s, err := parseCalver(tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.now = func() time.Time { return now }
+
+			last, err := version.NewSemver(tt.last)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.next(&config{}, last, bumpMinor); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	for _, bad := range []string{"YYYY.MICRO.MM", "YYYY.Q"} {
+		if _, err := parseCalver(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+func Test_checkTagTemplate(t *testing.T) {
+	tests := []struct {
+		tmpl       string
+		prerelease bool
+		ok         bool
+	}{
+		{defaultTagTemplate, true, true},
+		{"{{.Prefix}}{{.Major}}.{{.Minor}}.{{.Patch}}", false, true},
+		{"{{.Prefix}}v{{.Major}}.{{.Minor}}.{{.Patch}}-{{.Channel}}", false, false},
+		{"{{.Prefix}}v{{.Major}}.{{.Minor}}.{{.Patch}}{{if .Channel}}-{{.Channel}}{{.Number}}{{end}}", false, true},
+		{"{{.Prefix}}v{{.Major}}.{{.Minor}}.{{.Patch}}{{if .Channel}}-{{.Channel}}{{.Number}}{{end}}", true, false},
+		{"v{{.Major}}.{{.Minor}}.{{.Patch}}", false, false},
+		{"{{.Prefix}}v{{.Minor}}.{{.Major}}.{{.Patch}}", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tmpl, func(t *testing.T) {
+			tmpl := template.Must(template.New("tag").Parse(tt.tmpl))
+			if err := checkTagTemplate(tmpl, "api/", tt.prerelease); (err == nil) != tt.ok {
+				t.Errorf("got %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_detectVPrefix(t *testing.T) {
+	defer func() { vPrefix = "v" }()
+
+	tests := []struct {
+		tag, want string
+	}{
+		{"api/1.2.3", "api/1.2.4"},
+		{"api/v1.2.3", "api/v1.2.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			detectVPrefix(tt.tag, "api/")
+			v, err := version.NewSemver(tt.tag[len("api/"):])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := nextVersion(v, "api/", bumpPatch); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_fourSegments(t *testing.T) {
+	defer func() {
+		segments, patchSegment = 3, 2
+		tagTemplate = template.Must(template.New("tag").Parse(defaultTagTemplate))
+	}()
+	segments = 4
+	tagTemplate = template.Must(template.New("tag").Parse(defaultTagTemplate4))
+
+	tests := []struct {
+		previous     string
+		patchSegment int
+		bump         bump
+		want         string
+	}{
+		{"v1.2.3.4", 3, bumpPatch, "v1.2.3.5"},
+		{"v1.2.3.4", 2, bumpPatch, "v1.2.4.0"},
+		{"v1.2.3.4", 3, bumpMinor, "v1.3.0.0"},
+		{"v1.2.3", 3, bumpPatch, "v1.2.3.1"},
+		{"v1.2.3.4-rc.1", 3, bumpMinor, "v1.3.0.0-rc.1"},
+		{"v1.3.0.0-rc.1", 3, bumpMinor, "v1.3.0.0-rc.2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.previous+" "+tt.bump.String(), func(t *testing.T) {
+			patchSegment = tt.patchSegment
+			v, err := version.NewSemver(tt.previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := nextVersion(v, "", tt.bump)
+			if v.Prerelease() != "" {
+				got = nextPrerelease(v, "", "rc", tt.bump)
+			}
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if err := checkTagTemplate(tagTemplate, "api/", true); err != nil {
+		t.Errorf("default four-segment template: %v", err)
+	}
+	if err := checkTagTemplate(template.Must(template.New("tag").Parse(defaultTagTemplate)), "api/", false); err == nil {
+		t.Error("three-segment template passed with four segments")
+	}
+}
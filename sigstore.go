@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The public Sigstore instance, which signs with short-lived certificates
+// for an OIDC identity and logs the signatures in a transparency log.
+const (
+	fulcioURL = "https://fulcio.sigstore.dev"
+	rekorURL  = "https://rekor.sigstore.dev"
+)
+
+// sigstoreClient talks to Fulcio and Rekor.
+var sigstoreClient = &http.Client{Timeout: 30 * time.Second}
+
+// signature is a Sigstore signature over a blob, as cosign verify-blob takes
+// it.
+type signature struct {
+	Certificate string // the signing certificate, PEM encoded
+	Signature   string // the base64 ASN.1 ECDSA signature
+	LogIndex    int64  // the index of the Rekor entry
+	LogID       string // the UUID of the Rekor entry
+}
+
+// sigstoreSign signs blob with a key made for this run alone. Fulcio
+// certifies the key for the workflow's identity, proven by token, an OIDC
+// token with the sigstore audience, and the signature is logged in Rekor,
+// so it can be checked once the certificate has expired. The token itself
+// is only sent to Fulcio.
+func sigstoreSign(blob []byte, token string) (signature, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return signature{}, err
+	}
+
+	sub, err := tokenSubject(token)
+	if err != nil {
+		return signature{}, fmt.Errorf("invalid OIDC token: %v", err)
+	}
+	proof, err := signASN1(key, []byte(sub))
+	if err != nil {
+		return signature{}, err
+	}
+	cert, err := fulcioCert(key, token, proof)
+	if err != nil {
+		return signature{}, fmt.Errorf("could not get a signing certificate: %v", err)
+	}
+
+	sig, err := signASN1(key, blob)
+	if err != nil {
+		return signature{}, err
+	}
+	s := signature{Certificate: cert, Signature: base64.StdEncoding.EncodeToString(sig)}
+	if s.LogIndex, s.LogID, err = rekorUpload(blob, s); err != nil {
+		return signature{}, fmt.Errorf("could not log the signature: %v", err)
+	}
+	return s, nil
+}
+
+// tokenSubject returns the sub claim of the JWT token, which Fulcio wants
+// signed to prove the key is ours.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	var claims struct {
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", err
+	}
+	if claims.Sub == "" {
+		return "", fmt.Errorf("no subject")
+	}
+	return claims.Sub, nil
+}
+
+// signASN1 signs the SHA-256 digest of b with key, ASN.1 encoded.
+func signASN1(key *ecdsa.PrivateKey, b []byte) ([]byte, error) {
+	sum := sha256.Sum256(b)
+	r, s, err := ecdsa.Sign(rand.Reader, key, sum[:])
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
+
+// fulcioCert requests a certificate for key, returning it without the chain
+// up to Fulcio's root, which verifiers already have.
+func fulcioCert(key *ecdsa.PrivateKey, token string, proof []byte) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+
+	var req struct {
+		Credentials struct {
+			OIDCIdentityToken string `json:"oidcIdentityToken"`
+		} `json:"credentials"`
+		PublicKeyRequest struct {
+			PublicKey struct {
+				Algorithm string `json:"algorithm"`
+				Content   string `json:"content"`
+			} `json:"publicKey"`
+			ProofOfPossession string `json:"proofOfPossession"`
+		} `json:"publicKeyRequest"`
+	}
+	req.Credentials.OIDCIdentityToken = token
+	req.PublicKeyRequest.PublicKey.Algorithm = "ECDSA"
+	req.PublicKeyRequest.PublicKey.Content = string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	req.PublicKeyRequest.ProofOfPossession = base64.StdEncoding.EncodeToString(proof)
+
+	type chain struct {
+		Chain struct {
+			Certificates []string `json:"certificates"`
+		} `json:"chain"`
+	}
+	var resp struct {
+		Embedded chain `json:"signedCertificateEmbeddedSct"`
+		Detached chain `json:"signedCertificateDetachedSct"`
+	}
+	if err := postJSON(fulcioURL+"/api/v2/signingCert", req, &resp); err != nil {
+		return "", err
+	}
+
+	certs := resp.Embedded.Chain.Certificates
+	if len(certs) == 0 {
+		certs = resp.Detached.Chain.Certificates
+	}
+	if len(certs) == 0 {
+		return "", fmt.Errorf("no certificate in the response")
+	}
+	return certs[0], nil
+}
+
+// rekorUpload logs s, the signature of blob, in Rekor, returning the index
+// and ID of its entry.
+func rekorUpload(blob []byte, s signature) (int64, string, error) {
+	sum := sha256.Sum256(blob)
+	entry := map[string]interface{}{
+		"apiVersion": "0.0.1",
+		"kind":       "hashedrekord",
+		"spec": map[string]interface{}{
+			"signature": map[string]interface{}{
+				"content":   s.Signature,
+				"publicKey": map[string]string{"content": base64.StdEncoding.EncodeToString([]byte(s.Certificate))},
+			},
+			"data": map[string]interface{}{
+				"hash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(sum[:])},
+			},
+		},
+	}
+
+	var resp map[string]struct {
+		LogIndex int64 `json:"logIndex"`
+	}
+	if err := postJSON(rekorURL+"/api/v1/log/entries", entry, &resp); err != nil {
+		return 0, "", err
+	}
+	for id, e := range resp {
+		return e.LogIndex, id, nil
+	}
+	return 0, "", fmt.Errorf("no entry in the response")
+}
+
+// postJSON posts in as JSON to u, and decodes the response into out.
+func postJSON(u string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	resp, err := sigstoreClient.Post(u, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func Test_tokenSubject(t *testing.T) {
+	jwt := func(payload string) string {
+		return "e30." + base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".sig"
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  string
+		err   bool
+	}{
+		{"subject", jwt(`{"sub":"repo:acme/app:ref:refs/heads/master","aud":"sigstore"}`), "repo:acme/app:ref:refs/heads/master", false},
+		{"no subject", jwt(`{"aud":"sigstore"}`), "", true},
+		{"not a JWT", "abc", "", true},
+		{"bad payload", "e30.!!.sig", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tokenSubject(tt.token)
+			if got != tt.want || (err != nil) != tt.err {
+				t.Errorf("got %q, %v, want %q, error %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// releasePoint is a final release in the tag history.
+type releasePoint struct {
+	tag  string
+	v    *version.Version
+	date time.Time // of the tagged commit
+}
+
+// prefixStats is the release cadence under a prefix.
+type prefixStats struct {
+	Prefix      string         `json:"prefix"`
+	Releases    int            `json:"releases"`
+	Prereleases int            `json:"prereleases"`
+	First       string         `json:"first,omitempty"`
+	Last        string         `json:"last,omitempty"`
+	Bumps       map[string]int `json:"bumps"` // how many releases were major, minor and patch bumps
+	MeanDays    float64        `json:"mean_days_between"`
+	MedianDays  float64        `json:"median_days_between"`
+	PRs         int            `json:"prs"` // merged after the first release, up to the last
+	PRsPer      float64        `json:"prs_per_release"`
+}
+
+// statsCmd reports the release cadence per prefix, from the tag history and
+// the pull requests merged between releases, as markdown or JSON, e.g. for
+// dashboards.
+func statsCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	all := fs.Bool("all", false, "report every prefix instead of only those of TAG_PREFIX")
+	format := fs.String("format", "markdown", "markdown or json")
+	branch := fs.String("branch", "", "base branch of the pull requests (default: the repository's default branch)")
+	fs.Parse(args)
+
+	if *format != "markdown" && *format != "json" {
+		fatalf("invalid -format %q: must be markdown or json", *format)
+	}
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	if *branch == "" {
+		r, _, err := cli.c.Repositories.Get(ctx, cli.owner, cli.repo)
+		if err != nil {
+			fatalf("could not get repository: %v", err)
+		}
+		*branch = r.GetDefaultBranch()
+	}
+
+	var tags []string
+	err := cli.forEachTag(ctx, func(name string, r *github.Reference) {
+		tags = append(tags, name)
+	})
+	if err != nil {
+		fatalf("could not list tags: %v", err)
+	}
+
+	type history struct {
+		prefix      string
+		releases    []releasePoint
+		prereleases int
+	}
+	var histories []history
+	var oldest time.Time
+	for _, p := range groupVersions(tags) {
+		if !*all && !statsPrefix(cfg, p.prefix) {
+			continue
+		}
+
+		h := history{prefix: p.prefix}
+		for _, v := range p.versions {
+			if v.Prerelease() != "" {
+				h.prereleases++
+				continue
+			}
+			tag := p.prefix + v.Original()
+			date, err := cli.commitDate(ctx, tag)
+			if err != nil {
+				fatalf("could not get date of %s: %v", tag, err)
+			}
+			h.releases = append(h.releases, releasePoint{tag: tag, v: v, date: date})
+			if oldest.IsZero() || date.Before(oldest) {
+				oldest = date
+			}
+		}
+		histories = append(histories, h)
+	}
+
+	var merged []time.Time
+	if !oldest.IsZero() {
+		prs, err := cli.mergedSince(ctx, *branch, oldest)
+		if err != nil {
+			fatalf("could not list pull requests: %v", err)
+		}
+		for _, pr := range prs {
+			merged = append(merged, pr.GetMergedAt())
+		}
+	}
+
+	var stats []prefixStats
+	for _, h := range histories {
+		stats = append(stats, computeStats(h.prefix, h.releases, h.prereleases, merged))
+	}
+
+	if *format == "json" {
+		b, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			fatalf("could not encode stats: %v", err)
+		}
+		fmt.Println(string(b))
+		return
+	}
+	writeStats(os.Stdout, stats)
+}
+
+// statsPrefix reports whether prefix is one of TAG_PREFIX, which may list
+// several.
+func statsPrefix(cfg *config, prefix string) bool {
+	if len(cfg.prefixes) == 0 {
+		return prefix == cfg.prefix
+	}
+	for _, p := range cfg.prefixes {
+		if p == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// computeStats works out the cadence of the releases under prefix, in
+// version order, given how many prereleases there were and when pull
+// requests were merged.
+func computeStats(prefix string, releases []releasePoint, prereleases int, merged []time.Time) prefixStats {
+	s := prefixStats{
+		Prefix:      prefix,
+		Releases:    len(releases),
+		Prereleases: prereleases,
+		Bumps:       map[string]int{bumpMajor.String(): 0, bumpMinor.String(): 0, bumpPatch.String(): 0},
+	}
+	if len(releases) == 0 {
+		return s
+	}
+	for i := 1; i < len(releases); i++ {
+		s.Bumps[bumpBetween(releases[i-1].v, releases[i].v).String()]++
+	}
+
+	// backports are released after newer versions, cadence goes by date
+	byDate := append([]releasePoint(nil), releases...)
+	sort.SliceStable(byDate, func(i, j int) bool { return byDate[i].date.Before(byDate[j].date) })
+	first, last := byDate[0], byDate[len(byDate)-1]
+	s.First, s.Last = first.tag, last.tag
+	if len(byDate) < 2 {
+		return s
+	}
+
+	days := make([]float64, 0, len(byDate)-1)
+	total := 0.0
+	for i := 1; i < len(byDate); i++ {
+		d := byDate[i].date.Sub(byDate[i-1].date).Hours() / 24
+		days = append(days, d)
+		total += d
+	}
+	sort.Float64s(days)
+	s.MeanDays = total / float64(len(days))
+	if n := len(days); n%2 == 1 {
+		s.MedianDays = days[n/2]
+	} else {
+		s.MedianDays = (days[n/2-1] + days[n/2]) / 2
+	}
+
+	for _, t := range merged {
+		if t.After(first.date) && !t.After(last.date) {
+			s.PRs++
+		}
+	}
+	s.PRsPer = float64(s.PRs) / float64(len(byDate)-1)
+	return s
+}
+
+// bumpBetween returns the bump releasing v after prev, the first number to
+// grow deciding. Numbers after the third count as patch bumps.
+func bumpBetween(prev, v *version.Version) bump {
+	ps, vs := prev.Segments(), v.Segments()
+	for i := 0; i < len(vs) && i < len(ps); i++ {
+		if vs[i] == ps[i] {
+			continue
+		}
+		switch i {
+		case 0:
+			return bumpMajor
+		case 1:
+			return bumpMinor
+		}
+		break
+	}
+	return bumpPatch
+}
+
+// writeStats writes stats as a markdown table.
+func writeStats(w io.Writer, stats []prefixStats) {
+	fmt.Fprintln(w, "| Prefix | Releases | Prereleases | Major | Minor | Patch | Days between (mean) | Days between (median) | PRs per release | Latest |")
+	fmt.Fprintln(w, "|---|---:|---:|---:|---:|---:|---:|---:|---:|---|")
+	for _, s := range stats {
+		name := s.Prefix
+		if name == "" {
+			name = "(no prefix)"
+		}
+		fmt.Fprintf(w, "| %s | %d | %d | %d | %d | %d | %.1f | %.1f | %.1f | %s |\n", name, s.Releases, s.Prereleases,
+			s.Bumps["major"], s.Bumps["minor"], s.Bumps["patch"], s.MeanDays, s.MedianDays, s.PRsPer, s.Last)
+	}
+}
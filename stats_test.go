@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-version"
+)
+
+func Test_bumpBetween(t *testing.T) {
+	tests := []struct {
+		prev, v string
+		want    bump
+	}{
+		{"v1.2.3", "v2.0.0", bumpMajor},
+		{"v1.2.3", "v1.3.0", bumpMinor},
+		{"v1.2.3", "v1.2.4", bumpPatch},
+		{"v1.2.3.4", "v1.2.3.5", bumpPatch},
+		{"v1.2", "v1.2.1", bumpPatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prev+" "+tt.v, func(t *testing.T) {
+			prev, v := version.Must(version.NewSemver(tt.prev)), version.Must(version.NewSemver(tt.v))
+			if got := bumpBetween(prev, v); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_computeStats(t *testing.T) {
+	day := func(n int) time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, n) }
+	release := func(tag string, n int) releasePoint {
+		return releasePoint{tag: tag, v: version.Must(version.NewSemver(tag)), date: day(n)}
+	}
+
+	// in version order, v1.0.1 is a backport released after v1.1.0
+	releases := []releasePoint{
+		release("v1.0.0", 0),
+		release("v1.0.1", 10),
+		release("v1.1.0", 2),
+		release("v2.0.0", 12),
+	}
+	merged := []time.Time{day(-1), day(1), day(2), day(5), day(11), day(20)}
+
+	got := computeStats("", releases, 2, merged)
+	want := prefixStats{
+		Releases:    4,
+		Prereleases: 2,
+		First:       "v1.0.0",
+		Last:        "v2.0.0",
+		Bumps:       map[string]int{"major": 1, "minor": 1, "patch": 1},
+		MeanDays:    4,
+		MedianDays:  2,
+		PRs:         4,
+		PRsPer:      4.0 / 3,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	if got := computeStats("api/", nil, 0, merged); got.Releases != 0 || got.MeanDays != 0 || got.First != "" {
+		t.Errorf("got %+v for no releases", got)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tagInfo is what a tag says about its release.
+type tagInfo struct {
+	date    time.Time         // the tagger's date, or the commit's for lightweight tags
+	message string            // the annotated tag's message, "" for lightweight tags
+	meta    map[string]string // the trailers of the message, like "Channel: rc"
+}
+
+// getTagInfo looks up when tag was made and, when it's annotated, its
+// message.
+func (c *client) getTagInfo(ctx context.Context, tag string) (tagInfo, error) {
+	if c.release != nil {
+		return c.release.getTagInfo(ctx, tag)
+	}
+	refs, _, err := c.c.Git.GetRefs(ctx, c.owner, c.repo, "tags/"+tag)
+	if err != nil {
+		return tagInfo{}, err
+	}
+
+	for _, r := range refs {
+		if r.GetRef() != "refs/tags/"+tag {
+			continue
+		}
+		if r.GetObject().GetType() != "tag" {
+			date, err := c.commitDate(ctx, r.GetObject().GetSHA())
+			return tagInfo{date: date}, err
+		}
+
+		t, _, err := c.c.Git.GetTag(ctx, c.owner, c.repo, r.GetObject().GetSHA())
+		if err != nil {
+			return tagInfo{}, err
+		}
+		return tagInfo{date: t.GetTagger().GetDate(), message: t.GetMessage(), meta: tagTrailers(t.GetMessage())}, nil
+	}
+	return tagInfo{}, fmt.Errorf("tag %s doesn't exist", tag)
+}
+
+// trailerRE matches a trailer line, like "Channel: rc".
+var trailerRE = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9-]*): *(.+)$`)
+
+// tagTrailers returns the trailers in the last paragraph of the tag message
+// msg, the way git writes them, or nil if there are none. The paragraph
+// has to be all trailers, or it's just text.
+func tagTrailers(msg string) map[string]string {
+	paras := strings.Split(strings.TrimSpace(strings.Replace(msg, "\r\n", "\n", -1)), "\n\n")
+	if len(paras) < 2 {
+		return nil
+	}
+
+	meta := map[string]string{}
+	for _, line := range strings.Split(paras[len(paras)-1], "\n") {
+		m := trailerRE.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			return nil
+		}
+		meta[m[1]] = strings.TrimSpace(m[2])
+	}
+	return meta
+}
+
+// tagMessage returns the message of the annotated tag of version, with the
+// trailers in meta, in order.
+func tagMessage(version string, meta map[string]string) string {
+	msg := "Release " + version
+	if len(meta) == 0 {
+		return msg
+	}
+
+	keys := make([]string, 0, len(meta))
+	for k := range meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	msg += "\n"
+	for _, k := range keys {
+		msg += fmt.Sprintf("\n%s: %s", k, meta[k])
+	}
+	return msg
+}
+
+// releaseMeta returns the trailers of the annotated tag of d's version: the
+// ones carried forward from the previous release's, and its own previous
+// version and channel.
+func releaseMeta(d decision, previous tagInfo, channel string) map[string]string {
+	meta := map[string]string{}
+	for k, v := range previous.meta {
+		meta[k] = v
+	}
+	delete(meta, "Channel")
+	if d.previous != "" {
+		meta["Previous"] = d.previous
+	}
+	if channel != "" {
+		meta["Channel"] = channel
+	}
+	return meta
+}
+
+// sinceRelease describes how long ago a release made d ago was, like "3
+// days".
+func sinceRelease(d time.Duration) string {
+	plural := func(n int, unit string) string {
+		if n == 1 {
+			return "1 " + unit
+		}
+		return fmt.Sprintf("%d %ss", n, unit)
+	}
+
+	switch {
+	case d < time.Hour:
+		return "less than an hour"
+	case d < 24*time.Hour:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/(24*time.Hour)), "day")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_tagTrailers(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want map[string]string
+	}{
+		{name: "no message", msg: "", want: nil},
+		{name: "title only", msg: "Release v1.2.3", want: nil},
+		{
+			name: "trailers",
+			msg:  "Release v1.2.3-rc.1\n\nChannel: rc\nPrevious: v1.2.2\n",
+			want: map[string]string{"Channel": "rc", "Previous": "v1.2.2"},
+		},
+		{name: "text", msg: "Release v1.2.3\n\nThis one fixes: the bug", want: nil},
+		{name: "crlf", msg: "Release v1.2.3\r\n\r\nTeam: core", want: map[string]string{"Team": "core"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tagTrailers(tt.msg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_releaseMeta(t *testing.T) {
+	prev := tagInfo{meta: map[string]string{"Channel": "rc", "Previous": "v1.2.2-rc.1", "Team": "core"}}
+
+	got := tagMessage("v1.2.3", releaseMeta(decision{previous: "v1.2.3-rc.1"}, prev, ""))
+	want := "Release v1.2.3\n\nPrevious: v1.2.3-rc.1\nTeam: core"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got = tagMessage("v0.1.0-beta.1", releaseMeta(decision{}, tagInfo{}, "beta"))
+	want = "Release v0.1.0-beta.1\n\nChannel: beta"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	if got := tagMessage("v0.1.0", nil); got != "Release v0.1.0" {
+		t.Errorf("got %q without trailers", got)
+	}
+}
+
+func Test_sinceRelease(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{10 * time.Minute, "less than an hour"},
+		{time.Hour, "1 hour"},
+		{5*time.Hour + 59*time.Minute, "5 hours"},
+		{30 * time.Hour, "1 day"},
+		{72 * time.Hour, "3 days"},
+	}
+
+	for _, tt := range tests {
+		if got := sinceRelease(tt.d); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
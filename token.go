@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+)
+
+// kinds of tokens, which Github treats differently
+const (
+	tokenGithub  = "github_token" // the workflow's GITHUB_TOKEN, whose tags don't trigger workflows
+	tokenApp     = "app"          // a Github App installation token
+	tokenPAT     = "pat"          // a personal access token
+	tokenOAuth   = "oauth"        // an OAuth token, like the Github CLI's
+	tokenUnknown = "unknown"
+)
+
+// detectTokenKind tells the kind of tok by its prefix. Installation tokens
+// of apps and the workflow's GITHUB_TOKEN look alike, within Actions they're
+// taken for GITHUB_TOKEN unless TOKEN_KIND says it's an app's.
+func detectTokenKind(tok string) string {
+	switch {
+	case strings.HasPrefix(tok, "ghs_"):
+		return tokenGithub
+	case strings.HasPrefix(tok, "ghp_"), strings.HasPrefix(tok, "github_pat_"):
+		return tokenPAT
+	case strings.HasPrefix(tok, "gho_"):
+		return tokenOAuth
+	}
+	return tokenUnknown
+}
+
+// releaseEventPayload is the client_payload of the release event.
+type releaseEventPayload struct {
+	Tag      string `json:"tag"`
+	Version  string `json:"version"` // the tag without the prefix
+	Previous string `json:"previous,omitempty"`
+	Prefix   string `json:"prefix,omitempty"`
+	SHA      string `json:"sha"`
+}
+
+// sendReleaseEvent sends a repository_dispatch event for d's release, to the
+// repository it's tagged in. Tags pushed with GITHUB_TOKEN don't trigger
+// workflows, but its dispatch events do, so release pipelines listening to
+// them still run.
+func (c *client) sendReleaseEvent(ctx context.Context, cfg *config, d decision) error {
+	if c.release != nil {
+		return c.release.sendReleaseEvent(ctx, cfg, d)
+	}
+	payload, err := json.Marshal(releaseEventPayload{
+		Tag:      d.version,
+		Version:  strings.TrimPrefix(d.version, cfg.prefix),
+		Previous: d.previous,
+		Prefix:   cfg.prefix,
+		SHA:      d.ref,
+	})
+	if err != nil {
+		return err
+	}
+	raw := json.RawMessage(payload)
+
+	_, _, err = c.c.Repositories.Dispatch(ctx, c.owner, c.repo, github.DispatchRequestOptions{
+		EventType:     cfg.releaseEvent,
+		ClientPayload: &raw,
+	})
+	if err != nil {
+		return err
+	}
+	tracef("Sent the %s repository_dispatch event for %s", cfg.releaseEvent, d.version)
+	return nil
+}
+
+// tokenWarning explains what tagging with a token of kind means for
+// workflows triggered by tags, or returns "" when they're triggered as
+// usual.
+func tokenWarning(kind, event string) string {
+	if kind != tokenGithub {
+		return ""
+	}
+	msg := "Tags created with GITHUB_TOKEN don't trigger workflows on push: tags."
+	if event == "" {
+		return msg + " Use a Github App or personal access token instead, or set RELEASE_EVENT for a repository_dispatch event on every release."
+	}
+	return fmt.Sprintf("%s Workflows can listen to the %s repository_dispatch event instead, sent on every release, or use a Github App or personal access token.", msg, event)
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_detectTokenKind(t *testing.T) {
+	tests := []struct {
+		tok  string
+		want string
+	}{
+		{"ghs_abc", tokenGithub},
+		{"ghp_abc", tokenPAT},
+		{"github_pat_11abc", tokenPAT},
+		{"gho_abc", tokenOAuth},
+		{"0123456789abcdef", tokenUnknown},
+		{"", tokenUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := detectTokenKind(tt.tok); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.tok, got, tt.want)
+		}
+	}
+}
+
+func Test_tokenWarning(t *testing.T) {
+	if got := tokenWarning(tokenPAT, ""); got != "" {
+		t.Errorf("got %q for a personal access token", got)
+	}
+	if got := tokenWarning(tokenApp, "autotagger-release"); got != "" {
+		t.Errorf("got %q for an app token", got)
+	}
+	if got := tokenWarning(tokenGithub, "autotagger-release"); !strings.Contains(got, "autotagger-release repository_dispatch event") {
+		t.Errorf("got %q, expected it to point at the release event", got)
+	}
+	if got := tokenWarning(tokenGithub, ""); !strings.Contains(got, "RELEASE_EVENT") {
+		t.Errorf("got %q, expected it to suggest RELEASE_EVENT", got)
+	}
+}
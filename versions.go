@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/google/go-github/v29/github"
+	"github.com/hashicorp/go-version"
+)
+
+// tagRE splits a tag into its prefix and what should be its version.
+var tagRE = regexp.MustCompile(`^(.*?)(v?[0-9].*)$`)
+
+// prefixVersions is the version history found under a tag prefix.
+type prefixVersions struct {
+	prefix     string
+	versions   []*version.Version // in semver order
+	duplicates [][2]string        // pairs of tags holding the same version
+	malformed  []string           // tags that aren't valid semver
+}
+
+// latest returns the tag of the highest version, or an empty string if
+// there's none.
+func (p *prefixVersions) latest() string {
+	if len(p.versions) == 0 {
+		return ""
+	}
+	return p.prefix + p.versions[len(p.versions)-1].Original()
+}
+
+// groupVersions sorts the tags by prefix, returning the prefixes in
+// alphabetical order with their versions in semver order.
+func groupVersions(tags []string) []*prefixVersions {
+	byPrefix := map[string]*prefixVersions{}
+	get := func(prefix string) *prefixVersions {
+		p, ok := byPrefix[prefix]
+		if !ok {
+			p = &prefixVersions{prefix: prefix}
+			byPrefix[prefix] = p
+		}
+		return p
+	}
+
+	for _, t := range tags {
+		m := tagRE.FindStringSubmatch(t)
+		if m == nil {
+			// no version at all, assume the prefix is a directory
+			prefix := t[:strings.LastIndex(t, "/")+1]
+			get(prefix).malformed = append(get(prefix).malformed, t)
+			continue
+		}
+
+		p := get(m[1])
+		v, err := version.NewSemver(m[2])
+		if err != nil {
+			p.malformed = append(p.malformed, t)
+			continue
+		}
+		p.versions = append(p.versions, v)
+	}
+
+	var groups []*prefixVersions
+	for _, p := range byPrefix {
+		sort.Stable(version.Collection(p.versions))
+		for i := 1; i < len(p.versions); i++ {
+			if p.versions[i].Equal(p.versions[i-1]) {
+				p.duplicates = append(p.duplicates, [2]string{
+					p.prefix + p.versions[i-1].Original(),
+					p.prefix + p.versions[i].Original(),
+				})
+			}
+		}
+		sort.Strings(p.malformed)
+		groups = append(groups, p)
+	}
+
+	sort.Slice(groups, func(i, j int) bool { return groups[i].prefix < groups[j].prefix })
+	return groups
+}
+
+// versionsCmd prints every tag per prefix in semver order, flagging
+// malformed and duplicate versions, along with how far the default branch
+// is ahead of each prefix's latest version. Useful to audit a repository
+// before turning the action on.
+func versionsCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("versions", flag.ExitOnError)
+	all := fs.Bool("all", false, "report every prefix instead of only TAG_PREFIX")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	r, _, err := cli.c.Repositories.Get(ctx, cli.owner, cli.repo)
+	if err != nil {
+		fatalf("could not get repository: %v", err)
+	}
+	branch := r.GetDefaultBranch()
+
+	var tags []string
+	err = cli.forEachTag(ctx, func(name string, r *github.Reference) {
+		tags = append(tags, name)
+	})
+	if err != nil {
+		fatalf("could not list tags: %v", err)
+	}
+
+	for _, p := range groupVersions(tags) {
+		if !*all && p.prefix != cfg.prefix {
+			continue
+		}
+
+		name := p.prefix
+		if name == "" {
+			name = "(no prefix)"
+		}
+		fmt.Printf("%s: %d versions\n", name, len(p.versions))
+
+		for _, v := range p.versions {
+			fmt.Printf("    %s%s\n", p.prefix, v.Original())
+		}
+		for _, d := range p.duplicates {
+			fmt.Printf("    duplicate: %s and %s are the same version\n", d[0], d[1])
+		}
+		for _, t := range p.malformed {
+			fmt.Printf("    malformed: %s is not a valid semver\n", t)
+		}
+
+		if latest := p.latest(); latest != "" {
+			cmp, _, err := cli.c.Repositories.CompareCommits(ctx, cli.owner, cli.repo, latest, branch)
+			if err != nil {
+				fatalf("could not compare %s with %s: %v", latest, branch, err)
+			}
+			fmt.Printf("    %s is %d commits ahead of %s\n", branch, cmp.GetAheadBy(), latest)
+		}
+		fmt.Println()
+	}
+}
+
+// lastCmd only looks up the last version under TAG_PREFIX, the way a
+// release would, and prints its tag. It's also set as outputs, for
+// workflows that need the version before anything is released, like for
+// image cache keys.
+func lastCmd(cfg *config, args []string) {
+	fs := flag.NewFlagSet("last", flag.ExitOnError)
+	prefix := fs.String("prefix", cfg.prefix, "tag prefix to look under (default: TAG_PREFIX)")
+	branch := fs.String("branch", "", "pick the prerelease channel of this branch, with BRANCH_CHANNELS")
+	fs.Parse(args)
+
+	ctx := context.Background()
+	cli := newRepoClient(ctx)
+	cli.checkRateLimit(ctx, cfg)
+	cli.useReleaseRepo(ctx, cfg)
+
+	cfg.prefix = *prefix
+	if *branch != "" {
+		cfg.useBranch(*branch)
+	}
+	cfg.noTags = noTagsFail
+	last, tag, err := cli.lastRelease(ctx, cfg)
+	if err == errNoVersions {
+		fmt.Printf("No versions under %q yet\n", cfg.prefix)
+		exitSkipped("no_versions")
+	}
+	if err != nil {
+		fatal(err)
+	}
+
+	sha, err := cli.getTagSHA(ctx, tag)
+	if err != nil {
+		fatalf("could not look up tag %s: %v", tag, err)
+	}
+
+	outputs := lastOutputs(last, tag, cfg.prefix, sha)
+	for _, name := range []string{"tag", "version", "major", "minor", "patch", "prerelease", "sha"} {
+		if err := setOutput(name, outputs[name]); err != nil {
+			fatalf("could not set %s output: %v", name, err)
+		}
+	}
+	fmt.Println(tag)
+}
+
+// lastOutputs are the outputs of lastCmd for v, the version tagged tag on
+// the commit sha.
+func lastOutputs(v *version.Version, tag, prefix, sha string) map[string]string {
+	segs := v.Segments()
+	return map[string]string{
+		"tag":        tag,
+		"version":    strings.TrimPrefix(tag, prefix),
+		"major":      fmt.Sprint(segs[0]),
+		"minor":      fmt.Sprint(segs[1]),
+		"patch":      fmt.Sprint(segs[2]),
+		"prerelease": v.Prerelease(),
+		"sha":        sha,
+	}
+}
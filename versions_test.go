@@ -0,0 +1,74 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-version"
+)
+
+func Test_groupVersions(t *testing.T) {
+	tags := []string{
+		"v1.10.0",
+		"v1.2.0",
+		"v1.2",
+		"api/v0.1.0",
+		"api/vnext",
+		"api/v0.0.9",
+		"latest",
+	}
+
+	type group struct {
+		prefix     string
+		versions   []string
+		duplicates [][2]string
+		malformed  []string
+	}
+	want := []group{
+		{
+			prefix:     "",
+			versions:   []string{"v1.2.0", "v1.2", "v1.10.0"},
+			duplicates: [][2]string{{"v1.2.0", "v1.2"}},
+			malformed:  []string{"latest"},
+		},
+		{
+			prefix:    "api/",
+			versions:  []string{"v0.0.9", "v0.1.0"},
+			malformed: []string{"api/vnext"},
+		},
+	}
+
+	var got []group
+	for _, p := range groupVersions(tags) {
+		g := group{prefix: p.prefix, duplicates: p.duplicates, malformed: p.malformed}
+		for _, v := range p.versions {
+			g.versions = append(g.versions, v.Original())
+		}
+		got = append(got, g)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func Test_lastOutputs(t *testing.T) {
+	v, err := version.NewSemver("v1.4.0-rc.2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := lastOutputs(v, "api/v1.4.0-rc.2", "api/", "deadbeef")
+	want := map[string]string{
+		"tag":        "api/v1.4.0-rc.2",
+		"version":    "v1.4.0-rc.2",
+		"major":      "1",
+		"minor":      "4",
+		"patch":      "0",
+		"prerelease": "rc.2",
+		"sha":        "deadbeef",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}